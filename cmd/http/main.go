@@ -4,23 +4,37 @@ import (
 	"context"
 	"fmt"
 	"log"
-
 	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/auth"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/config"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/handler/http"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/middleware"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/notifier"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/profiling"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/publisher"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/storage/cache"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/storage/memory"
 	ProfilingDB "github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/storage/mongo"
 	MongoRepository "github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/storage/mongo/repository"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/storage/mysql"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/storage/mysql/repository"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/webhook"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/service"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func main() {
-	// Initialize Fiber app
-	app := fiber.New()
+	// Initialize Fiber app, errors of handlers and middlewares are written as problem+json
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
 
 	// Load env var
 	config, err := config.New()
@@ -28,17 +42,35 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Init Profling Database
-	ctx := context.Background()
-	profilingDBClient, err := ProfilingDB.New(ctx, config.ProfilingDB)
-	if err != nil {
-		fmt.Printf("Error initializing MongoDB connection: %v\n", err)
-		os.Exit(1)
-	}
-	defer profilingDBClient.Close(ctx)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	fmt.Println("Successfully connected to MongoDB")
-	profilingDb := profilingDBClient.Client.Database("product-management")
+	// Init Profling Database, MongoDB is only connected when a configured feature stores data in it.
+	// The product and idempotency stores can't run without it,
+	// the other features are skipped when it does not answer
+	mongoRequired := config.DB.ProductStore == "mongo" || (config.Idempotency.Enabled && config.Idempotency.Store == "mongo")
+	mongoUsed := mongoRequired ||
+		slices.Contains(config.Profiling.Sinks, "mongo") ||
+		config.Audit.Enabled ||
+		config.Webhook.Enabled ||
+		config.Auth.APIKeysEnabled
+	var profilingDb *mongo.Database
+	if mongoUsed {
+		connectCtx, connectCancel := context.WithTimeout(ctx, config.ProfilingDB.ConnectTimeout)
+		profilingDBClient, err := ProfilingDB.New(connectCtx, config.ProfilingDB)
+		connectCancel()
+		switch {
+		case err == nil:
+			defer profilingDBClient.Close(ctx)
+			fmt.Println("Successfully connected to MongoDB")
+			profilingDb = profilingDBClient.Client.Database(config.ProfilingDB.Database)
+		case mongoRequired:
+			fmt.Printf("Error initializing MongoDB connection: %v\n", err)
+			os.Exit(1)
+		default:
+			fmt.Printf("MongoDB is unavailable, starting without the mongo profiling sink, audit, webhooks and api keys: %v\n", err)
+		}
+	}
 
 	// Init MySQL DB
 	mysqlDB, err := mysql.New(ctx, config.DB)
@@ -50,14 +82,289 @@ func main() {
 
 	fmt.Println("Successfully connected to MySQL")
 
-	profilingRepo := MongoRepository.NewProfilingRepository(profilingDb, "request-logs")
-	profilingService := service.NewProfilingService(profilingRepo)
-	app.Use(middleware.RequestProfiling(profilingService))
+	// Profiling is stored in the configured sinks, mongo is only prepared when it is one of them
+	var mongoProfilingRepo port.ProfilingRepository
+	var profilingSpill http.SpillStatsReader
+	var profilingRollups port.ProfilingRollupService
+	if slices.Contains(config.Profiling.Sinks, "mongo") && profilingDb != nil {
+		profilingRepo := MongoRepository.NewProfilingRepository(profilingDb, config.ProfilingDB.Collection)
+		if err := profilingRepo.EnsureIndexes(ctx); err != nil {
+			fmt.Printf("Error creating profiling indexes: %v\n", err)
+			os.Exit(1)
+		}
+		if err := profilingRepo.EnsureRetention(ctx, config.Profiling.Retention); err != nil {
+			fmt.Printf("Error applying profiling retention: %v\n", err)
+			os.Exit(1)
+		}
+		mongoProfilingRepo = profilingRepo
+
+		// Summarize the raw records into hourly and daily rollups for trend charts
+		if config.Profiling.RollupEnabled {
+			rollupRepository := MongoRepository.NewProfilingRollupRepository(profilingDb,
+				config.ProfilingDB.Collection,
+				config.ProfilingDB.Collection+"-hourly",
+				config.ProfilingDB.Collection+"-daily")
+			if err := rollupRepository.EnsureIndexes(ctx); err != nil {
+				fmt.Printf("Error creating profiling rollup indexes: %v\n", err)
+				os.Exit(1)
+			}
+			rollupService, err := service.NewProfilingRollupService(rollupRepository,
+				config.Profiling.RollupInterval,
+				config.Profiling.RollupLookback,
+				config.Profiling.Retention)
+			if err != nil {
+				fmt.Printf("Error initializing profiling rollups: %v\n", err)
+				os.Exit(1)
+			}
+			go rollupService.Run(ctx)
+			profilingRollups = rollupService
+
+			// Hours that get records after they were rolled up are rolled up again
+			mongoProfilingRepo = profiling.NewTrackedSink(mongoProfilingRepo, rollupService.MarkWritten)
+		}
+
+		// Keep what mongo fails to write on disk until it is back
+		if config.Profiling.SpillEnabled {
+			spillSink, err := profiling.NewSpillSink(mongoProfilingRepo, profiling.SpillConfig{
+				Dir:             config.Profiling.SpillDir,
+				SegmentSize:     config.Profiling.SpillSegmentSize,
+				MaxSize:         config.Profiling.SpillMaxSize,
+				ReplayInterval:  config.Profiling.SpillReplayInterval,
+				ReplayBatchSize: config.Profiling.BatchSize,
+				ReplayTimeout:   config.Profiling.SpillReplayTimeout,
+			})
+			if err != nil {
+				fmt.Printf("Error initializing profiling spill: %v\n", err)
+				os.Exit(1)
+			}
+			go spillSink.Run(ctx)
+			mongoProfilingRepo = spillSink
+			profilingSpill = spillSink
+		}
+	}
+	if mongoProfilingRepo == nil {
+		// The other sinks are still written when mongo is unavailable
+		config.Profiling.Sinks = slices.DeleteFunc(slices.Clone(config.Profiling.Sinks), func(sink string) bool {
+			return sink == "mongo"
+		})
+	}
+	profilingRepo, err := profiling.New(config.Profiling, mongoProfilingRepo)
+	if err != nil {
+		fmt.Printf("Error initializing profiling sinks: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Profiling is written in batches by background workers, drained on shutdown
+	profilingService := service.NewProfilingService(profilingRepo, service.ProfilingWriterConfig{
+		Workers:       config.Profiling.Workers,
+		QueueSize:     config.Profiling.QueueSize,
+		BatchSize:     config.Profiling.BatchSize,
+		FlushInterval: config.Profiling.FlushInterval,
+		DropPolicy:    service.ProfilingDropPolicy(config.Profiling.DropPolicy),
+	})
+	profilingService.Start()
+	app.Use(middleware.RequestContext())
+	app.Use(middleware.RequestProfiling(profilingService, middleware.RequestProfilingConfig{
+		Include:            middleware.NewRouteMatcher(config.Profiling.IncludeRoutes),
+		Exclude:            middleware.NewRouteMatcher(config.Profiling.ExcludeRoutes),
+		SampleRate:         config.Profiling.SampleRate,
+		SlowThreshold:      config.Profiling.SlowThreshold,
+		SlowQueryThreshold: config.Profiling.SlowQueryThreshold,
+		NPlusOneThreshold:  config.Profiling.NPlusOneThreshold,
+		MaxQueries:         config.Profiling.MaxQueries,
+	}))
+
+	// Permissions of the principals, everything is allowed when auth is disabled
+	authorizer := service.NewAllowAllAuthorizer()
+	if config.Auth.Enabled {
+		authorizer = service.NewRoleAuthorizer(config.Auth.RolePermissions)
+	}
+
+	// Init api keys of machine clients, a key never gets more than its issuer holds
+	var apiKeyService port.APIKeyService
+	if config.Auth.APIKeysEnabled && profilingDb != nil {
+		apiKeyRepository := MongoRepository.NewAPIKeyRepository(profilingDb, "api-keys")
+		if err := apiKeyRepository.EnsureIndexes(ctx); err != nil {
+			fmt.Printf("Error creating api key indexes, api keys are disabled: %v\n", err)
+		} else {
+			apiKeyService = service.NewAPIKeyService(apiKeyRepository, authorizer)
+		}
+	}
+
+	// Authenticate requests, public routes can be called anonymously
+	if config.Auth.Enabled {
+		jwtVerifier, err := auth.NewJWTVerifier(config.Auth.JWT)
+		if err != nil {
+			fmt.Printf("Error initializing JWT verifier: %v\n", err)
+			os.Exit(1)
+		}
+		authenticators := []middleware.Authenticator{middleware.BearerJWT(jwtVerifier)}
+		if apiKeyService != nil {
+			authenticators = append(authenticators, middleware.APIKey(apiKeyService))
+		}
+		app.Use(middleware.Authenticate(middleware.NewRouteMatcher(config.Auth.PublicRoutes), authenticators...))
+	}
+	app.Use(middleware.Tenant(authorizer))
+
+	// Init audit trail on the same MongoDB connection as profiling
+	var auditService port.AuditService
+	var auditPublisher port.EventPublisher
+	if config.Audit.Enabled && profilingDb != nil {
+		auditRepository := MongoRepository.NewAuditRepository(profilingDb, "audit-logs")
+		if err := auditRepository.EnsureIndexes(ctx); err != nil {
+			fmt.Printf("Error creating audit indexes, audit trail is disabled: %v\n", err)
+		} else {
+			audit := service.NewAuditService(auditRepository)
+			go audit.Run(ctx, config.Audit.RetryInterval)
+			auditService = audit
+			auditPublisher = audit
+		}
+	}
+
+	// Only the MySQL store has an outbox, the others record audit entries after the change
+	productOutbox := false
+	var productRepository port.ProductRepository
+	switch config.DB.ProductStore {
+	case "mongo":
+		mongoProductRepository := MongoRepository.NewProductRepository(profilingDb, "products")
+		if err := mongoProductRepository.EnsureIndexes(ctx); err != nil {
+			fmt.Printf("Error creating product indexes: %v\n", err)
+			os.Exit(1)
+		}
+		productRepository = mongoProductRepository
+	case "memory":
+		productRepository = memory.NewProductRepository()
+	default:
+		productRepository = repository.NewProductRepository(mysqlDB.DB)
+		productOutbox = true
+	}
+
+	// Cache product reads in front of MySQL
+	var productCache http.CacheStatsReader
+	if config.Cache.Enabled {
+		cachedProductRepository := cache.NewProductRepository(productRepository, config.Cache.Capacity, config.Cache.TTL)
+		productRepository = cachedProductRepository
+		productCache = cachedProductRepository
+	}
+
+	lowStockNotifier, err := notifier.New(config.Notifier)
+	if err != nil {
+		fmt.Printf("Error initializing notifier: %v\n", err)
+		os.Exit(1)
+	}
+	// Audit entries of the MySQL store are written to its outbox with the change, so none is lost
+	auditOption := service.WithAuditService(auditService)
+	if auditService != nil && productOutbox {
+		auditOption = service.WithOutboxAudit()
+	}
+	productService := service.NewProductService(
+		productRepository,
+		service.WithNotifier(lowStockNotifier),
+		auditOption,
+		service.WithAuthorizer(authorizer),
+		service.WithProductRules(domain.ProductRules{
+			NameMaxLength: config.Product.NameMaxLength,
+			MaxPrice:      config.Product.MaxPrice,
+			MaxStock:      config.Product.MaxStock,
+		}),
+	)
+
+	// Init webhooks, deliveries are made in the background
+	var webhookService port.WebhookService
+	eventPublishers := []port.EventPublisher{publisher.NewLogPublisher()}
+	if config.Webhook.Enabled && profilingDb != nil {
+		webhookRepository := MongoRepository.NewWebhookRepository(profilingDb, "webhook-subscriptions", "webhook-deliveries")
+		if err := webhookRepository.EnsureIndexes(ctx); err != nil {
+			fmt.Printf("Error creating webhook indexes, webhooks are disabled: %v\n", err)
+		} else {
+			webhooks := service.NewWebhookService(
+				webhookRepository,
+				webhook.NewHTTPSender(config.Webhook.Timeout, config.Webhook.AllowPrivateNetworks),
+				service.WebhookRetryPolicy{
+					MaxAttempts:    config.Webhook.MaxAttempts,
+					InitialBackoff: config.Webhook.InitialBackoff,
+					MaxBackoff:     config.Webhook.MaxBackoff,
+				},
+				config.Webhook.BatchSize,
+			)
+			go webhooks.Run(ctx, config.Webhook.DispatchInterval)
+			webhookService = webhooks
+			eventPublishers = append(eventPublishers, webhooks)
+		}
+	}
+
+	// Relay product events from the outbox
+	outboxRepository := repository.NewOutboxRepository(mysqlDB.DB)
+	eventPublisher := publisher.NewFanoutPublisher(eventPublishers...)
+	var relayOptions []service.OutboxRelayOption
+	if auditPublisher != nil && productOutbox {
+		// Audit events have their own relay, so a failing webhook never retries or dead letters them
+		auditEvents := domain.EventSelector{Types: []domain.EventType{domain.EventAuditRecorded}}
+		auditRelay := service.NewOutboxRelay(outboxRepository, auditPublisher, config.Events.RelayInterval, config.Events.RelayBatchSize, config.Events.RelayMaxAttempts, service.WithRelayedEvents(auditEvents))
+		go auditRelay.Run(ctx)
 
-	productRepository := repository.NewProductRepository(mysqlDB.DB)
-	productService := service.NewProductService(productRepository)
+		auditEvents.Exclude = true
+		relayOptions = append(relayOptions, service.WithRelayedEvents(auditEvents))
+	}
+	outboxRelay := service.NewOutboxRelay(outboxRepository, eventPublisher, config.Events.RelayInterval, config.Events.RelayBatchSize, config.Events.RelayMaxAttempts, relayOptions...)
+	go outboxRelay.Run(ctx)
 
-	http.SetupRoutes(app, productService)
+	// Limit requests per client and route group
+	var rateLimiter *middleware.RateLimiter
+	if config.RateLimit.Enabled {
+		rateLimitStore := memory.NewRateLimitStore(config.RateLimit.Shards)
+		go rateLimitStore.Run(ctx, time.Minute)
+
+		groupLimits := make(map[string]domain.RateLimit)
+		for group, rule := range config.RateLimit.Groups {
+			groupLimits[group] = domain.RateLimit{Requests: rule.Requests, Period: rule.Period}
+		}
+		rateLimiter = middleware.NewRateLimiter(
+			rateLimitStore,
+			domain.RateLimit{Requests: config.RateLimit.Default.Requests, Period: config.RateLimit.Default.Period},
+			groupLimits,
+		)
+	}
+
+	// Replay responses of retried product writes
+	var idempotency fiber.Handler
+	if config.Idempotency.Enabled {
+		var idempotencyStore port.IdempotencyStore
+		switch config.Idempotency.Store {
+		case "mongo":
+			mongoStore := MongoRepository.NewIdempotencyStore(profilingDb, "idempotency-keys")
+			if err := mongoStore.EnsureIndexes(ctx); err != nil {
+				fmt.Printf("Error creating idempotency indexes: %v\n", err)
+				os.Exit(1)
+			}
+			idempotencyStore = mongoStore
+		default:
+			memoryStore := memory.NewIdempotencyStore()
+			go memoryStore.Run(ctx, time.Minute)
+			idempotencyStore = memoryStore
+		}
+		idempotency = middleware.Idempotency(idempotencyStore, config.Idempotency.TTL)
+	}
+
+	cacheControl := http.CacheControl{
+		Product:     config.HTTP.ProductCacheControl,
+		ProductList: config.HTTP.ProductListCacheControl,
+	}
+
+	http.SetupRoutes(app, http.RouteDependencies{
+		Authorizer:       authorizer,
+		RateLimiter:      rateLimiter,
+		Idempotency:      idempotency,
+		CacheControl:     cacheControl,
+		ProductService:   productService,
+		ProfilingService: profilingService,
+		WebhookService:   webhookService,
+		AuditService:     auditService,
+		APIKeyService:    apiKeyService,
+		ProfilingSpill:   profilingSpill,
+		ProfilingRollups: profilingRollups,
+		ProductCache:     productCache,
+	})
 
 	port := config.HTTP.Port
 	if port == "" {
@@ -65,7 +372,25 @@ func main() {
 	}
 	fmt.Printf("Starting server on port %s\n", port)
 
-	if err := app.Listen(":" + port); err != nil {
-		log.Fatalf("Error starting server: %v\n", err)
+	go func() {
+		if err := app.Listen(":" + port); err != nil {
+			log.Fatalf("Error starting server: %v\n", err)
+		}
+	}()
+
+	// Stop accepting requests on SIGINT or SIGTERM, then flush the queued profiling
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-signalCtx.Done()
+
+	fmt.Println("Shutting down server")
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), config.Profiling.ShutdownTimeout)
+	defer shutdownCancel()
+
+	if err := app.ShutdownWithContext(shutdownCtx); err != nil {
+		fmt.Printf("Error shutting down server: %v\n", err)
+	}
+	if err := profilingService.Close(shutdownCtx); err != nil {
+		fmt.Printf("Error flushing profiling data: %v\n", err)
 	}
 }
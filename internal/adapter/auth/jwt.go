@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/config"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+)
+
+// Claims of the access token, besides the registered claims
+type Claims struct {
+	jwt.RegisteredClaims
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	TenantID    string   `json:"tenant_id,omitempty"`
+}
+
+/*
+ * JWT verifier validates HS256 and RS256 access tokens,
+ * RS256 keys are loaded from a PEM public key file or a local JWKS file
+ * and selected with the token "kid" header
+ */
+type JWTVerifier struct {
+	hmacSecret []byte
+	rsaKeys    map[string]*rsa.PublicKey
+	parser     *jwt.Parser
+}
+
+func NewJWTVerifier(config *config.JWT) (*JWTVerifier, error) {
+	verifier := &JWTVerifier{
+		rsaKeys: make(map[string]*rsa.PublicKey),
+	}
+
+	if config.HS256Secret != "" {
+		verifier.hmacSecret = []byte(config.HS256Secret)
+	}
+
+	if config.RS256PublicKeyFile != "" {
+		pem, err := os.ReadFile(config.RS256PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading RS256 public key: %w", err)
+		}
+		key, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing RS256 public key: %w", err)
+		}
+		verifier.rsaKeys[""] = key
+	}
+
+	if config.JWKSFile != "" {
+		keys, err := loadJWKS(config.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		for kid, key := range keys {
+			verifier.rsaKeys[kid] = key
+		}
+	}
+
+	if verifier.hmacSecret == nil && len(verifier.rsaKeys) == 0 {
+		return nil, errors.New("no JWT verification key is configured")
+	}
+
+	var methods []string
+	if verifier.hmacSecret != nil {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if len(verifier.rsaKeys) > 0 {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(config.ClockSkew),
+	}
+	if config.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		opts = append(opts, jwt.WithAudience(config.Audience))
+	}
+	verifier.parser = jwt.NewParser(opts...)
+
+	return verifier, nil
+}
+
+// Verify the token and return the principal it was issued for
+func (v *JWTVerifier) Verify(tokenString string) (*domain.Principal, error) {
+	var claims Claims
+	_, err := v.parser.ParseWithClaims(tokenString, &claims, v.key)
+	if err != nil {
+		log.Println("error when verifying access token:", err)
+		return nil, domain.ErrUnauthenticated
+	}
+
+	if claims.Subject == "" {
+		log.Println("error when verifying access token: missing subject")
+		return nil, domain.ErrUnauthenticated
+	}
+
+	return &domain.Principal{
+		Subject:     claims.Subject,
+		Type:        domain.PrincipalUser,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+		TenantID:    claims.TenantID,
+	}, nil
+}
+
+func (v *JWTVerifier) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		return v.hmacSecret, nil
+	case jwt.SigningMethodRS256.Alg():
+		kid, _ := token.Header["kid"].(string)
+		if key, ok := v.rsaKeys[kid]; ok {
+			return key, nil
+		}
+		// Fallback to the PEM key for tokens signed without kid
+		if key, ok := v.rsaKeys[""]; ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// Load RSA signing keys from a JWKS file, keys are indexed by kid
+func loadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading JWKS file: %w", err)
+	}
+
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("error parsing JWKS file: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding JWKS key %q modulus: %w", jwk.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding JWKS key %q exponent: %w", jwk.Kid, err)
+		}
+
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
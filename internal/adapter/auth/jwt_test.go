@@ -0,0 +1,141 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/auth"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/config"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "test-hs256-secret"
+
+func signHS256(t *testing.T, claims auth.Claims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	require.NoError(t, err)
+	return token
+}
+
+func validClaims() auth.Claims {
+	return auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "alice",
+			Issuer:    "https://auth.example.com",
+			Audience:  jwt.ClaimStrings{"product-api"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Roles: []string{"editor"},
+	}
+}
+
+func newHS256Verifier(t *testing.T) *auth.JWTVerifier {
+	verifier, err := auth.NewJWTVerifier(&config.JWT{
+		HS256Secret: testSecret,
+		Issuer:      "https://auth.example.com",
+		Audience:    "product-api",
+		ClockSkew:   30 * time.Second,
+	})
+	require.NoError(t, err)
+	return verifier
+}
+
+/*
+ * Test JWT Verifier
+ * HS256 valid, clock skew, expired, wrong audience, wrong issuer, RS256 from JWKS
+ */
+func TestVerify_HS256Valid(t *testing.T) {
+	verifier := newHS256Verifier(t)
+
+	principal, err := verifier.Verify(signHS256(t, validClaims()))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", principal.Subject)
+	assert.Equal(t, domain.PrincipalUser, principal.Type)
+	assert.Equal(t, []string{"editor"}, principal.Roles)
+}
+
+func TestVerify_ExpiredWithinClockSkew(t *testing.T) {
+	verifier := newHS256Verifier(t)
+
+	claims := validClaims()
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-10 * time.Second))
+
+	_, err := verifier.Verify(signHS256(t, claims))
+
+	assert.NoError(t, err)
+}
+
+func TestVerify_Expired(t *testing.T) {
+	verifier := newHS256Verifier(t)
+
+	claims := validClaims()
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+
+	_, err := verifier.Verify(signHS256(t, claims))
+
+	assert.Equal(t, domain.ErrUnauthenticated, err)
+}
+
+func TestVerify_WrongAudienceAndIssuer(t *testing.T) {
+	verifier := newHS256Verifier(t)
+
+	wrongAudience := validClaims()
+	wrongAudience.Audience = jwt.ClaimStrings{"other-api"}
+	_, err := verifier.Verify(signHS256(t, wrongAudience))
+	assert.Equal(t, domain.ErrUnauthenticated, err)
+
+	wrongIssuer := validClaims()
+	wrongIssuer.Issuer = "https://evil.example.com"
+	_, err = verifier.Verify(signHS256(t, wrongIssuer))
+	assert.Equal(t, domain.ErrUnauthenticated, err)
+}
+
+func TestVerify_RS256FromJWKS(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwks, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes()),
+		}},
+	})
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, jwks, 0600))
+
+	verifier, err := auth.NewJWTVerifier(&config.JWT{JWKSFile: jwksFile, Audience: "product-api"})
+	require.NoError(t, err)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims())
+	token.Header["kid"] = "key-1"
+	signed, err := token.SignedString(privateKey)
+	require.NoError(t, err)
+
+	principal, err := verifier.Verify(signed)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", principal.Subject)
+
+	// HS256 is not accepted when only RSA keys are configured
+	_, err = verifier.Verify(signHS256(t, validClaims()))
+	assert.Equal(t, domain.ErrUnauthenticated, err)
+}
+
+func TestNewJWTVerifier_NoKey(t *testing.T) {
+	_, err := auth.NewJWTVerifier(&config.JWT{})
+
+	assert.Error(t, err)
+}
@@ -2,6 +2,9 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,6 +15,16 @@ type (
 		DB          *DB
 		ProfilingDB *ProfilingDB
 		HTTP        *HTTP
+		Notifier    *Notifier
+		Events      *Events
+		Webhook     *Webhook
+		Auth        *Auth
+		RateLimit   *RateLimit
+		Idempotency *Idempotency
+		Cache       *Cache
+		Product     *Product
+		Profiling   *Profiling
+		Audit       *Audit
 	}
 
 	App struct {
@@ -25,10 +38,19 @@ type (
 		User     string
 		Password string
 		Name     string
+		// Store of the products, "mysql" (default), "mongo" or "memory",
+		// only the MySQL store writes product events to the outbox
+		ProductStore string
 	}
 
 	ProfilingDB struct {
 		URI string
+		// Database of profiling and the other MongoDB collections
+		Database string
+		// Collection of the request profiling
+		Collection string
+		// MongoDB is skipped when it does not answer in time, see cmd/http
+		ConnectTimeout time.Duration
 	}
 
 	HTTP struct {
@@ -36,6 +58,135 @@ type (
 		URL            string
 		Port           string
 		AllowedOrigins string
+		// Cache-Control directives of single products and product lists
+		ProductCacheControl     string
+		ProductListCacheControl string
+	}
+
+	Notifier struct {
+		Type       string
+		FilePath   string
+		WebhookURL string
+		Timeout    time.Duration
+	}
+
+	Events struct {
+		RelayInterval  time.Duration
+		RelayBatchSize uint64
+		// Publish attempts of an event before it is dead lettered
+		RelayMaxAttempts int
+	}
+
+	Webhook struct {
+		Enabled          bool
+		MaxAttempts      int
+		InitialBackoff   time.Duration
+		MaxBackoff       time.Duration
+		DispatchInterval time.Duration
+		BatchSize        int64
+		Timeout          time.Duration
+		// Deliver to loopback and private addresses, only for local development
+		AllowPrivateNetworks bool
+	}
+
+	Auth struct {
+		Enabled bool
+		// Api keys of machine clients, stored in MongoDB
+		APIKeysEnabled  bool
+		PublicRoutes    []string
+		RolePermissions map[string][]string
+		JWT             *JWT
+	}
+
+	// Audit trail of product changes, stored in MongoDB
+	Audit struct {
+		Enabled bool
+		// Entries that failed to be stored are retried this often
+		RetryInterval time.Duration
+	}
+
+	RateLimit struct {
+		Enabled bool
+		Shards  int
+		Default RateLimitRule
+		// Limit per route group, e.g. "products"
+		Groups map[string]RateLimitRule
+	}
+
+	RateLimitRule struct {
+		Requests int
+		Period   time.Duration
+	}
+
+	Idempotency struct {
+		Enabled bool
+		// Either "memory" or "mongo"
+		Store string
+		TTL   time.Duration
+	}
+
+	Cache struct {
+		Enabled  bool
+		Capacity int
+		TTL      time.Duration
+	}
+
+	// Business rules of products, zero maximums are not enforced
+	Product struct {
+		NameMaxLength int
+		MaxPrice      int
+		MaxStock      int
+	}
+
+	// Background writer of the request profiling
+	Profiling struct {
+		Workers       int
+		QueueSize     int
+		BatchSize     int
+		FlushInterval time.Duration
+		// Either "drop_newest" or "drop_oldest", applied when the queue is full
+		DropPolicy      string
+		ShutdownTimeout time.Duration
+		// Share of the requests stored (0 to 1), failed (4xx, 5xx) and slow requests are always stored
+		SampleRate    float64
+		SlowThreshold time.Duration
+		// Route patterns like "GET /products/*", all routes are included when empty
+		IncludeRoutes []string
+		ExcludeRoutes []string
+		// Records older than this are removed by a TTL index, kept forever when zero
+		Retention time.Duration
+		// Where records are written: "mongo", "stdout", "file", "ring" or "noop"
+		Sinks []string
+		// Rotated to FilePath.1 and so on when it would exceed FileMaxSize bytes
+		FilePath       string
+		FileMaxSize    int64
+		FileMaxBackups int
+		// Latest records kept by the ring sink
+		RingCapacity int
+		// Batches the mongo sink fails to write are spilled to local files and replayed
+		SpillEnabled        bool
+		SpillDir            string
+		SpillSegmentSize    int64
+		SpillMaxSize        int64
+		SpillReplayInterval time.Duration
+		SpillReplayTimeout  time.Duration
+		// Hourly and daily rollups of the mongo sink, the first run looks back over RollupLookback
+		RollupEnabled  bool
+		RollupInterval time.Duration
+		RollupLookback time.Duration
+		// Database statements of a request, flagged when slow or repeated often enough to be N+1
+		SlowQueryThreshold time.Duration
+		NPlusOneThreshold  int
+		MaxQueries         int
+	}
+
+	JWT struct {
+		HS256Secret        string
+		RS256PublicKeyFile string
+		JWKSFile           string
+		Issuer             string
+		Audience           string
+		ClockSkew          time.Duration
 	}
 )
 
@@ -53,15 +204,20 @@ func New() (*Container, error) {
 	}
 
 	db := &DB{
-		Host:     os.Getenv("DB_HOST"),
-		Port:     os.Getenv("DB_PORT"),
-		User:     os.Getenv("DB_USER"),
-		Password: os.Getenv("DB_PASSWORD"),
-		Name:     os.Getenv("DB_NAME"),
+		Host:         os.Getenv("DB_HOST"),
+		Port:         os.Getenv("DB_PORT"),
+		User:         os.Getenv("DB_USER"),
+		Password:     os.Getenv("DB_PASSWORD"),
+		Name:         os.Getenv("DB_NAME"),
+		ProductStore: getEnv("PRODUCT_STORE", "mysql"),
 	}
 
 	profilingDB := &ProfilingDB{
-		URI: os.Getenv("MONGODB_URI"),
+		URI:        os.Getenv("MONGODB_URI"),
+		Database:   getEnv("MONGODB_DATABASE", "product-management"),
+		Collection: getEnv("PROFILING_COLLECTION", "request-logs"),
+
+		ConnectTimeout: getEnvDuration("MONGODB_CONNECT_TIMEOUT", 10*time.Second),
 	}
 
 	http := &HTTP{
@@ -69,6 +225,123 @@ func New() (*Container, error) {
 		URL:            os.Getenv("HTTP_URL"),
 		Port:           os.Getenv("HTTP_PORT"),
 		AllowedOrigins: os.Getenv("HTTP_ALLOWED_ORIGINS"),
+
+		ProductCacheControl:     getEnv("HTTP_PRODUCT_CACHE_CONTROL", "private, no-cache"),
+		ProductListCacheControl: getEnv("HTTP_PRODUCT_LIST_CACHE_CONTROL", "private, max-age=5"),
+	}
+
+	notifier := &Notifier{
+		Type:       os.Getenv("NOTIFIER_TYPE"),
+		FilePath:   os.Getenv("NOTIFIER_FILE_PATH"),
+		WebhookURL: os.Getenv("NOTIFIER_WEBHOOK_URL"),
+		Timeout:    getEnvDuration("NOTIFIER_TIMEOUT", 5*time.Second),
+	}
+
+	events := &Events{
+		RelayInterval:  getEnvDuration("EVENTS_RELAY_INTERVAL", time.Second),
+		RelayBatchSize: uint64(getEnvInt("EVENTS_RELAY_BATCH_SIZE", 100)),
+
+		RelayMaxAttempts: getEnvInt("EVENTS_RELAY_MAX_ATTEMPTS", 10),
+	}
+
+	webhook := &Webhook{
+		Enabled:          getEnvBool("WEBHOOK_ENABLED", true),
+		MaxAttempts:      getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		InitialBackoff:   getEnvDuration("WEBHOOK_INITIAL_BACKOFF", 5*time.Second),
+		MaxBackoff:       getEnvDuration("WEBHOOK_MAX_BACKOFF", 10*time.Minute),
+		DispatchInterval: getEnvDuration("WEBHOOK_DISPATCH_INTERVAL", 2*time.Second),
+		BatchSize:        int64(getEnvInt("WEBHOOK_BATCH_SIZE", 50)),
+		Timeout:          getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+
+		AllowPrivateNetworks: getEnvBool("WEBHOOK_ALLOW_PRIVATE_NETWORKS", false),
+	}
+
+	auth := &Auth{
+		Enabled:         getEnvBool("AUTH_ENABLED", false),
+		APIKeysEnabled:  getEnvBool("AUTH_API_KEYS_ENABLED", true),
+		PublicRoutes:    getEnvList("AUTH_PUBLIC_ROUTES"),
+		RolePermissions: getEnvListMap("AUTH_ROLE_PERMISSIONS"),
+		JWT: &JWT{
+			HS256Secret:        os.Getenv("JWT_HS256_SECRET"),
+			RS256PublicKeyFile: os.Getenv("JWT_RS256_PUBLIC_KEY_FILE"),
+			JWKSFile:           os.Getenv("JWT_JWKS_FILE"),
+			Issuer:             os.Getenv("JWT_ISSUER"),
+			Audience:           os.Getenv("JWT_AUDIENCE"),
+			ClockSkew:          getEnvDuration("JWT_CLOCK_SKEW", 30*time.Second),
+		},
+	}
+
+	rateLimit := &RateLimit{
+		Enabled: getEnvBool("RATE_LIMIT_ENABLED", false),
+		Shards:  getEnvInt("RATE_LIMIT_SHARDS", 32),
+		Default: parseRateLimitRule(os.Getenv("RATE_LIMIT_DEFAULT"), RateLimitRule{Requests: 100, Period: time.Minute}),
+		Groups:  make(map[string]RateLimitRule),
+	}
+	for group, rules := range getEnvListMap("RATE_LIMIT_GROUPS") {
+		if len(rules) == 1 {
+			rateLimit.Groups[group] = parseRateLimitRule(rules[0], rateLimit.Default)
+		}
+	}
+
+	idempotency := &Idempotency{
+		Enabled: getEnvBool("IDEMPOTENCY_ENABLED", true),
+		Store:   os.Getenv("IDEMPOTENCY_STORE"),
+		TTL:     getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+	}
+
+	cache := &Cache{
+		Enabled:  getEnvBool("CACHE_ENABLED", false),
+		Capacity: getEnvInt("CACHE_CAPACITY", 1000),
+		TTL:      getEnvDuration("CACHE_TTL", 30*time.Second),
+	}
+
+	audit := &Audit{
+		Enabled: getEnvBool("AUDIT_ENABLED", true),
+
+		RetryInterval: getEnvDuration("AUDIT_RETRY_INTERVAL", 30*time.Second),
+	}
+
+	product := &Product{
+		NameMaxLength: getEnvInt("PRODUCT_NAME_MAX_LENGTH", 255),
+		MaxPrice:      getEnvInt("PRODUCT_MAX_PRICE", 0),
+		MaxStock:      getEnvInt("PRODUCT_MAX_STOCK", 0),
+	}
+
+	profiling := &Profiling{
+		Workers:         getEnvInt("PROFILING_WORKERS", 2),
+		QueueSize:       getEnvInt("PROFILING_QUEUE_SIZE", 10000),
+		BatchSize:       getEnvInt("PROFILING_BATCH_SIZE", 200),
+		FlushInterval:   getEnvDuration("PROFILING_FLUSH_INTERVAL", time.Second),
+		DropPolicy:      getEnv("PROFILING_DROP_POLICY", "drop_newest"),
+		ShutdownTimeout: getEnvDuration("PROFILING_SHUTDOWN_TIMEOUT", 10*time.Second),
+		SampleRate:      getEnvFloat("PROFILING_SAMPLE_RATE", 1),
+		SlowThreshold:   getEnvDuration("PROFILING_SLOW_THRESHOLD", 500*time.Millisecond),
+		IncludeRoutes:   getEnvList("PROFILING_INCLUDE_ROUTES"),
+		ExcludeRoutes:   getEnvList("PROFILING_EXCLUDE_ROUTES"),
+		Retention:       getEnvDuration("PROFILING_RETENTION", 30*24*time.Hour),
+		Sinks:           getEnvList("PROFILING_SINKS"),
+		FilePath:        getEnv("PROFILING_FILE_PATH", "profiling.log"),
+		FileMaxSize:     int64(getEnvInt("PROFILING_FILE_MAX_SIZE", 10<<20)),
+		FileMaxBackups:  getEnvInt("PROFILING_FILE_MAX_BACKUPS", 5),
+		RingCapacity:    getEnvInt("PROFILING_RING_CAPACITY", 10000),
+
+		SpillEnabled:        getEnvBool("PROFILING_SPILL_ENABLED", true),
+		SpillDir:            getEnv("PROFILING_SPILL_DIR", "profiling-spill"),
+		SpillSegmentSize:    int64(getEnvInt("PROFILING_SPILL_SEGMENT_SIZE", 1<<20)),
+		SpillMaxSize:        int64(getEnvInt("PROFILING_SPILL_MAX_SIZE", 100<<20)),
+		SpillReplayInterval: getEnvDuration("PROFILING_SPILL_REPLAY_INTERVAL", 30*time.Second),
+		SpillReplayTimeout:  getEnvDuration("PROFILING_SPILL_REPLAY_TIMEOUT", 10*time.Second),
+
+		RollupEnabled:  getEnvBool("PROFILING_ROLLUP_ENABLED", true),
+		RollupInterval: getEnvDuration("PROFILING_ROLLUP_INTERVAL", 5*time.Minute),
+		RollupLookback: getEnvDuration("PROFILING_ROLLUP_LOOKBACK", 24*time.Hour),
+
+		SlowQueryThreshold: getEnvDuration("PROFILING_SLOW_QUERY_THRESHOLD", 100*time.Millisecond),
+		NPlusOneThreshold:  getEnvInt("PROFILING_N_PLUS_ONE_THRESHOLD", 5),
+		MaxQueries:         getEnvInt("PROFILING_MAX_QUERIES", 50),
+	}
+	if len(profiling.Sinks) == 0 {
+		profiling.Sinks = []string{"mongo"}
 	}
 
 	return &Container{
@@ -76,5 +349,108 @@ func New() (*Container, error) {
 		db,
 		profilingDB,
 		http,
+		notifier,
+		events,
+		webhook,
+		auth,
+		rateLimit,
+		idempotency,
+		cache,
+		product,
+		profiling,
+		audit,
 	}, nil
 }
+
+// Read a string from env var, fallback is used when unset
+func getEnv(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// Read a duration (e.g. "5s") from env var, fallback is used when unset or invalid
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// Read an integer from env var, fallback is used when unset or invalid
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// Read a float from env var, fallback is used when unset or invalid
+func getEnvFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// Read a boolean from env var, fallback is used when unset or invalid
+func getEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// Read a comma separated list from env var, empty items are skipped
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Read "key=a,b;other=c" from env var into a map of lists, keys without "=" are skipped
+func getEnvListMap(key string) map[string][]string {
+	values := make(map[string][]string)
+	for _, entry := range strings.Split(os.Getenv(key), ";") {
+		name, list, found := strings.Cut(entry, "=")
+		if name = strings.TrimSpace(name); !found || name == "" {
+			continue
+		}
+
+		var items []string
+		for _, item := range strings.Split(list, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		values[name] = items
+	}
+	return values
+}
+
+// Parse a rate limit rule like "100/1m", fallback is used when empty or invalid
+func parseRateLimitRule(value string, fallback RateLimitRule) RateLimitRule {
+	requests, period, found := strings.Cut(value, "/")
+	if !found {
+		return fallback
+	}
+
+	rule := RateLimitRule{}
+	var err error
+	if rule.Requests, err = strconv.Atoi(strings.TrimSpace(requests)); err != nil {
+		return fallback
+	}
+	if rule.Period, err = time.ParseDuration(strings.TrimSpace(period)); err != nil {
+		return fallback
+	}
+	return rule
+}
@@ -1,13 +1,33 @@
 package dto
 
+import "time"
+
 type CreateProductRequest struct {
-	Name  string `json:"name" validate:"required,min=1"`
-	Stock int    `json:"stock" validate:"required,min=0"`
-	Price int    `json:"price" validate:"required,gt=0"`
+	Name             string `json:"name" xml:"name" form:"name" validate:"required,min=1"`
+	Stock            int    `json:"stock" xml:"stock" form:"stock" validate:"required,min=0"`
+	Price            int    `json:"price" xml:"price" form:"price" validate:"required,gt=0"`
+	ReorderThreshold int    `json:"reorder_threshold" xml:"reorder_threshold" form:"reorder_threshold" validate:"min=0"`
 }
 
 type UpdateProductRequest struct {
-	Name  string `json:"name" validate:"required,min=1"`
-	Stock int    `json:"stock" validate:"required,min=0"`
-	Price int    `json:"price" validate:"required,gt=0"`
+	Name             string `json:"name" xml:"name" form:"name" validate:"required,min=1"`
+	Stock            int    `json:"stock" xml:"stock" form:"stock" validate:"required,min=0"`
+	Price            int    `json:"price" xml:"price" form:"price" validate:"required,gt=0"`
+	ReorderThreshold int    `json:"reorder_threshold" xml:"reorder_threshold" form:"reorder_threshold" validate:"min=0"`
+}
+
+type AdjustStockRequest struct {
+	Delta int `json:"delta" xml:"delta" form:"delta" validate:"required"`
+}
+
+type CreateWebhookRequest struct {
+	URL        string   `json:"url" validate:"required,http_url"`
+	Secret     string   `json:"secret" validate:"required,min=16"`
+	EventTypes []string `json:"event_types" validate:"required,min=1,dive,oneof=ProductCreated ProductUpdated ProductDeleted StockAdjusted"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name        string     `json:"name" validate:"required,min=1"`
+	Permissions []string   `json:"permissions" validate:"required,min=1,dive,required"`
+	ExpiresAt   *time.Time `json:"expires_at"`
 }
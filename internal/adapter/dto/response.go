@@ -0,0 +1,9 @@
+package dto
+
+import "github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+
+// Issued API key, the secret is only returned in this response
+type IssuedAPIKeyResponse struct {
+	domain.APIKey
+	Secret string `json:"secret"`
+}
@@ -1,9 +1,12 @@
 package dto
 
+import "encoding/xml"
+
 type WebResponse[T any] struct {
-	Total   *int64 `json:"total,omitempty"`
-	Data    T      `json:"data,omitempty"`
-	Message string `json:"message"`
+	XMLName xml.Name `json:"-" xml:"response"`
+	Total   *int64   `json:"total,omitempty" xml:"total,omitempty"`
+	Data    T        `json:"data,omitempty" xml:"data>product,omitempty"`
+	Message string   `json:"message" xml:"message"`
 }
 
 func NewWebResponse[T any](data T, message string, total *int64) *WebResponse[T] {
@@ -0,0 +1,70 @@
+package http
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/dto"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/middleware"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+/*
+ * Wrapper for api key handler,
+ * It holds api key service port to issue and revoke keys of machine clients
+ */
+type APIKeyHandler struct {
+	svc port.APIKeyService
+}
+
+func NewAPIKeyHandler(svc port.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		svc,
+	}
+}
+
+func (ah *APIKeyHandler) IssueAPIKey(c *fiber.Ctx) error {
+	req, err := middleware.Validated[dto.CreateAPIKeyRequest](c)
+	if err != nil {
+		return err
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return problem.Validation(map[string]string{"expires_at": "Expiry time must be in the future"})
+	}
+
+	key, secret, err := ah.svc.IssueAPIKey(c.UserContext(), req.Name, req.Permissions, req.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(dto.NewWebResponse(
+		dto.IssuedAPIKeyResponse{APIKey: *key, Secret: secret},
+		"Successfully issued api key, store the secret now as it is not shown again",
+		nil,
+	))
+}
+
+func (ah *APIKeyHandler) GetAPIKeys(c *fiber.Ctx) error {
+	keys, err := ah.svc.GetAPIKeys(c.UserContext())
+	if err != nil {
+		return err
+	}
+
+	totalCount := int64(len(keys))
+	return c.Status(fiber.StatusOK).JSON(dto.NewWebResponse(
+		keys,
+		"Api keys successfully fetched",
+		&totalCount,
+	))
+}
+
+func (ah *APIKeyHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	err := ah.svc.RevokeAPIKey(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
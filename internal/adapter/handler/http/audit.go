@@ -0,0 +1,65 @@
+package http
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/dto"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+/*
+ * Wrapper for audit handler,
+ * It holds audit service port to read the audit trail
+ */
+type AuditHandler struct {
+	svc port.AuditService
+}
+
+func NewAuditHandler(svc port.AuditService) *AuditHandler {
+	return &AuditHandler{
+		svc,
+	}
+}
+
+func (ah *AuditHandler) GetAuditEntries(c *fiber.Ctx) error {
+	filter := domain.AuditFilter{
+		ProductID: int64(c.QueryInt("product_id", 0)),
+		Actor:     c.Query("actor", ""),
+		Page:      int64(c.QueryInt("page", 1)),
+		Limit:     int64(c.QueryInt("limit", 10)),
+	}
+
+	var err error
+	if filter.From, filter.To, err = queryTimeRange(c); err != nil {
+		return err
+	}
+
+	entries, totalCount, err := ah.svc.GetAuditEntries(c.UserContext(), filter)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewWebResponse(
+		entries,
+		"Audit entries successfully fetched",
+		&totalCount,
+	))
+}
+
+// Optional "from" and "to" query times in RFC3339 format
+func queryTimeRange(c *fiber.Ctx) (from time.Time, to time.Time, err error) {
+	if value := c.Query("from"); value != "" {
+		if from, err = time.Parse(time.RFC3339, value); err != nil {
+			return from, to, problem.New(fiber.StatusBadRequest, problem.CodeInvalidQuery, "Invalid from time, expected RFC3339 format")
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = time.Parse(time.RFC3339, value); err != nil {
+			return from, to, problem.New(fiber.StatusBadRequest, problem.CodeInvalidQuery, "Invalid to time, expected RFC3339 format")
+		}
+	}
+	return from, to, nil
+}
@@ -0,0 +1,33 @@
+package http
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/dto"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+)
+
+type CacheStatsReader interface {
+	Stats() domain.CacheStats
+}
+
+/*
+ * Wrapper for cache handler,
+ * It exposes the counters of the product cache
+ */
+type CacheHandler struct {
+	cache CacheStatsReader
+}
+
+func NewCacheHandler(cache CacheStatsReader) *CacheHandler {
+	return &CacheHandler{
+		cache,
+	}
+}
+
+func (ch *CacheHandler) GetStats(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(dto.NewWebResponse(
+		ch.cache.Stats(),
+		"Cache stats successfully fetched",
+		nil,
+	))
+}
@@ -1,11 +1,12 @@
 package http
 
 import (
-	"errors"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/dto"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/middleware"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
 )
@@ -25,31 +26,24 @@ func NewProductHandler(svc port.ProductService) *ProductHandler {
 }
 
 func (ph *ProductHandler) CreateProduct(c *fiber.Ctx) error {
-	var req dto.CreateProductRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(dto.NewWebResponse[interface{}](
-			nil,
-			"Invalid request payload",
-			nil,
-		))
+	req, err := middleware.Validated[dto.CreateProductRequest](c)
+	if err != nil {
+		return err
 	}
 
 	product := domain.Product{
-		Name:  req.Name,
-		Stock: req.Stock,
-		Price: req.Price,
+		Name:             req.Name,
+		Stock:            req.Stock,
+		Price:            req.Price,
+		ReorderThreshold: req.ReorderThreshold,
 	}
 
-	createdProduct, err := ph.svc.CreateProduct(c.Context(), &product)
+	createdProduct, err := ph.svc.CreateProduct(c.UserContext(), &product)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewWebResponse[interface{}](
-			nil,
-			"Failed to create product",
-			nil,
-		))
+		return err
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(dto.NewWebResponse[domain.Product](
+	return render(c.Status(fiber.StatusCreated), dto.NewWebResponse[domain.Product](
 		*createdProduct,
 		"Successfully created product",
 		nil,
@@ -60,78 +54,68 @@ func (ph *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 	id := c.Params("id")
 	objID, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(dto.NewWebResponse[interface{}](
-			nil,
-			"Invalid product ID",
-			nil,
-		))
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidProductID, "Invalid product ID")
 	}
 
-	var req dto.UpdateProductRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(dto.NewWebResponse[interface{}](
-			nil,
-			"Invalid request payload",
-			nil,
-		))
+	req, err := middleware.Validated[dto.UpdateProductRequest](c)
+	if err != nil {
+		return err
 	}
 
 	product := domain.Product{
-		ID:    objID,
-		Name:  req.Name,
-		Stock: req.Stock,
-		Price: req.Price,
+		ID:               objID,
+		Name:             req.Name,
+		Stock:            req.Stock,
+		Price:            req.Price,
+		ReorderThreshold: req.ReorderThreshold,
 	}
 
-	updatedProduct, err := ph.svc.UpdateProduct(c.Context(), &product)
+	updatedProduct, err := ph.svc.UpdateProduct(c.UserContext(), &product)
 	if err != nil {
-		if errors.Is(err, domain.ErrProductNotFound) {
-			return c.Status(fiber.StatusNotFound).JSON(dto.NewWebResponse[interface{}](
-				nil,
-				"Product not found",
-				nil,
-			))
-		}
-		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewWebResponse[interface{}](
-			nil,
-			"Failed to update product",
-			nil,
-		))
-	}
-
-	return c.Status(fiber.StatusOK).JSON(dto.NewWebResponse(
+		return err
+	}
+
+	return render(c.Status(fiber.StatusOK), dto.NewWebResponse(
 		*updatedProduct,
 		"Product successfully updated",
 		nil,
 	))
 }
 
-func (ph *ProductHandler) DeleteProduct(c *fiber.Ctx) error {
+func (ph *ProductHandler) AdjustStock(c *fiber.Ctx) error {
 	idStr := c.Params("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(dto.NewWebResponse[interface{}](
-			nil,
-			"Invalid product ID",
-			nil,
-		))
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidProductID, "Invalid product ID")
+	}
+
+	req, err := middleware.Validated[dto.AdjustStockRequest](c)
+	if err != nil {
+		return err
 	}
 
-	err = ph.svc.DeleteProduct(c.Context(), id)
+	adjustedProduct, err := ph.svc.AdjustStock(c.UserContext(), id, req.Delta)
+	if err != nil {
+		return err
+	}
+
+	return render(c.Status(fiber.StatusOK), dto.NewWebResponse(
+		*adjustedProduct,
+		"Product stock successfully adjusted",
+		nil,
+	))
+}
+
+func (ph *ProductHandler) DeleteProduct(c *fiber.Ctx) error {
+	idStr := c.Params("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		if errors.Is(err, domain.ErrProductNotFound) {
-			return c.Status(fiber.StatusNotFound).JSON(dto.NewWebResponse[interface{}](
-				nil,
-				"Product not found",
-				nil,
-			))
-		}
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidProductID, "Invalid product ID")
+	}
 
-		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewWebResponse[interface{}](
-			nil,
-			"Failed to delete product",
-			nil,
-		))
+	err = ph.svc.DeleteProduct(c.UserContext(), id)
+	if err != nil {
+		return err
 	}
 
 	return c.SendStatus(fiber.StatusNoContent)
@@ -148,50 +132,46 @@ func (ph *ProductHandler) GetProducts(c *fiber.Ctx) error {
 	pageUint64 := uint64(page)
 	limitUint64 := uint64(limit)
 
-	products, totalCount, err := ph.svc.GetProducts(c.Context(), pageUint64, limitUint64, name, stock, price, sortBy)
+	products, totalCount, err := ph.svc.GetProducts(c.UserContext(), pageUint64, limitUint64, name, stock, price, sortBy)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewWebResponse[interface{}](
-			nil,
-			"Failed to fetch products",
-			nil,
-		))
+		return err
 	}
 
-	return c.Status(fiber.StatusOK).JSON(dto.NewWebResponse(
+	return render(c.Status(fiber.StatusOK), dto.NewWebResponse(
 		products,
 		"Products successfully fetched",
 		&totalCount,
 	))
 }
 
+func (ph *ProductHandler) GetLowStockProducts(c *fiber.Ctx) error {
+	products, err := ph.svc.GetLowStockProducts(c.UserContext())
+	if err != nil {
+		return err
+	}
+
+	totalCount := int64(len(products))
+	return render(c.Status(fiber.StatusOK), dto.NewWebResponse(
+		products,
+		"Low stock products successfully fetched",
+		&totalCount,
+	))
+}
+
 func (ph *ProductHandler) GetProductById(c *fiber.Ctx) error {
 	idStr := c.Params("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(dto.NewWebResponse[interface{}](
-			nil,
-			"Invalid product ID",
-			nil,
-		))
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidProductID, "Invalid product ID")
 	}
 
-	product, err := ph.svc.GetProductById(c.Context(), id)
+	product, err := ph.svc.GetProductById(c.UserContext(), id)
 	if err != nil {
-		if errors.Is(err, domain.ErrProductNotFound) {
-			return c.Status(fiber.StatusNotFound).JSON(dto.NewWebResponse[interface{}](
-				nil,
-				"Product not found",
-				nil,
-			))
-		}
-		return c.Status(fiber.StatusInternalServerError).JSON(dto.NewWebResponse[interface{}](
-			nil,
-			"Failed to fetch product",
-			nil,
-		))
-	}
-
-	return c.Status(fiber.StatusOK).JSON(dto.NewWebResponse(
+		return err
+	}
+
+	middleware.SetLastModified(c, product.UpdatedAt)
+	return render(c.Status(fiber.StatusOK), dto.NewWebResponse(
 		product,
 		"Product successfully fetched",
 		nil,
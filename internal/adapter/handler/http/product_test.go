@@ -4,12 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"encoding/xml"
+	"io"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/dto"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/handler/http"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/middleware"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -42,6 +47,19 @@ func (m *MockProductService) GetProducts(ctx context.Context, page uint64, limit
 	return args.Get(0).([]domain.Product), args.Get(1).(int64), args.Error(2)
 }
 
+func (m *MockProductService) GetLowStockProducts(ctx context.Context) ([]domain.Product, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]domain.Product), args.Error(1)
+}
+
+func (m *MockProductService) AdjustStock(ctx context.Context, id int64, delta int) (*domain.Product, error) {
+	args := m.Called(ctx, id, delta)
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Product), nil
+}
+
 func (m *MockProductService) GetProductById(ctx context.Context, id int64) (*domain.Product, error) {
 	args := m.Called(ctx, id)
 	if args.Error(1) != nil {
@@ -51,12 +69,14 @@ func (m *MockProductService) GetProductById(ctx context.Context, id int64) (*dom
 }
 
 func setupApp(handler *http.ProductHandler) *fiber.App {
-	app := fiber.New()
-	app.Post("/products", handler.CreateProduct)
-	app.Put("/products/:id", handler.UpdateProduct)
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Post("/products", middleware.ValidationMiddleware(dto.CreateProductRequest{}), handler.CreateProduct)
+	app.Put("/products/:id", middleware.ValidationMiddleware(dto.UpdateProductRequest{}), handler.UpdateProduct)
 	app.Delete("/products/:id", handler.DeleteProduct)
 	app.Get("/products", handler.GetProducts)
+	app.Get("/products/low-stock", handler.GetLowStockProducts)
 	app.Get("/products/:id", handler.GetProductById)
+	app.Post("/products/:id/stock", middleware.ValidationMiddleware(dto.AdjustStockRequest{}), handler.AdjustStock)
 	return app
 }
 
@@ -99,6 +119,29 @@ func TestCreateProduct_Success(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestCreateProduct_AlreadyExists(t *testing.T) {
+	mockService := new(MockProductService)
+	handler := http.NewProductHandler(mockService)
+
+	mockService.On("CreateProduct", mock.Anything, mock.AnythingOfType("*domain.Product")).
+		Return((*domain.Product)(nil), domain.ErrProductAlreadyExists)
+
+	app := setupApp(handler)
+	requestBytes, _ := json.Marshal(dto.CreateProductRequest{Name: "Test Product", Stock: 10, Price: 100})
+	req := httptest.NewRequest("POST", "/products", bytes.NewBuffer(requestBytes))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+
+	var response problem.Problem
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, problem.CodeProductExists, response.Code)
+}
+
 /*
  * Test Get Product By Id
  * Success, Product Not Found
@@ -142,12 +185,15 @@ func TestGetProductById_NotFound(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
 
-	var response dto.WebResponse[interface{}]
+	assert.Equal(t, problem.ContentType, resp.Header.Get("Content-Type"))
+
+	var response problem.Problem
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		t.Fatal(err)
 	}
 
-	assert.Equal(t, "Product not found", response.Message)
+	assert.Equal(t, problem.CodeProductNotFound, response.Code)
+	assert.Equal(t, "product not found", response.Detail)
 
 	mockService.AssertExpectations(t)
 }
@@ -345,13 +391,13 @@ func TestUpdateProduct_NotFound(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
 
-	var response dto.WebResponse[interface{}]
+	var response problem.Problem
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		t.Fatal(err)
 	}
 
-	assert.Nil(t, response.Data)
-	assert.Equal(t, "Product not found", response.Message)
+	assert.Equal(t, fiber.StatusNotFound, response.Status)
+	assert.Equal(t, problem.CodeProductNotFound, response.Code)
 
 	mockService.AssertExpectations(t)
 }
@@ -395,13 +441,179 @@ func TestDeleteProduct_NotFound(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
 
-	var response dto.WebResponse[interface{}]
+	var response problem.Problem
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, fiber.StatusNotFound, response.Status)
+	assert.Equal(t, problem.CodeProductNotFound, response.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+/*
+ * Test Low Stock Products and Adjust Stock
+ * Success, Insufficient Stock
+ */
+func TestGetLowStockProducts_Success(t *testing.T) {
+	mockService := new(MockProductService)
+	handler := http.NewProductHandler(mockService)
+
+	products := []domain.Product{
+		{ID: 1, Name: "Product 1", Stock: 2, Price: 100, ReorderThreshold: 5},
+	}
+	mockService.On("GetLowStockProducts", mock.Anything).Return(products, nil)
+
+	app := setupApp(handler)
+	req := httptest.NewRequest("GET", "/products/low-stock", nil)
+	resp, err := app.Test(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var response dto.WebResponse[[]domain.Product]
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		t.Fatal(err)
 	}
 
-	assert.Nil(t, response.Data)
-	assert.Equal(t, "Product not found", response.Message)
+	assert.Len(t, response.Data, 1)
+	assert.Equal(t, 5, response.Data[0].ReorderThreshold)
+	assert.Equal(t, int64(1), *response.Total)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestAdjustStock_InsufficientStock(t *testing.T) {
+	mockService := new(MockProductService)
+	handler := http.NewProductHandler(mockService)
+
+	mockService.On("AdjustStock", mock.Anything, int64(1), -20).Return(nil, domain.ErrInsufficientStock)
+
+	app := setupApp(handler)
+	requestBytes, _ := json.Marshal(dto.AdjustStockRequest{Delta: -20})
+	req := httptest.NewRequest("POST", "/products/1/stock", bytes.NewBuffer(requestBytes))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+
+	mockService.AssertExpectations(t)
+}
+
+/*
+ * Test Content Negotiation
+ * XML response, CSV list with formula-like names escaped,
+ * unsupported Accept, XML and form-encoded payloads
+ */
+func TestGetProductById_XML(t *testing.T) {
+	mockService := new(MockProductService)
+	handler := http.NewProductHandler(mockService)
+
+	product := &domain.Product{ID: 1, Name: "Test Product", Stock: 10, Price: 100}
+	mockService.On("GetProductById", mock.Anything, int64(1)).Return(product, nil)
+
+	app := setupApp(handler)
+	req := httptest.NewRequest("GET", "/products/1", nil)
+	req.Header.Set("Accept", "application/xml")
+	resp, err := app.Test(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "application/xml")
+
+	var response struct {
+		Product domain.Product `xml:"data>product"`
+		Message string         `xml:"message"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "Test Product", response.Product.Name)
+	assert.Equal(t, 10, response.Product.Stock)
+	assert.Equal(t, "Product successfully fetched", response.Message)
+}
+
+func TestGetProducts_CSV(t *testing.T) {
+	mockService := new(MockProductService)
+	handler := http.NewProductHandler(mockService)
+
+	products := []domain.Product{
+		{ID: 1, Name: "Samsung A1", Stock: 10, Price: 1000},
+		{ID: 2, Name: "Pixel, 8", Stock: 3, Price: 2000, ReorderThreshold: 5},
+		{ID: 3, Name: "=HYPERLINK(\"http://example.com\")", Stock: 1, Price: 10},
+		{ID: 4, Name: "@SUM(A1)", Stock: 1, Price: 10},
+	}
+	mockService.On("GetProducts", mock.Anything, uint64(1), uint64(10), "", "", "", "").Return(products, int64(4), nil)
+
+	app := setupApp(handler)
+	req := httptest.NewRequest("GET", "/products", nil)
+	req.Header.Set("Accept", "text/csv")
+	resp, err := app.Test(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/csv")
+
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "id,name,stock,price,reorder_threshold,updated_at\n"+
+		"1,Samsung A1,10,1000,0,\n"+
+		"2,\"Pixel, 8\",3,2000,5,\n"+
+		"3,\"'=HYPERLINK(\"\"http://example.com\"\")\",1,10,0,\n"+
+		"4,'@SUM(A1),1,10,0,\n", string(body))
+}
+
+func TestGetProducts_NotAcceptable(t *testing.T) {
+	mockService := new(MockProductService)
+	handler := http.NewProductHandler(mockService)
+
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Get("/products", middleware.Negotiate(http.ProductFormats...), handler.GetProducts)
+	req := httptest.NewRequest("GET", "/products", nil)
+	req.Header.Set("Accept", "application/pdf")
+	resp, err := app.Test(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotAcceptable, resp.StatusCode)
+	mockService.AssertNotCalled(t, "GetProducts", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateProduct_XMLPayload(t *testing.T) {
+	mockService := new(MockProductService)
+	handler := http.NewProductHandler(mockService)
+
+	product := &domain.Product{Name: "Test Product", Stock: 10, Price: 100, ReorderThreshold: 2}
+	mockService.On("CreateProduct", mock.Anything, product).Return(&domain.Product{ID: 1, Name: "Test Product", Stock: 10, Price: 100, ReorderThreshold: 2}, nil)
+
+	app := setupApp(handler)
+	body := `<product><name>Test Product</name><stock>10</stock><price>100</price><reorder_threshold>2</reorder_threshold></product>`
+	req := httptest.NewRequest("POST", "/products", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/xml")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateProduct_FormPayload(t *testing.T) {
+	mockService := new(MockProductService)
+	handler := http.NewProductHandler(mockService)
+
+	product := &domain.Product{Name: "Test Product", Stock: 10, Price: 100, ReorderThreshold: 2}
+	mockService.On("CreateProduct", mock.Anything, product).Return(&domain.Product{ID: 1, Name: "Test Product", Stock: 10, Price: 100, ReorderThreshold: 2}, nil)
+
+	app := setupApp(handler)
+	body := "name=Test+Product&stock=10&price=100&reorder_threshold=2"
+	req := httptest.NewRequest("POST", "/products", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
 
 	mockService.AssertExpectations(t)
 }
@@ -0,0 +1,127 @@
+package http
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/dto"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+/*
+ * Wrapper for profiling handler,
+ * It reads the request profiling and exposes the counters of the background writer
+ */
+type ProfilingHandler struct {
+	profilingService port.ProfilingService
+}
+
+func NewProfilingHandler(profilingService port.ProfilingService) *ProfilingHandler {
+	return &ProfilingHandler{
+		profilingService,
+	}
+}
+
+func (ph *ProfilingHandler) GetProfilings(c *fiber.Ctx) error {
+	filter, err := profilingFilter(c)
+	if err != nil {
+		return err
+	}
+
+	profilings, totalCount, err := ph.profilingService.GetProfilings(c.UserContext(), filter)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewWebResponse(
+		profilings,
+		"Profiling data successfully fetched",
+		&totalCount,
+	))
+}
+
+func (ph *ProfilingHandler) GetRouteStats(c *fiber.Ctx) error {
+	filter, err := profilingFilter(c)
+	if err != nil {
+		return err
+	}
+
+	stats, err := ph.profilingService.GetRouteStats(c.UserContext(), filter)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewWebResponse(
+		stats,
+		"Profiling stats successfully fetched",
+		nil,
+	))
+}
+
+func (ph *ProfilingHandler) GetWriterStats(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(dto.NewWebResponse(
+		ph.profilingService.Stats(),
+		"Profiling writer stats successfully fetched",
+		nil,
+	))
+}
+
+// Largest page of profiling records
+const maxProfilingLimit = 100
+
+func profilingFilter(c *fiber.Ctx) (domain.ProfilingFilter, error) {
+	filter := domain.ProfilingFilter{
+		Method: strings.ToUpper(c.Query("method", "")),
+		Route:  c.Query("route", ""),
+		Status: c.QueryInt("status", 0),
+		Page:   int64(c.QueryInt("page", 1)),
+		Limit:  int64(min(c.QueryInt("limit", 10), maxProfilingLimit)),
+	}
+
+	var err error
+	filter.From, filter.To, err = queryTimeRange(c)
+	return filter, err
+}
+
+/*
+ * Wrapper for profiling trend handler,
+ * It serves trend charts from the hourly and daily rollups
+ */
+type ProfilingTrendHandler struct {
+	rollupService port.ProfilingRollupService
+}
+
+func NewProfilingTrendHandler(rollupService port.ProfilingRollupService) *ProfilingTrendHandler {
+	return &ProfilingTrendHandler{
+		rollupService,
+	}
+}
+
+func (th *ProfilingTrendHandler) GetTrend(c *fiber.Ctx) error {
+	filter := domain.RollupFilter{
+		Granularity: domain.RollupGranularity(c.Query("granularity", string(domain.RollupHourly))),
+		Method:      strings.ToUpper(c.Query("method", "")),
+		Route:       c.Query("route", ""),
+	}
+	if filter.Granularity != domain.RollupHourly && filter.Granularity != domain.RollupDaily {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidQuery, "Invalid granularity, expected hour or day")
+	}
+
+	var err error
+	if filter.From, filter.To, err = queryTimeRange(c); err != nil {
+		return err
+	}
+
+	rollups, err := th.rollupService.GetTrend(c.UserContext(), filter)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewWebResponse(
+		rollups,
+		"Profiling trend successfully fetched",
+		nil,
+	))
+}
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/dto"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/middleware"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+)
+
+// Media types offered by the product endpoints, the first one is the default
+var ProductFormats = []string{fiber.MIMEApplicationJSON, fiber.MIMEApplicationXML, "text/csv"}
+
+/*
+ * Render the response in the media type chosen by the Negotiate middleware,
+ * when the route is not negotiated the Accept header is matched here
+ * and JSON is used for anything unsupported
+ */
+func render[T any](c *fiber.Ctx, response *dto.WebResponse[T]) error {
+	format, _ := c.Locals(middleware.FormatKey).(string)
+	if format == "" {
+		format = c.Accepts(ProductFormats...)
+	}
+
+	switch format {
+	case fiber.MIMEApplicationXML:
+		body, err := xml.Marshal(response)
+		if err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationXMLCharsetUTF8)
+		return c.Send(append([]byte(xml.Header), body...))
+	case "text/csv":
+		body, err := productsCSV(response.Data, response.Message)
+		if err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+		return c.Send(body)
+	default:
+		return c.JSON(response)
+	}
+}
+
+var productCSVHeader = []string{"id", "name", "stock", "price", "reorder_threshold", "updated_at"}
+
+// Products are written one per row, responses without products only carry their message
+func productsCSV(data interface{}, message string) ([]byte, error) {
+	var products []domain.Product
+	isProducts := true
+	switch v := data.(type) {
+	case domain.Product:
+		products = []domain.Product{v}
+	case *domain.Product:
+		products = []domain.Product{*v}
+	case []domain.Product:
+		products = v
+	default:
+		isProducts = false
+	}
+
+	rows := [][]string{{"message"}, {csvText(message)}}
+	if isProducts {
+		rows = [][]string{productCSVHeader}
+		for _, p := range products {
+			updatedAt := ""
+			if !p.UpdatedAt.IsZero() {
+				updatedAt = p.UpdatedAt.UTC().Format(time.RFC3339Nano)
+			}
+			rows = append(rows, []string{
+				strconv.FormatInt(p.ID, 10),
+				csvText(p.Name),
+				strconv.Itoa(p.Stock),
+				strconv.Itoa(p.Price),
+				strconv.Itoa(p.ReorderThreshold),
+				updatedAt,
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+/*
+ * Text cell that spreadsheets show as text, a value starting like a formula
+ * (=, +, -, @, or a tab or carriage return) is prefixed with a single quote
+ * so opening the export can't run a formula from a product name
+ */
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -4,20 +4,120 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/dto"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/middleware"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
 )
 
-func SetupRoutes(app *fiber.App, productService port.ProductService) {
-	productHandler := NewProductHandler(productService)
+// Cache-Control directives of the cacheable routes
+type CacheControl struct {
+	Product     string
+	ProductList string
+}
+
+/*
+ * Dependencies of the routes, the optional services are nil when their feature is disabled
+ * and their routes are not served. Idempotency is nil when retried writes are not deduplicated
+ */
+type RouteDependencies struct {
+	Authorizer   port.Authorizer
+	RateLimiter  *middleware.RateLimiter
+	Idempotency  fiber.Handler
+	CacheControl CacheControl
+
+	ProductService   port.ProductService
+	ProfilingService port.ProfilingService
+
+	WebhookService   port.WebhookService
+	AuditService     port.AuditService
+	APIKeyService    port.APIKeyService
+	ProfilingSpill   SpillStatsReader
+	ProfilingRollups port.ProfilingRollupService
+	ProductCache     CacheStatsReader
+}
+
+func SetupRoutes(app *fiber.App, deps RouteDependencies) {
+	productHandler := NewProductHandler(deps.ProductService)
+	profilingHandler := NewProfilingHandler(deps.ProfilingService)
+
+	can := func(permission domain.Permission) fiber.Handler {
+		return middleware.RequirePermission(deps.Authorizer, permission)
+	}
 
 	// Api for products
-	api := app.Group("/products")
+	// Products are served as JSON, XML or CSV depending on the Accept header
+	productMiddlewares := []fiber.Handler{deps.RateLimiter.Limit("products"), middleware.Negotiate(ProductFormats...)}
+	if deps.Idempotency != nil {
+		// Retried product writes replay the first response instead of writing twice
+		productMiddlewares = append(productMiddlewares, deps.Idempotency)
+	}
+	api := app.Group("/products", productMiddlewares...)
 
 	api.Post("",
+		can(domain.PermissionProductsCreate),
 		middleware.ValidationMiddleware(dto.CreateProductRequest{}),
 		productHandler.CreateProduct)
-	api.Get("", productHandler.GetProducts)
-	api.Get("/:id", productHandler.GetProductById)
-	api.Put("/:id", middleware.ValidationMiddleware(dto.UpdateProductRequest{}), productHandler.UpdateProduct)
-	api.Delete("/:id", productHandler.DeleteProduct)
+	productList := middleware.ConditionalGet(middleware.ConditionalGetConfig{Weak: true, CacheControl: deps.CacheControl.ProductList})
+	product := middleware.ConditionalGet(middleware.ConditionalGetConfig{CacheControl: deps.CacheControl.Product})
+
+	api.Get("", productList, productHandler.GetProducts)
+	api.Get("/low-stock", productList, productHandler.GetLowStockProducts)
+	api.Get("/:id", product, productHandler.GetProductById)
+	api.Put("/:id", can(domain.PermissionProductsUpdate), middleware.ValidationMiddleware(dto.UpdateProductRequest{}), productHandler.UpdateProduct)
+	api.Post("/:id/stock", can(domain.PermissionProductsUpdate), middleware.ValidationMiddleware(dto.AdjustStockRequest{}), productHandler.AdjustStock)
+	api.Delete("/:id", can(domain.PermissionProductsDelete), productHandler.DeleteProduct)
+
+	// Api for webhook subscriptions, webhooks, audit and api keys are stored in MongoDB
+	// and are only served when they are enabled and MongoDB is available
+	if deps.WebhookService != nil {
+		webhookHandler := NewWebhookHandler(deps.WebhookService)
+		webhooks := app.Group("/webhooks", deps.RateLimiter.Limit("webhooks"), can(domain.PermissionWebhooksManage))
+
+		webhooks.Post("",
+			middleware.ValidationMiddleware(dto.CreateWebhookRequest{}),
+			webhookHandler.CreateSubscription)
+		webhooks.Get("", webhookHandler.GetSubscriptions)
+		webhooks.Get("/:id", webhookHandler.GetSubscriptionById)
+		webhooks.Delete("/:id", webhookHandler.DeleteSubscription)
+		webhooks.Get("/:id/deliveries", webhookHandler.GetDeliveries)
+	}
+
+	// Api for audit trail
+	if deps.AuditService != nil {
+		auditHandler := NewAuditHandler(deps.AuditService)
+		app.Get("/audit", deps.RateLimiter.Limit("audit"), can(domain.PermissionAuditRead), auditHandler.GetAuditEntries)
+	}
+
+	// Admin api for api keys of machine clients
+	if deps.APIKeyService != nil {
+		apiKeyHandler := NewAPIKeyHandler(deps.APIKeyService)
+		apiKeys := app.Group("/admin/api-keys", deps.RateLimiter.Limit("admin"), can(domain.PermissionAPIKeysManage))
+
+		apiKeys.Post("",
+			middleware.ValidationMiddleware(dto.CreateAPIKeyRequest{}),
+			apiKeyHandler.IssueAPIKey)
+		apiKeys.Get("", apiKeyHandler.GetAPIKeys)
+		apiKeys.Delete("/:id", apiKeyHandler.RevokeAPIKey)
+	}
+
+	// Admin api for the request profiling and its background writer
+	profiling := app.Group("/admin/profiling", deps.RateLimiter.Limit("admin"), can(domain.PermissionProfilingRead))
+
+	profiling.Get("", profilingHandler.GetProfilings)
+	profiling.Get("/stats", profilingHandler.GetRouteStats)
+	profiling.Get("/writer", profilingHandler.GetWriterStats)
+	if deps.ProfilingSpill != nil {
+		profiling.Get("/spill", NewSpillHandler(deps.ProfilingSpill).GetStats)
+	}
+	if deps.ProfilingRollups != nil {
+		profiling.Get("/trends", NewProfilingTrendHandler(deps.ProfilingRollups).GetTrend)
+	}
+
+	// Admin api for the product cache, only when caching is enabled
+	if deps.ProductCache != nil {
+		cacheHandler := NewCacheHandler(deps.ProductCache)
+		app.Get("/admin/cache/stats",
+			deps.RateLimiter.Limit("admin"),
+			can(domain.PermissionProfilingRead),
+			cacheHandler.GetStats)
+	}
 }
@@ -0,0 +1,33 @@
+package http
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/dto"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+)
+
+type SpillStatsReader interface {
+	Stats() domain.ProfilingSpillStats
+}
+
+/*
+ * Wrapper for spill handler,
+ * It exposes the counters of the profiling spill
+ */
+type SpillHandler struct {
+	spill SpillStatsReader
+}
+
+func NewSpillHandler(spill SpillStatsReader) *SpillHandler {
+	return &SpillHandler{
+		spill,
+	}
+}
+
+func (sh *SpillHandler) GetStats(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(dto.NewWebResponse(
+		sh.spill.Stats(),
+		"Profiling spill stats successfully fetched",
+		nil,
+	))
+}
@@ -0,0 +1,101 @@
+package http
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/dto"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/middleware"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+/*
+ * Wrapper for webhook handler,
+ * It holds webhook service port to manage subscriptions and read their deliveries
+ */
+type WebhookHandler struct {
+	svc port.WebhookService
+}
+
+func NewWebhookHandler(svc port.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		svc,
+	}
+}
+
+func (wh *WebhookHandler) CreateSubscription(c *fiber.Ctx) error {
+	req, err := middleware.Validated[dto.CreateWebhookRequest](c)
+	if err != nil {
+		return err
+	}
+
+	subscription := domain.WebhookSubscription{
+		URL:    req.URL,
+		Secret: req.Secret,
+	}
+	for _, eventType := range req.EventTypes {
+		subscription.EventTypes = append(subscription.EventTypes, domain.EventType(eventType))
+	}
+
+	createdSubscription, err := wh.svc.CreateSubscription(c.UserContext(), &subscription)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(dto.NewWebResponse(
+		*createdSubscription,
+		"Successfully created webhook subscription",
+		nil,
+	))
+}
+
+func (wh *WebhookHandler) GetSubscriptions(c *fiber.Ctx) error {
+	subscriptions, err := wh.svc.GetSubscriptions(c.UserContext())
+	if err != nil {
+		return err
+	}
+
+	totalCount := int64(len(subscriptions))
+	return c.Status(fiber.StatusOK).JSON(dto.NewWebResponse(
+		subscriptions,
+		"Webhook subscriptions successfully fetched",
+		&totalCount,
+	))
+}
+
+func (wh *WebhookHandler) GetSubscriptionById(c *fiber.Ctx) error {
+	subscription, err := wh.svc.GetSubscriptionById(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewWebResponse(
+		subscription,
+		"Webhook subscription successfully fetched",
+		nil,
+	))
+}
+
+func (wh *WebhookHandler) DeleteSubscription(c *fiber.Ctx) error {
+	err := wh.svc.DeleteSubscription(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (wh *WebhookHandler) GetDeliveries(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 10)
+
+	deliveries, totalCount, err := wh.svc.GetDeliveries(c.UserContext(), c.Params("id"), int64(page), int64(limit))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.NewWebResponse(
+		deliveries,
+		"Webhook deliveries successfully fetched",
+		&totalCount,
+	))
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+const HeaderAPIKey = "X-API-Key"
+
+// Authenticate with "X-API-Key: <secret>" header, used by machine clients
+func APIKey(svc port.APIKeyService) Authenticator {
+	return func(c *fiber.Ctx) (*domain.Principal, error) {
+		secret := c.Get(HeaderAPIKey)
+		if secret == "" {
+			return nil, nil
+		}
+
+		return svc.Authenticate(c.UserContext(), secret)
+	}
+}
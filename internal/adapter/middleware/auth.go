@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+)
+
+/*
+ * Authenticator resolves the request credentials to a principal,
+ * it returns nil principal and nil error when the request does not carry its kind of credential
+ */
+type Authenticator func(c *fiber.Ctx) (*domain.Principal, error)
+
+type TokenVerifier interface {
+	Verify(token string) (*domain.Principal, error)
+}
+
+// Authenticate with "Authorization: Bearer <jwt>" header
+func BearerJWT(verifier TokenVerifier) Authenticator {
+	return func(c *fiber.Ctx) (*domain.Principal, error) {
+		header := c.Get(fiber.HeaderAuthorization)
+		if header == "" {
+			return nil, nil
+		}
+
+		scheme, token, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			return nil, domain.ErrUnauthenticated
+		}
+
+		return verifier.Verify(strings.TrimSpace(token))
+	}
+}
+
+/*
+ * This middleware is responsible to authenticate the request,
+ * authenticators are tried in order and the first principal found is used.
+ * The principal is stored in context locals and in the user context,
+ * so services and the audit log know who is calling.
+ * Public routes may be called without credentials, but invalid credentials are always rejected
+ */
+func Authenticate(publicRoutes RouteMatcher, authenticators ...Authenticator) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		for _, authenticate := range authenticators {
+			principal, err := authenticate(c)
+			if err != nil {
+				return unauthenticated(c, err)
+			}
+			if principal != nil {
+				c.Locals("principal", principal)
+				c.SetUserContext(domain.WithPrincipal(c.UserContext(), principal))
+				return c.Next()
+			}
+		}
+
+		if publicRoutes.Match(c.Method(), c.Path()) {
+			return c.Next()
+		}
+
+		return unauthenticated(c, domain.ErrUnauthenticated)
+	}
+}
+
+func unauthenticated(c *fiber.Ctx, err error) error {
+	message := "Authentication required"
+	if !errors.Is(err, domain.ErrUnauthenticated) {
+		message = "Failed to authenticate request"
+	}
+
+	c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="api"`)
+	return problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, message)
+}
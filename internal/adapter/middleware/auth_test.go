@@ -0,0 +1,84 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/middleware"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubVerifier struct{}
+
+func (stubVerifier) Verify(token string) (*domain.Principal, error) {
+	if token != "valid-token" {
+		return nil, domain.ErrUnauthenticated
+	}
+	return &domain.Principal{Subject: "alice", Type: domain.PrincipalUser}, nil
+}
+
+func setupAuthApp() *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Use(middleware.Authenticate(
+		middleware.NewRouteMatcher([]string{"GET /products", "GET /products/*"}),
+		middleware.BearerJWT(stubVerifier{}),
+	))
+	handler := func(c *fiber.Ctx) error {
+		return c.SendString(domain.ActorFromContext(c.UserContext()))
+	}
+	app.Get("/products/:id", handler)
+	app.Delete("/products/:id", handler)
+	return app
+}
+
+/*
+ * Test Authenticate
+ * Public route, missing credentials, invalid token, valid token
+ */
+func TestAuthenticate_PublicRouteWithoutCredentials(t *testing.T) {
+	app := setupAuthApp()
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/products/1", nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+}
+
+func TestAuthenticate_MissingCredentials(t *testing.T) {
+	app := setupAuthApp()
+
+	resp, err := app.Test(httptest.NewRequest("DELETE", "/products/1", nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get(fiber.HeaderWWWAuthenticate))
+}
+
+func TestAuthenticate_InvalidTokenOnPublicRoute(t *testing.T) {
+	app := setupAuthApp()
+
+	req := httptest.NewRequest("GET", "/products/1", nil)
+	req.Header.Set("Authorization", "Bearer forged-token")
+	resp, err := app.Test(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestAuthenticate_ValidToken(t *testing.T) {
+	app := setupAuthApp()
+
+	req := httptest.NewRequest("DELETE", "/products/1", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	resp, err := app.Test(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "alice", string(body))
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+/*
+ * This middleware is responsible to authorize the request,
+ * it must run after Authenticate and rejects principals without the permission
+ */
+func RequirePermission(authorizer port.Authorizer, permission domain.Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := authorizer.Authorize(c.UserContext(), permission)
+		if err == nil {
+			return c.Next()
+		}
+
+		if errors.Is(err, domain.ErrUnauthenticated) {
+			return unauthenticated(c, err)
+		}
+
+		return err
+	}
+}
@@ -0,0 +1,63 @@
+package middleware_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/middleware"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupAuthorizationApp(principal *domain.Principal) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Use(func(c *fiber.Ctx) error {
+		if principal != nil {
+			c.SetUserContext(domain.WithPrincipal(c.UserContext(), principal))
+		}
+		return c.Next()
+	})
+
+	authorizer := service.NewRoleAuthorizer(map[string][]string{"editor": {"products:update"}})
+	app.Delete("/products/:id",
+		middleware.RequirePermission(authorizer, domain.PermissionProductsDelete),
+		func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+	app.Put("/products/:id",
+		middleware.RequirePermission(authorizer, domain.PermissionProductsUpdate),
+		func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+	return app
+}
+
+/*
+ * Test RequirePermission
+ * Granted, forbidden, anonymous
+ */
+func TestRequirePermission_Granted(t *testing.T) {
+	app := setupAuthorizationApp(&domain.Principal{Subject: "alice", Roles: []string{"editor"}})
+
+	resp, err := app.Test(httptest.NewRequest("PUT", "/products/1", nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+}
+
+func TestRequirePermission_Forbidden(t *testing.T) {
+	app := setupAuthorizationApp(&domain.Principal{Subject: "alice", Roles: []string{"editor"}})
+
+	resp, err := app.Test(httptest.NewRequest("DELETE", "/products/1", nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+}
+
+func TestRequirePermission_Anonymous(t *testing.T) {
+	app := setupAuthorizationApp(nil)
+
+	resp, err := app.Test(httptest.NewRequest("DELETE", "/products/1", nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+}
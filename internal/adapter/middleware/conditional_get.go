@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ConditionalGetConfig struct {
+	// Weak ETags are used for list queries, their representation is only semantically equal
+	Weak bool
+	// Cache-Control directives of the route, not set when empty
+	CacheControl string
+}
+
+/*
+ * This middleware is responsible for conditional GET requests,
+ * it computes the ETag of successful responses and answers 304 Not Modified
+ * when If-None-Match matches it, or when If-Modified-Since is not older than
+ * the Last-Modified header set by the handler (If-None-Match takes precedence)
+ */
+func ConditionalGet(config ConditionalGetConfig) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+			return c.Next()
+		}
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		if c.Response().StatusCode() != fiber.StatusOK {
+			return nil
+		}
+
+		if config.CacheControl != "" {
+			c.Set(fiber.HeaderCacheControl, config.CacheControl)
+		}
+
+		sum := sha256.Sum256(c.Response().Body())
+		etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+		if config.Weak {
+			etag = "W/" + etag
+		}
+		c.Set(fiber.HeaderETag, etag)
+
+		if notModified(c, etag) {
+			c.Context().ResetBody()
+			c.Status(fiber.StatusNotModified)
+		}
+
+		return nil
+	}
+}
+
+// Set the Last-Modified header, used by ConditionalGet for If-Modified-Since
+func SetLastModified(c *fiber.Ctx, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	c.Set(fiber.HeaderLastModified, t.UTC().Format(http.TimeFormat))
+}
+
+func notModified(c *fiber.Ctx, etag string) bool {
+	if ifNoneMatch := c.Get(fiber.HeaderIfNoneMatch); ifNoneMatch != "" {
+		// GET uses the weak comparison, the W/ prefix is ignored on both sides
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	ifModifiedSince := c.Get(fiber.HeaderIfModifiedSince)
+	lastModified := string(c.Response().Header.Peek(fiber.HeaderLastModified))
+	if ifModifiedSince == "" || lastModified == "" {
+		return false
+	}
+
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+
+	return !modified.After(since)
+}
@@ -0,0 +1,95 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/middleware"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
+	"github.com/stretchr/testify/assert"
+)
+
+var productModifiedAt = time.Date(2024, 8, 1, 10, 0, 0, 0, time.UTC)
+
+func setupConditionalGetApp() *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Get("/products",
+		middleware.ConditionalGet(middleware.ConditionalGetConfig{Weak: true, CacheControl: "private, max-age=5"}),
+		func(c *fiber.Ctx) error {
+			return c.JSON(fiber.Map{"data": []int{1, 2}})
+		})
+	app.Get("/products/:id",
+		middleware.ConditionalGet(middleware.ConditionalGetConfig{CacheControl: "private, no-cache"}),
+		func(c *fiber.Ctx) error {
+			middleware.SetLastModified(c, productModifiedAt)
+			return c.JSON(fiber.Map{"id": 1})
+		})
+	return app
+}
+
+func conditionalGet(t *testing.T, app *fiber.App, path string, headers map[string]string) *http.Response {
+	req := httptest.NewRequest("GET", path, nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	return resp
+}
+
+/*
+ * Test ConditionalGet
+ * Strong and weak ETag, If-None-Match, If-Modified-Since, changed resource
+ */
+func TestConditionalGet_StrongETag(t *testing.T) {
+	app := setupConditionalGetApp()
+
+	first := conditionalGet(t, app, "/products/1", nil)
+	etag := first.Header.Get(fiber.HeaderETag)
+
+	assert.Equal(t, fiber.StatusOK, first.StatusCode)
+	assert.True(t, strings.HasPrefix(etag, `"`))
+	assert.Equal(t, "private, no-cache", first.Header.Get(fiber.HeaderCacheControl))
+	assert.Equal(t, productModifiedAt.Format(http.TimeFormat), first.Header.Get(fiber.HeaderLastModified))
+
+	second := conditionalGet(t, app, "/products/1", map[string]string{fiber.HeaderIfNoneMatch: etag})
+	assert.Equal(t, fiber.StatusNotModified, second.StatusCode)
+	assert.Equal(t, etag, second.Header.Get(fiber.HeaderETag))
+}
+
+func TestConditionalGet_WeakETag(t *testing.T) {
+	app := setupConditionalGetApp()
+
+	first := conditionalGet(t, app, "/products", nil)
+	etag := first.Header.Get(fiber.HeaderETag)
+	assert.True(t, strings.HasPrefix(etag, `W/"`))
+
+	second := conditionalGet(t, app, "/products", map[string]string{fiber.HeaderIfNoneMatch: etag})
+	assert.Equal(t, fiber.StatusNotModified, second.StatusCode)
+}
+
+func TestConditionalGet_ETagChanged(t *testing.T) {
+	app := setupConditionalGetApp()
+
+	resp := conditionalGet(t, app, "/products/1", map[string]string{fiber.HeaderIfNoneMatch: `"stale"`})
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+}
+
+func TestConditionalGet_IfModifiedSince(t *testing.T) {
+	app := setupConditionalGetApp()
+
+	notModified := conditionalGet(t, app, "/products/1", map[string]string{
+		fiber.HeaderIfModifiedSince: productModifiedAt.Format(http.TimeFormat),
+	})
+	modified := conditionalGet(t, app, "/products/1", map[string]string{
+		fiber.HeaderIfModifiedSince: productModifiedAt.Add(-time.Hour).Format(http.TimeFormat),
+	})
+
+	assert.Equal(t, fiber.StatusNotModified, notModified.StatusCode)
+	assert.Equal(t, fiber.StatusOK, modified.StatusCode)
+}
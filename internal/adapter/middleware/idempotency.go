@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+const (
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+/*
+ * This middleware is responsible to make POST and PATCH requests idempotent,
+ * when the request carries an Idempotency-Key header the response is stored for ttl
+ * and replayed to retries of the same request. A key reused with another request is rejected.
+ * Keys are scoped to the tenant and caller, and server errors are not stored so they can be retried.
+ * The error of the handler is still returned, so the middlewares before this one (e.g. profiling) see it
+ */
+func Idempotency(store port.IdempotencyStore, ttl time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		method := c.Method()
+		if method != fiber.MethodPost && method != fiber.MethodPatch {
+			return c.Next()
+		}
+
+		key := c.Get(HeaderIdempotencyKey)
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return problem.New(fiber.StatusBadRequest, problem.CodeInvalidIdempotency, "Idempotency-Key is too long")
+		}
+
+		ctx := c.UserContext()
+		now := time.Now()
+		record := &domain.IdempotencyRecord{
+			Key:         domain.TenantIDFromContext(ctx) + ":" + domain.ActorFromContext(ctx) + ":" + key,
+			Fingerprint: requestFingerprint(c),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(ttl),
+		}
+
+		existing, err := store.Reserve(ctx, record)
+		if err != nil {
+			log.Println("error when reserving idempotency key", err)
+			return domain.ErrInternal
+		}
+
+		if existing != nil {
+			switch {
+			case existing.Fingerprint != record.Fingerprint:
+				return problem.New(fiber.StatusUnprocessableEntity, problem.CodeIdempotencyMismatch, "Idempotency-Key was already used with a different request")
+			case !existing.Completed:
+				return problem.New(fiber.StatusConflict, problem.CodeIdempotencyConflict, "A request with this Idempotency-Key is still in progress")
+			default:
+				c.Set(HeaderIdempotentReplayed, "true")
+				c.Set(fiber.HeaderContentType, existing.ContentType)
+				return c.Status(existing.StatusCode).Send(existing.Body)
+			}
+		}
+
+		err = c.Next()
+		if err != nil {
+			if problem.Status(err) >= fiber.StatusInternalServerError {
+				releaseIdempotencyKey(c, store, record.Key)
+				return err
+			}
+
+			// Client errors are written here so they are stored and replayed like any other response,
+			// the error handler writes the same response again for the returned error
+			if renderErr := c.App().ErrorHandler(c, err); renderErr != nil {
+				releaseIdempotencyKey(c, store, record.Key)
+				return renderErr
+			}
+		}
+
+		if c.Response().StatusCode() >= fiber.StatusInternalServerError {
+			releaseIdempotencyKey(c, store, record.Key)
+			return err
+		}
+
+		completeIdempotencyKey(c, store, record)
+		return err
+	}
+}
+
+func completeIdempotencyKey(c *fiber.Ctx, store port.IdempotencyStore, record *domain.IdempotencyRecord) {
+	record.Completed = true
+	record.StatusCode = c.Response().StatusCode()
+	record.ContentType = string(c.Response().Header.ContentType())
+	record.Body = append([]byte(nil), c.Response().Body()...)
+	if err := store.Complete(c.UserContext(), record); err != nil {
+		log.Println("error when storing idempotent response", err)
+	}
+}
+
+func releaseIdempotencyKey(c *fiber.Ctx, store port.IdempotencyStore, key string) {
+	if err := store.Release(c.UserContext(), key); err != nil {
+		log.Println("error when releasing idempotency key", err)
+	}
+}
+
+/*
+ * Fingerprint of the method, path, response media type and body, a retry must send the same request.
+ * The media type is the one chosen by Negotiate, or the Accept header when the route is not negotiated,
+ * so a retry asking for another representation is not replayed the stored one
+ */
+func requestFingerprint(c *fiber.Ctx) string {
+	format, _ := c.Locals(FormatKey).(string)
+	if format == "" {
+		format = c.Get(fiber.HeaderAccept)
+	}
+
+	h := sha256.New()
+	h.Write([]byte(c.Method()))
+	h.Write([]byte{0})
+	h.Write([]byte(c.Path()))
+	h.Write([]byte{0})
+	h.Write([]byte(format))
+	h.Write([]byte{0})
+	h.Write(c.Body())
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,154 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/middleware"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/storage/memory"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupIdempotencyApp(calls *int, status int) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Use(middleware.Idempotency(memory.NewIdempotencyStore(), time.Hour))
+	app.Post("/products", func(c *fiber.Ctx) error {
+		*calls++
+		return c.Status(status).JSON(fiber.Map{"id": *calls})
+	})
+	return app
+}
+
+// Returns "<status> <body>" and the replayed header
+func postProduct(t *testing.T, app *fiber.App, key string, body string) (string, string) {
+	req := httptest.NewRequest("POST", "/products", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(middleware.HeaderIdempotencyKey, key)
+	}
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+
+	respBody, _ := io.ReadAll(resp.Body)
+	return strconv.Itoa(resp.StatusCode) + " " + string(respBody), resp.Header.Get(middleware.HeaderIdempotentReplayed)
+}
+
+/*
+ * Test Idempotency
+ * Replay same request, reject different body, reject different negotiated media type,
+ * no key, server error is not stored,
+ * returned client error is replayed and still seen by the middlewares before it
+ */
+func TestIdempotency_ReplaysResponse(t *testing.T) {
+	calls := 0
+	app := setupIdempotencyApp(&calls, fiber.StatusCreated)
+
+	first, replayed := postProduct(t, app, "key-1", `{"name":"Samsung A1"}`)
+	assert.Empty(t, replayed)
+
+	second, replayed := postProduct(t, app, "key-1", `{"name":"Samsung A1"}`)
+	assert.Equal(t, "true", replayed)
+	assert.Equal(t, first, second)
+	assert.Equal(t, `201 {"id":1}`, second)
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotency_DifferentBody(t *testing.T) {
+	calls := 0
+	app := setupIdempotencyApp(&calls, fiber.StatusCreated)
+
+	postProduct(t, app, "key-1", `{"name":"Samsung A1"}`)
+	second, _ := postProduct(t, app, "key-1", `{"name":"Samsung A2"}`)
+
+	assert.True(t, strings.HasPrefix(second, "422 "))
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotency_DifferentMediaType(t *testing.T) {
+	calls := 0
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Use(middleware.Negotiate(fiber.MIMEApplicationJSON, "text/csv"), middleware.Idempotency(memory.NewIdempotencyStore(), time.Hour))
+	app.Post("/products", func(c *fiber.Ctx) error {
+		calls++
+		return c.SendStatus(fiber.StatusCreated)
+	})
+
+	post := func(accept string) int {
+		req := httptest.NewRequest("POST", "/products", strings.NewReader(`{"name":"Samsung A1"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", accept)
+		req.Header.Set(middleware.HeaderIdempotencyKey, "key-1")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, fiber.StatusCreated, post("application/json"))
+	assert.Equal(t, fiber.StatusUnprocessableEntity, post("text/csv"))
+	// Another Accept header negotiating the same media type is the same request
+	assert.Equal(t, fiber.StatusCreated, post("application/json, text/csv;q=0.5"))
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotency_WithoutKey(t *testing.T) {
+	calls := 0
+	app := setupIdempotencyApp(&calls, fiber.StatusCreated)
+
+	postProduct(t, app, "", `{"name":"Samsung A1"}`)
+	postProduct(t, app, "", `{"name":"Samsung A1"}`)
+
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotency_ServerErrorIsRetried(t *testing.T) {
+	calls := 0
+	app := setupIdempotencyApp(&calls, fiber.StatusInternalServerError)
+
+	postProduct(t, app, "key-1", `{"name":"Samsung A1"}`)
+	_, replayed := postProduct(t, app, "key-1", `{"name":"Samsung A1"}`)
+
+	assert.Empty(t, replayed)
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotency_ReturnedClientErrorIsReplayed(t *testing.T) {
+	calls := 0
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Use(middleware.Idempotency(memory.NewIdempotencyStore(), time.Hour))
+	app.Post("/products", func(c *fiber.Ctx) error {
+		calls++
+		return domain.ErrInsufficientStock
+	})
+
+	first, _ := postProduct(t, app, "key-1", `{"delta":-20}`)
+	second, replayed := postProduct(t, app, "key-1", `{"delta":-20}`)
+
+	assert.True(t, strings.HasPrefix(first, "409 "))
+	assert.Equal(t, first, second)
+	assert.Equal(t, "true", replayed)
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotency_ReturnsHandlerError(t *testing.T) {
+	profilingService := &FakeProfilingService{}
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Use(middleware.RequestProfiling(profilingService, middleware.RequestProfilingConfig{SampleRate: 1}))
+	app.Use(middleware.Idempotency(memory.NewIdempotencyStore(), time.Hour))
+	app.Post("/products", func(c *fiber.Ctx) error {
+		return domain.ErrInsufficientStock
+	})
+
+	result, _ := postProduct(t, app, "key-1", `{"delta":-20}`)
+
+	assert.True(t, strings.HasPrefix(result, "409 "))
+	assert.Len(t, profilingService.records, 1)
+	assert.Equal(t, fiber.StatusConflict, profilingService.records[0].Status)
+	assert.Equal(t, domain.ErrInsufficientStock.Error(), profilingService.records[0].Error)
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
+)
+
+// Locals key of the response media type chosen by Negotiate
+const FormatKey = "format"
+
+/*
+ * This middleware is responsible for content negotiation,
+ * it picks the best of the offered media types for the Accept header
+ * and saves it in context locals, a 406 Not Acceptable is returned when
+ * the client accepts none of them (a missing Accept header picks the first offer)
+ */
+func Negotiate(offers ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// The representation depends on Accept, shared caches must key on it
+		c.Vary(fiber.HeaderAccept)
+
+		format := c.Accepts(offers...)
+		if format == "" {
+			return problem.New(fiber.StatusNotAcceptable, problem.CodeNotAcceptable, "Supported media types are "+strings.Join(offers, ", "))
+		}
+
+		c.Locals(FormatKey, format)
+		return c.Next()
+	}
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+)
+
+/*
+ * Rate limiter holds the limit of every route group,
+ * groups without their own limit use the default limit
+ */
+type RateLimiter struct {
+	store        port.RateLimitStore
+	defaultLimit domain.RateLimit
+	groupLimits  map[string]domain.RateLimit
+}
+
+func NewRateLimiter(store port.RateLimitStore, defaultLimit domain.RateLimit, groupLimits map[string]domain.RateLimit) *RateLimiter {
+	return &RateLimiter{
+		store:        store,
+		defaultLimit: defaultLimit,
+		groupLimits:  groupLimits,
+	}
+}
+
+/*
+ * This middleware is responsible to limit the requests of a route group,
+ * every client has its own budget per group. Authenticated clients are identified
+ * by their principal (user or api key), anonymous clients by their IP.
+ * A nil limiter or a limit without requests lets every request through
+ */
+func (l *RateLimiter) Limit(group string) fiber.Handler {
+	if l == nil {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	limit, ok := l.groupLimits[group]
+	if !ok {
+		limit = l.defaultLimit
+	}
+	if limit.Requests <= 0 || limit.Period <= 0 {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	return func(c *fiber.Ctx) error {
+		result, err := l.store.Take(c.UserContext(), group+":"+rateLimitClient(c), limit)
+		if err != nil {
+			// Prefer serving the request over failing it when the store is unavailable
+			log.Println("error when taking rate limit", err)
+			return c.Next()
+		}
+
+		c.Set(HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+		c.Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+		c.Set(HeaderRateLimitReset, strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+		if !result.Allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			return problem.New(fiber.StatusTooManyRequests, problem.CodeRateLimited, "Too many requests, please retry later")
+		}
+
+		return c.Next()
+	}
+}
+
+func rateLimitClient(c *fiber.Ctx) string {
+	if principal, ok := domain.PrincipalFromContext(c.UserContext()); ok {
+		return string(principal.Type) + ":" + principal.Subject
+	}
+	return "ip:" + c.IP()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
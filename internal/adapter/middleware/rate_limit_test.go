@@ -0,0 +1,57 @@
+package middleware_test
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/middleware"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/storage/memory"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupRateLimitApp(limiter *middleware.RateLimiter) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Get("/products", limiter.Limit("products"), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	return app
+}
+
+/*
+ * Test RateLimiter
+ * Headers and 429 with Retry-After, disabled limiter
+ */
+func TestRateLimit_TooManyRequests(t *testing.T) {
+	limiter := middleware.NewRateLimiter(
+		memory.NewRateLimitStore(1),
+		domain.RateLimit{Requests: 100, Period: time.Minute},
+		map[string]domain.RateLimit{"products": {Requests: 1, Period: time.Minute}},
+	)
+	app := setupRateLimitApp(limiter)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/products", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get(middleware.HeaderRateLimitLimit))
+	assert.Equal(t, "0", resp.Header.Get(middleware.HeaderRateLimitRemaining))
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/products", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "60", resp.Header.Get(fiber.HeaderRetryAfter))
+}
+
+func TestRateLimit_Disabled(t *testing.T) {
+	app := setupRateLimitApp(nil)
+
+	for i := 0; i < 3; i++ {
+		resp, err := app.Test(httptest.NewRequest("GET", "/products", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get(middleware.HeaderRateLimitLimit))
+	}
+}
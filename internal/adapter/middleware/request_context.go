@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+/*
+ * This middleware is responsible to give every request an id,
+ * the id from X-Request-ID header is reused when the client sends one.
+ * The id is returned in the response header and stored in the user context,
+ * so services can read it through domain.RequestIDFromContext
+ */
+func RequestContext() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		requestID := c.Get(RequestIDHeader)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = uuid.NewString()
+		}
+
+		c.Set(RequestIDHeader, requestID)
+		c.Locals("requestid", requestID)
+		c.SetUserContext(domain.WithRequestID(c.UserContext(), requestID))
+
+		return c.Next()
+	}
+}
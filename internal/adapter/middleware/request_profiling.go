@@ -1,40 +1,141 @@
 package middleware
 
 import (
-	"context"
+	"errors"
 	"log"
+	"math/rand"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
 )
 
+type RequestProfilingConfig struct {
+	// Only matching requests are profiled, every request when it has no patterns
+	Include RouteMatcher
+	// Matching requests are never profiled, e.g. health checks
+	Exclude RouteMatcher
+	// Share of the requests stored, from 0 to 1. Failed (4xx, 5xx or handler error) and slow requests are always stored
+	SampleRate float64
+	// Requests taking at least this long are slow, no request is slow when zero
+	SlowThreshold time.Duration
+	// Database statements taking at least this long are slow, no statement is slow when zero
+	SlowQueryThreshold time.Duration
+	// A statement run this many times in one request is reported as N+1, disabled when zero
+	NPlusOneThreshold int
+	// Statements stored per record, all when zero. The count and total time include the others
+	MaxQueries int
+}
+
 /*
  * This middleware responsible to set time since the request entry
  * until the request end
- * Then the information will be stored in mongodb
+ * Then the information is queued to the profiling service, which stores it
+ * in mongodb in the background, so the response never waits for the insert.
+ * The error of the handler is returned untouched, the recorded status is
+ * the one the error handler will write for it.
+ * The database statements of the request are collected in a query log
+ * carried by the user context, slow statements and N+1 patterns are flagged
+ * and such requests are always stored
  */
-func RequestProfiling(profilingService port.ProfilingService) fiber.Handler {
+func RequestProfiling(profilingService port.ProfilingService, config RequestProfilingConfig) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		if !config.profiles(c.Method(), c.Path()) {
+			return c.Next()
+		}
+
+		queryLog := &domain.QueryLog{}
+		c.SetUserContext(domain.WithQueryLog(c.UserContext(), queryLog))
+
 		start := time.Now()
 		err := c.Next()
-		duration := time.Since(start).Milliseconds()
+		duration := time.Since(start)
 
-		log.Printf("Request %s %s took %dms", c.Method(), c.Path(), duration)
+		log.Printf("Request %s %s took %s", c.Method(), c.Path(), duration)
 
+		// The record outlives the request, so strings backed by fiber's buffers are copied
 		profilingData := &domain.Profiling{
-			Method:    c.Method(),
-			Path:      c.Path(),
-			Duration:  duration,
-			Timestamp: time.Now(),
+			TenantID:   utils.CopyString(domain.TenantIDFromContext(c.UserContext())),
+			Method:     utils.CopyString(c.Method()),
+			Path:       utils.CopyString(c.Path()),
+			Route:      c.Route().Path,
+			Status:     c.Response().StatusCode(),
+			BytesIn:    len(c.Request().Body()),
+			BytesOut:   len(c.Response().Body()),
+			IP:         utils.CopyString(c.IP()),
+			UserAgent:  utils.CopyString(c.Get(fiber.HeaderUserAgent)),
+			RequestID:  utils.CopyString(domain.RequestIDFromContext(c.UserContext())),
+			Duration:   duration.Milliseconds(),
+			DurationUs: duration.Microseconds(),
+			Timestamp:  time.Now(),
 		}
-
-		_, err = profilingService.InsertProfilingData(context.Background(), profilingData)
 		if err != nil {
-			log.Printf("Failed to save request profiling data: %v", err)
+			profilingData.Status = problem.Status(err)
+			profilingData.Error = err.Error()
+
+			// Fiber reports an unknown route with the path of the last middleware, which is not a route
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) && fiberErr.Code == fiber.StatusNotFound {
+				profilingData.Route = ""
+			}
+		}
+		config.attachQueries(profilingData, queryLog.Queries())
+		if config.keeps(profilingData, duration) {
+			profilingService.RecordProfiling(profilingData)
 		}
 
 		return err
 	}
 }
+
+func (config RequestProfilingConfig) profiles(method string, path string) bool {
+	if len(config.Include.patterns) > 0 && !config.Include.Match(method, path) {
+		return false
+	}
+	return !config.Exclude.Match(method, path)
+}
+
+// Adds the statements to the record, flagging the slow ones and the repeated fingerprints
+func (config RequestProfilingConfig) attachQueries(profilingData *domain.Profiling, queries []domain.QueryTrace) {
+	if len(queries) == 0 {
+		return
+	}
+
+	counts := make(map[string]int)
+	for i := range queries {
+		query := &queries[i]
+		profilingData.DBTimeUs += query.DurationUs
+		if config.SlowQueryThreshold > 0 && query.DurationUs >= config.SlowQueryThreshold.Microseconds() {
+			query.Slow = true
+			profilingData.SlowQueries++
+		}
+
+		counts[query.Fingerprint]++
+		if config.NPlusOneThreshold > 0 && counts[query.Fingerprint] == config.NPlusOneThreshold {
+			profilingData.NPlusOne = append(profilingData.NPlusOne, query.Fingerprint)
+		}
+	}
+
+	profilingData.QueryCount = len(queries)
+	if config.MaxQueries > 0 && len(queries) > config.MaxQueries {
+		queries = queries[:config.MaxQueries]
+	}
+	profilingData.Queries = queries
+}
+
+// Failed and slow requests and requests with query problems are kept, the others are sampled
+func (config RequestProfilingConfig) keeps(profilingData *domain.Profiling, duration time.Duration) bool {
+	if profilingData.Error != "" || profilingData.Status >= fiber.StatusBadRequest {
+		return true
+	}
+	if profilingData.SlowQueries > 0 || len(profilingData.NPlusOne) > 0 {
+		return true
+	}
+	if config.SlowThreshold > 0 && duration >= config.SlowThreshold {
+		return true
+	}
+	return config.SampleRate >= 1 || rand.Float64() < config.SampleRate
+}
@@ -0,0 +1,262 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/middleware"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+type FakeProfilingService struct {
+	records []domain.Profiling
+}
+
+func (s *FakeProfilingService) RecordProfiling(data *domain.Profiling) {
+	s.records = append(s.records, *data)
+}
+
+func (s *FakeProfilingService) Stats() domain.ProfilingWriterStats {
+	return domain.ProfilingWriterStats{}
+}
+
+func (s *FakeProfilingService) GetProfilings(ctx context.Context, filter domain.ProfilingFilter) ([]domain.Profiling, int64, error) {
+	return s.records, int64(len(s.records)), nil
+}
+
+func (s *FakeProfilingService) GetRouteStats(ctx context.Context, filter domain.ProfilingFilter) ([]domain.RouteStats, error) {
+	return nil, nil
+}
+
+/*
+ * Test Request Profiling
+ * Records the request, route template, handler error is returned untouched, unknown route,
+ * excluded and included routes, sampling keeps client errors, server errors and slow requests,
+ * database queries with slow and N+1 flags
+ */
+func TestRequestProfiling_RecordsRequest(t *testing.T) {
+	profilingService := &FakeProfilingService{}
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Use(middleware.RequestContext())
+	app.Use(middleware.RequestProfiling(profilingService, middleware.RequestProfilingConfig{SampleRate: 1}))
+	app.Post("/products", func(c *fiber.Ctx) error {
+		return c.Status(fiber.StatusCreated).SendString("created")
+	})
+
+	req := httptest.NewRequest("POST", "/products", strings.NewReader(`{"name":"Pen"}`))
+	req.Header.Set(fiber.HeaderUserAgent, "inventory-client/1.0")
+	req.Header.Set(middleware.RequestIDHeader, "req-1")
+	resp, err := app.Test(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	assert.Len(t, profilingService.records, 1)
+
+	record := profilingService.records[0]
+	assert.Equal(t, "POST", record.Method)
+	assert.Equal(t, "/products", record.Path)
+	assert.Equal(t, "/products", record.Route)
+	assert.Equal(t, fiber.StatusCreated, record.Status)
+	assert.Equal(t, 14, record.BytesIn)
+	assert.Equal(t, 7, record.BytesOut)
+	assert.Equal(t, "0.0.0.0", record.IP)
+	assert.Equal(t, "inventory-client/1.0", record.UserAgent)
+	assert.Equal(t, "req-1", record.RequestID)
+	assert.Empty(t, record.Error)
+	assert.GreaterOrEqual(t, record.DurationUs, record.Duration*1000)
+}
+
+func TestRequestProfiling_RouteTemplate(t *testing.T) {
+	profilingService := &FakeProfilingService{}
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Use(middleware.RequestProfiling(profilingService, middleware.RequestProfilingConfig{SampleRate: 1}))
+	api := app.Group("/products")
+	api.Get("/:id", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	for _, path := range []string{"/products/1", "/products/2"} {
+		_, err := app.Test(httptest.NewRequest("GET", path, nil))
+		assert.NoError(t, err)
+	}
+
+	assert.Len(t, profilingService.records, 2)
+	assert.Equal(t, "/products/:id", profilingService.records[0].Route)
+	assert.Equal(t, "/products/:id", profilingService.records[1].Route)
+}
+
+func TestRequestProfiling_KeepsHandlerError(t *testing.T) {
+	profilingService := &FakeProfilingService{}
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Use(middleware.RequestProfiling(profilingService, middleware.RequestProfilingConfig{SampleRate: 1}))
+	app.Get("/products/:id", func(c *fiber.Ctx) error {
+		return domain.ErrProductNotFound
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/products/7", nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	assert.Len(t, profilingService.records, 1)
+	assert.Equal(t, fiber.StatusNotFound, profilingService.records[0].Status)
+	assert.Equal(t, "/products/:id", profilingService.records[0].Route)
+	assert.Equal(t, domain.ErrProductNotFound.Error(), profilingService.records[0].Error)
+}
+
+func TestRequestProfiling_UnknownRoute(t *testing.T) {
+	profilingService := &FakeProfilingService{}
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Use(middleware.RequestProfiling(profilingService, middleware.RequestProfilingConfig{SampleRate: 1}))
+	app.Get("/products", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/unknown", nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, fiber.StatusNotFound, profilingService.records[0].Status)
+	assert.Empty(t, profilingService.records[0].Route)
+}
+
+func setupSampledProfilingApp(profilingService *FakeProfilingService, config middleware.RequestProfilingConfig) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Use(middleware.RequestProfiling(profilingService, config))
+	app.Get("/health", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	app.Get("/products", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	app.Get("/products/slow", func(c *fiber.Ctx) error {
+		time.Sleep(20 * time.Millisecond)
+		return c.SendStatus(fiber.StatusOK)
+	})
+	app.Get("/products/broken", func(c *fiber.Ctx) error {
+		return domain.ErrInternal
+	})
+	app.Get("/products/missing", func(c *fiber.Ctx) error {
+		return domain.ErrProductNotFound
+	})
+	app.Get("/products/rejected", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	})
+	return app
+}
+
+func profiledPaths(t *testing.T, app *fiber.App, profilingService *FakeProfilingService, paths ...string) []string {
+	for _, path := range paths {
+		_, err := app.Test(httptest.NewRequest("GET", path, nil))
+		assert.NoError(t, err)
+	}
+
+	var profiled []string
+	for _, record := range profilingService.records {
+		profiled = append(profiled, record.Path)
+	}
+	return profiled
+}
+
+func TestRequestProfiling_ExcludedRoute(t *testing.T) {
+	profilingService := &FakeProfilingService{}
+	app := setupSampledProfilingApp(profilingService, middleware.RequestProfilingConfig{
+		Exclude:    middleware.NewRouteMatcher([]string{"GET /health"}),
+		SampleRate: 1,
+	})
+
+	profiled := profiledPaths(t, app, profilingService, "/health", "/products")
+
+	assert.Equal(t, []string{"/products"}, profiled)
+}
+
+func TestRequestProfiling_IncludedRoutes(t *testing.T) {
+	profilingService := &FakeProfilingService{}
+	app := setupSampledProfilingApp(profilingService, middleware.RequestProfilingConfig{
+		Include:    middleware.NewRouteMatcher([]string{"/products/*"}),
+		Exclude:    middleware.NewRouteMatcher([]string{"/products/broken"}),
+		SampleRate: 1,
+	})
+
+	profiled := profiledPaths(t, app, profilingService, "/health", "/products/slow", "/products/broken")
+
+	assert.Equal(t, []string{"/products/slow"}, profiled)
+}
+
+func TestRequestProfiling_SamplingKeepsErrorsAndSlowRequests(t *testing.T) {
+	profilingService := &FakeProfilingService{}
+	app := setupSampledProfilingApp(profilingService, middleware.RequestProfilingConfig{
+		SampleRate:    0,
+		SlowThreshold: 10 * time.Millisecond,
+	})
+
+	profiled := profiledPaths(t, app, profilingService, "/health", "/products", "/products/slow", "/products/broken", "/products/missing", "/products/rejected")
+
+	assert.Equal(t, []string{"/products/slow", "/products/broken", "/products/missing", "/products/rejected"}, profiled)
+}
+
+// Records the queries a handler would run through the mysql repositories
+func setupQueryProfilingApp(profilingService *FakeProfilingService, config middleware.RequestProfilingConfig, queries ...domain.QueryTrace) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Use(middleware.RequestProfiling(profilingService, config))
+	app.Get("/products", func(c *fiber.Ctx) error {
+		for _, query := range queries {
+			domain.RecordQuery(c.UserContext(), query)
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+	return app
+}
+
+func TestRequestProfiling_Queries(t *testing.T) {
+	profilingService := &FakeProfilingService{}
+	app := setupQueryProfilingApp(profilingService, middleware.RequestProfilingConfig{
+		SampleRate:         1,
+		SlowQueryThreshold: 100 * time.Millisecond,
+		NPlusOneThreshold:  5,
+		MaxQueries:         1,
+	},
+		domain.QueryTrace{Fingerprint: "SELECT * FROM products LIMIT ?", DurationUs: 150000, Rows: 10},
+		domain.QueryTrace{Fingerprint: "SELECT COUNT(id) FROM products", DurationUs: 2000, Rows: 1},
+	)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/products", nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Len(t, profilingService.records, 1)
+
+	record := profilingService.records[0]
+	assert.Equal(t, 2, record.QueryCount)
+	assert.Equal(t, int64(152000), record.DBTimeUs)
+	assert.Equal(t, 1, record.SlowQueries)
+	assert.Empty(t, record.NPlusOne)
+	assert.Len(t, record.Queries, 1)
+	assert.True(t, record.Queries[0].Slow)
+}
+
+func TestRequestProfiling_NPlusOne(t *testing.T) {
+	queries := []domain.QueryTrace{{Fingerprint: "SELECT * FROM products LIMIT ?", DurationUs: 500}}
+	for i := 0; i < 6; i++ {
+		queries = append(queries, domain.QueryTrace{Fingerprint: "SELECT * FROM stock WHERE product_id = ?", DurationUs: 100})
+	}
+
+	profilingService := &FakeProfilingService{}
+	app := setupQueryProfilingApp(profilingService, middleware.RequestProfilingConfig{
+		NPlusOneThreshold: 5,
+	}, queries...)
+
+	_, err := app.Test(httptest.NewRequest("GET", "/products", nil))
+
+	// Kept although nothing is sampled
+	assert.NoError(t, err)
+	assert.Len(t, profilingService.records, 1)
+	assert.Equal(t, []string{"SELECT * FROM stock WHERE product_id = ?"}, profilingService.records[0].NPlusOne)
+	assert.Len(t, profilingService.records[0].Queries, 7)
+	assert.Zero(t, profilingService.records[0].SlowQueries)
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import "strings"
+
+/*
+ * Route matcher matches a request against patterns like "GET /products/*",
+ * the method is optional and a trailing "*" matches any path with that prefix
+ */
+type RouteMatcher struct {
+	patterns []routePattern
+}
+
+type routePattern struct {
+	method string
+	path   string
+	prefix bool
+}
+
+func NewRouteMatcher(patterns []string) RouteMatcher {
+	var matcher RouteMatcher
+	for _, pattern := range patterns {
+		method, path := "", strings.TrimSpace(pattern)
+		if fields := strings.Fields(pattern); len(fields) == 2 {
+			method, path = strings.ToUpper(fields[0]), fields[1]
+		}
+		if method == "*" {
+			method = ""
+		}
+
+		prefix := strings.HasSuffix(path, "*")
+		matcher.patterns = append(matcher.patterns, routePattern{
+			method: method,
+			path:   strings.TrimSuffix(path, "*"),
+			prefix: prefix,
+		})
+	}
+
+	return matcher
+}
+
+func (m RouteMatcher) Match(method string, path string) bool {
+	for _, pattern := range m.patterns {
+		if pattern.method != "" && pattern.method != method {
+			continue
+		}
+		if pattern.prefix && strings.HasPrefix(path, pattern.path) {
+			return true
+		}
+		if !pattern.prefix && pattern.path == path {
+			return true
+		}
+	}
+
+	return false
+}
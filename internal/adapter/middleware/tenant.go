@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"regexp"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+const TenantIDHeader = "X-Tenant-ID"
+
+var tenantIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+/*
+ * This middleware is responsible to resolve the tenant of the request,
+ * it must run after Authenticate. The tenant of the principal always wins,
+ * the X-Tenant-ID header is rejected when it names another tenant than the principal one.
+ * Principals that are not bound to a tenant need the tenants:switch permission
+ * to name a tenant, anonymous callers only get domain.DefaultTenantID.
+ * Requests without tenant use domain.DefaultTenantID
+ */
+func Tenant(authorizer port.Authorizer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tenantID := c.Get(TenantIDHeader)
+		if tenantID != "" && !tenantIDPattern.MatchString(tenantID) {
+			return problem.New(fiber.StatusBadRequest, problem.CodeInvalidTenant, "Invalid tenant ID")
+		}
+
+		principal, ok := domain.PrincipalFromContext(c.UserContext())
+		switch {
+		case ok && principal.TenantID != "":
+			if tenantID != "" && tenantID != principal.TenantID {
+				return problem.New(fiber.StatusForbidden, problem.CodeTenantForbidden, "Access to tenant is not allowed")
+			}
+			tenantID = principal.TenantID
+		case tenantID != "" && tenantID != domain.DefaultTenantID:
+			// The authorizer allows everything when auth is disabled, so a principal is required as well
+			if !ok || authorizer.Authorize(c.UserContext(), domain.PermissionTenantsSwitch) != nil {
+				return problem.New(fiber.StatusForbidden, problem.CodeTenantForbidden, "Access to tenant is not allowed")
+			}
+		}
+
+		if tenantID == "" {
+			tenantID = domain.DefaultTenantID
+		}
+
+		c.Locals("tenantid", tenantID)
+		c.SetUserContext(domain.WithTenantID(c.UserContext(), tenantID))
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,106 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/middleware"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTenantApp(principal *domain.Principal) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Use(func(c *fiber.Ctx) error {
+		if principal != nil {
+			c.SetUserContext(domain.WithPrincipal(c.UserContext(), principal))
+		}
+		return c.Next()
+	})
+	app.Use(middleware.Tenant(service.NewRoleAuthorizer(map[string][]string{"platform": {"tenants:switch"}})))
+	app.Get("/products", func(c *fiber.Ctx) error {
+		return c.SendString(domain.TenantIDFromContext(c.UserContext()))
+	})
+	return app
+}
+
+func tenantOf(t *testing.T, app *fiber.App, header string) (int, string) {
+	req := httptest.NewRequest("GET", "/products", nil)
+	if header != "" {
+		req.Header.Set(middleware.TenantIDHeader, header)
+	}
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+/*
+ * Test Tenant
+ * Default tenant, header tenant, principal tenant, mismatched header, invalid header,
+ * header of anonymous callers and principals without tenants:switch
+ */
+func TestTenant_Default(t *testing.T) {
+	status, tenant := tenantOf(t, setupTenantApp(nil), "")
+
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, domain.DefaultTenantID, tenant)
+}
+
+func TestTenant_Header(t *testing.T) {
+	app := setupTenantApp(&domain.Principal{Subject: "ops", Roles: []string{"platform"}})
+
+	status, tenant := tenantOf(t, app, "brand-a")
+
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "brand-a", tenant)
+}
+
+func TestTenant_AnonymousHeader(t *testing.T) {
+	status, _ := tenantOf(t, setupTenantApp(nil), "brand-a")
+
+	assert.Equal(t, fiber.StatusForbidden, status)
+}
+
+func TestTenant_AnonymousDefaultHeader(t *testing.T) {
+	status, tenant := tenantOf(t, setupTenantApp(nil), domain.DefaultTenantID)
+
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, domain.DefaultTenantID, tenant)
+}
+
+func TestTenant_HeaderWithoutSwitchPermission(t *testing.T) {
+	app := setupTenantApp(&domain.Principal{Subject: "bob", Roles: []string{"viewer"}})
+
+	status, _ := tenantOf(t, app, "brand-a")
+
+	assert.Equal(t, fiber.StatusForbidden, status)
+}
+
+func TestTenant_Principal(t *testing.T) {
+	app := setupTenantApp(&domain.Principal{Subject: "alice", TenantID: "brand-b"})
+
+	status, tenant := tenantOf(t, app, "")
+
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "brand-b", tenant)
+}
+
+func TestTenant_HeaderMismatch(t *testing.T) {
+	app := setupTenantApp(&domain.Principal{Subject: "alice", TenantID: "brand-b"})
+
+	status, _ := tenantOf(t, app, "brand-a")
+
+	assert.Equal(t, fiber.StatusForbidden, status)
+}
+
+func TestTenant_InvalidHeader(t *testing.T) {
+	status, _ := tenantOf(t, setupTenantApp(nil), "brand a;drop")
+
+	assert.Equal(t, fiber.StatusBadRequest, status)
+}
@@ -1,41 +1,214 @@
 package middleware
 
 import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"reflect"
+	"strings"
 
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/id"
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	idTranslations "github.com/go-playground/validator/v10/translations/id"
 	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
 )
 
-// Validator instance
-var validate = validator.New()
+// Locals key of the body validated by ValidationMiddleware
+const validatedBodyKey = "validatedbody"
+
+// Languages of the validation messages, the first one is the default
+var validationLanguages = []string{"en", "id"}
+
+// Validator instance and the translators of its messages
+var (
+	validate   = validator.New()
+	translator = ut.New(en.New(), en.New(), id.New())
+)
+
+func init() {
+	// Report fields by the name clients send, not the Go struct field name
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	unknownFieldMessages := map[string]string{
+		"en": "{0} is not a known field",
+		"id": "{0} bukan field yang dikenal",
+	}
+	registrations := map[string]func(*validator.Validate, ut.Translator) error{
+		"en": enTranslations.RegisterDefaultTranslations,
+		"id": idTranslations.RegisterDefaultTranslations,
+	}
+	for _, lang := range validationLanguages {
+		trans, _ := translator.GetTranslator(lang)
+		if err := registrations[lang](validate, trans); err != nil {
+			log.Fatalf("error when registering %s validation messages: %v", lang, err)
+		}
+		if err := trans.Add("unknown_field", unknownFieldMessages[lang], false); err != nil {
+			log.Fatalf("error when registering %s validation messages: %v", lang, err)
+		}
+	}
+}
 
 /*
  * This middleware is responsible to validate request with type definition
  * It will parse the request body, then validate the data with its validation
- * If succeed, the parse result will be save in context locals
- * If fails, a error message with 400 Bad Request status will be returns
+ * If succeed, the parse result will be save in context locals, read it with Validated
+ * If fails, a validation problem with 400 Bad Request status will be returns,
+ * messages are translated to the Accept-Language of the request and fields
+ * the schema does not know are rejected
  */
 func ValidationMiddleware(schema interface{}) fiber.Handler {
+	schemaType := reflect.TypeOf(schema)
+
 	return func(c *fiber.Ctx) error {
 		// Create a new instance of the schema
-		req := reflect.New(reflect.TypeOf(schema)).Interface()
+		req := reflect.New(schemaType).Interface()
 
 		// Parse the request body into the schema instance
 		if err := c.BodyParser(req); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request payload"})
+			return problem.New(fiber.StatusBadRequest, problem.CodeInvalidPayload, "Invalid request payload")
+		}
+
+		trans, _ := translator.GetTranslator(validationLanguage(c))
+		errorMessages := make(map[string]string)
+
+		unknown, err := unknownFields(c, schemaType)
+		if err != nil {
+			return problem.New(fiber.StatusBadRequest, problem.CodeInvalidPayload, "Invalid request payload")
+		}
+		for _, field := range unknown {
+			message, _ := trans.T("unknown_field", field)
+			errorMessages[field] = message
 		}
 
 		// Validate the request
 		if err := validate.Struct(req); err != nil {
 			validationErrors := err.(validator.ValidationErrors)
-			errorMessages := make(map[string]string)
 			for _, validationErr := range validationErrors {
-				errorMessages[validationErr.Field()] = validationErr.Error()
+				errorMessages[validationErr.Field()] = validationErr.Translate(trans)
 			}
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errorMessages})
 		}
 
+		if len(errorMessages) > 0 {
+			return problem.Validation(errorMessages)
+		}
+
+		c.Locals(validatedBodyKey, req)
 		return c.Next()
 	}
 }
+
+/*
+ * Validated returns the request body validated by ValidationMiddleware,
+ * it fails with an internal error when the route does not validate a body of type T
+ */
+func Validated[T any](c *fiber.Ctx) (*T, error) {
+	req, ok := c.Locals(validatedBodyKey).(*T)
+	if !ok {
+		return nil, fmt.Errorf("request body of type %T is not validated: %w", *new(T), domain.ErrInternal)
+	}
+	return req, nil
+}
+
+func validationLanguage(c *fiber.Ctx) string {
+	if lang := c.AcceptsLanguages(validationLanguages...); lang != "" {
+		return lang
+	}
+	return validationLanguages[0]
+}
+
+/*
+ * Top level fields of the body that have no matching field in the schema,
+ * they are looked up by the json, xml or form tag depending on the Content-Type,
+ * case-insensitively like the decoders do
+ */
+func unknownFields(c *fiber.Ctx, schemaType reflect.Type) ([]string, error) {
+	var format string
+	var names []string
+
+	contentType := strings.ToLower(c.Get(fiber.HeaderContentType))
+	switch {
+	case strings.HasPrefix(contentType, fiber.MIMEApplicationJSON):
+		format = "json"
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(c.Body(), &fields); err != nil {
+			return nil, err
+		}
+		for name := range fields {
+			names = append(names, name)
+		}
+	case strings.HasPrefix(contentType, fiber.MIMEApplicationForm):
+		format = "form"
+		c.Request().PostArgs().VisitAll(func(key, _ []byte) {
+			names = append(names, string(key))
+		})
+	case strings.HasSuffix(strings.Split(contentType, ";")[0], "xml"):
+		format = "xml"
+		var err error
+		if names, err = xmlChildElements(c.Body()); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+
+	known := make(map[string]bool)
+	for i := 0; i < schemaType.NumField(); i++ {
+		field := schemaType.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get(format), ",")
+		if name == "" {
+			name = field.Name
+		}
+		known[strings.ToLower(name)] = true
+	}
+
+	var unknown []string
+	for _, name := range names {
+		if !known[strings.ToLower(name)] {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown, nil
+}
+
+// Names of the elements directly under the root element of an XML document
+func xmlChildElements(body []byte) ([]string, error) {
+	var names []string
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 2 {
+				names = append(names, t.Name.Local)
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
@@ -3,17 +3,20 @@ package middleware_test
 import (
 	"bytes"
 	"encoding/json"
+	"io"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/dto"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/middleware"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestValidationMiddleware_CreateProduct_Success(t *testing.T) {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
 
 	app.Use("/create-product", middleware.ValidationMiddleware(dto.CreateProductRequest{}))
 	app.Post("/create-product", func(c *fiber.Ctx) error {
@@ -31,7 +34,7 @@ func TestValidationMiddleware_CreateProduct_Success(t *testing.T) {
 }
 
 func TestValidationMiddleware_CreateProduct_Failure(t *testing.T) {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
 
 	app.Use("/create-product", middleware.ValidationMiddleware(dto.CreateProductRequest{}))
 	app.Post("/create-product", func(c *fiber.Ctx) error {
@@ -50,11 +53,12 @@ func TestValidationMiddleware_CreateProduct_Failure(t *testing.T) {
 	var response map[string]interface{}
 	err = json.NewDecoder(resp.Body).Decode(&response)
 	assert.NoError(t, err)
+	assert.Equal(t, problem.CodeValidationFailed, response["code"])
 	assert.NotEmpty(t, response["errors"])
 }
 
 func TestValidationMiddleware_CreateProduct_InvalidPayload(t *testing.T) {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
 
 	app.Use("/create-product", middleware.ValidationMiddleware(dto.CreateProductRequest{}))
 	app.Post("/create-product", func(c *fiber.Ctx) error {
@@ -71,5 +75,92 @@ func TestValidationMiddleware_CreateProduct_InvalidPayload(t *testing.T) {
 	var response map[string]interface{}
 	err = json.NewDecoder(resp.Body).Decode(&response)
 	assert.NoError(t, err)
-	assert.Equal(t, "Invalid request payload", response["error"])
+	assert.Equal(t, problem.CodeInvalidPayload, response["code"])
+}
+
+/*
+ * Test Validation Messages and Parsed Body
+ * JSON field names, translated messages, unknown fields, validated body in locals
+ */
+func postValidated(t *testing.T, contentType string, language string, body string) (int, map[string]string) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Post("/create-product", middleware.ValidationMiddleware(dto.CreateProductRequest{}), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	req := httptest.NewRequest("POST", "/create-product", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	if language != "" {
+		req.Header.Set("Accept-Language", language)
+	}
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+
+	var response problem.Problem
+	if resp.StatusCode != fiber.StatusOK {
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	}
+	return resp.StatusCode, response.Errors
+}
+
+func TestValidationMiddleware_JSONFieldNames(t *testing.T) {
+	status, errors := postValidated(t, "application/json", "", `{"name":"Product1","stock":10,"price":0}`)
+
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	assert.Equal(t, map[string]string{"price": "price is a required field"}, errors)
+}
+
+func TestValidationMiddleware_TranslatedMessages(t *testing.T) {
+	status, errors := postValidated(t, "application/json", "id-ID,id;q=0.9,en;q=0.8", `{"name":"Product1","stock":10,"price":0}`)
+
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	assert.Equal(t, map[string]string{"price": "price wajib diisi"}, errors)
+}
+
+func TestValidationMiddleware_UnknownJSONField(t *testing.T) {
+	status, errors := postValidated(t, "application/json", "", `{"name":"Product1","stock":10,"price":100,"discount":5}`)
+
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	assert.Equal(t, map[string]string{"discount": "discount is not a known field"}, errors)
+}
+
+func TestValidationMiddleware_UnknownFormField(t *testing.T) {
+	status, errors := postValidated(t, "application/x-www-form-urlencoded", "", "name=Product1&stock=10&price=100&sku=A1")
+
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	assert.Equal(t, map[string]string{"sku": "sku is not a known field"}, errors)
+}
+
+func TestValidationMiddleware_UnknownXMLField(t *testing.T) {
+	status, errors := postValidated(t, "application/xml", "", "<product><name>Product1</name><stock>10</stock><price>100</price><sku>A1</sku></product>")
+
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	assert.Equal(t, map[string]string{"sku": "sku is not a known field"}, errors)
+}
+
+func TestValidated_ReturnsParsedBody(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Post("/create-product", middleware.ValidationMiddleware(dto.CreateProductRequest{}), func(c *fiber.Ctx) error {
+		req, err := middleware.Validated[dto.CreateProductRequest](c)
+		if err != nil {
+			return err
+		}
+		return c.SendString(req.Name)
+	})
+	app.Post("/unvalidated", func(c *fiber.Ctx) error {
+		_, err := middleware.Validated[dto.CreateProductRequest](c)
+		return err
+	})
+
+	req := httptest.NewRequest("POST", "/create-product", strings.NewReader(`{"name":"Product1","stock":10,"price":100}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "Product1", string(body))
+
+	req = httptest.NewRequest("POST", "/unvalidated", nil)
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusInternalServerError, resp.StatusCode)
 }
@@ -0,0 +1,22 @@
+package notifier
+
+import (
+	"context"
+	"log"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+// Write alerts to the application log
+type LogNotifier struct{}
+
+func NewLogNotifier() port.Notifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) NotifyLowStock(ctx context.Context, alert *domain.LowStockAlert) error {
+	log.Printf("Low stock alert: product %d (%s) stock %d is below threshold %d",
+		alert.ProductID, alert.Name, alert.Stock, alert.Threshold)
+	return nil
+}
@@ -0,0 +1,25 @@
+package notifier
+
+import (
+	"fmt"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/config"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+/*
+ * Create the notifier selected in configuration,
+ * log notifier is used when no type is configured
+ */
+func New(config *config.Notifier) (port.Notifier, error) {
+	switch config.Type {
+	case "", "log":
+		return NewLogNotifier(), nil
+	case "file":
+		return NewFileNotifier(config.FilePath)
+	case "webhook":
+		return NewWebhookNotifier(config.WebhookURL, config.Timeout)
+	default:
+		return nil, fmt.Errorf("unknown notifier type: %s", config.Type)
+	}
+}
@@ -0,0 +1,45 @@
+package problem
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+)
+
+/*
+ * ErrorHandler is installed as fiber.Config.ErrorHandler,
+ * every error returned by a handler or middleware is written as problem+json
+ * with the request ID, so clients get one error shape for all failures
+ */
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	problem, known := fromError(err)
+	if !known {
+		log.Println("error when handling request", err)
+	}
+
+	// Copy so problems shared between requests are not mutated
+	response := *problem
+	response.Instance = c.Path()
+	response.RequestID = domain.RequestIDFromContext(c.UserContext())
+
+	return c.Status(response.Status).JSON(response, ContentType)
+}
+
+// Status is the HTTP status ErrorHandler writes for err
+func Status(err error) int {
+	problem, _ := fromError(err)
+	return problem.Status
+}
+
+// Like From, errors of fiber itself (e.g. an unknown route) keep their status
+func fromError(err error) (*Problem, bool) {
+	problem, known := From(err)
+
+	var fiberErr *fiber.Error
+	if !known && errors.As(err, &fiberErr) {
+		return New(fiberErr.Code, codeFromStatus(fiberErr.Code), fiberErr.Message), true
+	}
+	return problem, known
+}
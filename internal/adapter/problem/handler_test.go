@@ -0,0 +1,98 @@
+package problem_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/middleware"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/problem"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveError(t *testing.T, err error) (int, problem.Problem, string) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+	app.Use(middleware.RequestContext())
+	app.Get("/products/1", func(c *fiber.Ctx) error {
+		return err
+	})
+
+	req := httptest.NewRequest("GET", "/products/1", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-1")
+	resp, testErr := app.Test(req)
+	assert.NoError(t, testErr)
+
+	var response problem.Problem
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	return resp.StatusCode, response, resp.Header.Get("Content-Type")
+}
+
+/*
+ * Test Error Handler
+ * Domain error, wrapped domain error, unsupported error keeps its detail,
+ * business rule error, explicit problem, fiber error, unknown error
+ */
+func TestErrorHandler_DomainError(t *testing.T) {
+	status, response, contentType := serveError(t, domain.ErrProductNotFound)
+
+	assert.Equal(t, fiber.StatusNotFound, status)
+	assert.Equal(t, problem.ContentType, contentType)
+	assert.Equal(t, problem.CodeProductNotFound, response.Code)
+	assert.Equal(t, "/problems/product_not_found", response.Type)
+	assert.Equal(t, "Not Found", response.Title)
+	assert.Equal(t, "product not found", response.Detail)
+	assert.Equal(t, "/products/1", response.Instance)
+	assert.Equal(t, "req-1", response.RequestID)
+}
+
+func TestErrorHandler_WrappedDomainError(t *testing.T) {
+	status, response, _ := serveError(t, fmt.Errorf("adjusting stock of product 1: %w", domain.ErrInsufficientStock))
+
+	assert.Equal(t, fiber.StatusConflict, status)
+	assert.Equal(t, problem.CodeInsufficientStock, response.Code)
+	assert.Equal(t, "product stock is not enough", response.Detail)
+}
+
+func TestErrorHandler_UnsupportedError(t *testing.T) {
+	status, response, _ := serveError(t, domain.ErrRouteStatsUnsupported)
+
+	assert.Equal(t, fiber.StatusNotImplemented, status)
+	assert.Equal(t, problem.CodeRouteStats, response.Code)
+	assert.Equal(t, "profiling route stats need MongoDB 7.0 or later", response.Detail)
+}
+
+func TestErrorHandler_BusinessRuleError(t *testing.T) {
+	err := domain.DefaultProductRules().Validate(&domain.Product{Name: "Samsung A1", Stock: 1, Price: 0})
+	status, response, _ := serveError(t, err)
+
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	assert.Equal(t, problem.CodeValidationFailed, response.Code)
+	assert.Equal(t, map[string]string{"price": "price must be greater than 0"}, response.Errors)
+}
+
+func TestErrorHandler_Problem(t *testing.T) {
+	status, response, _ := serveError(t, problem.Validation(map[string]string{"price": "must be greater than 0"}))
+
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	assert.Equal(t, problem.CodeValidationFailed, response.Code)
+	assert.Equal(t, map[string]string{"price": "must be greater than 0"}, response.Errors)
+}
+
+func TestErrorHandler_FiberError(t *testing.T) {
+	status, response, _ := serveError(t, fiber.ErrMethodNotAllowed)
+
+	assert.Equal(t, fiber.StatusMethodNotAllowed, status)
+	assert.Equal(t, "method_not_allowed", response.Code)
+}
+
+func TestErrorHandler_UnknownError(t *testing.T) {
+	status, response, _ := serveError(t, errors.New("dial tcp 10.0.0.1:3306: connection refused"))
+
+	assert.Equal(t, fiber.StatusInternalServerError, status)
+	assert.Equal(t, problem.CodeInternal, response.Code)
+	assert.Empty(t, response.Detail)
+}
@@ -0,0 +1,58 @@
+package problem
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Media type of the problem details responses (RFC 7807)
+const ContentType = "application/problem+json"
+
+// Problem types are relative URIs made of this prefix and the error code
+const typePrefix = "/problems/"
+
+/*
+ * Problem details of a failed request,
+ * the code is stable and meant for clients, the detail is meant for humans
+ * and errors carries the messages of the invalid fields
+ */
+type Problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail,omitempty"`
+	Instance  string            `json:"instance,omitempty"`
+	Code      string            `json:"code"`
+	RequestID string            `json:"request_id,omitempty"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+func New(status int, code string, detail string) *Problem {
+	return &Problem{
+		Type:   typePrefix + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Problem of a request body that failed validation, keyed by field
+func Validation(errors map[string]string) *Problem {
+	problem := New(http.StatusBadRequest, CodeValidationFailed, "Request payload failed validation")
+	problem.Errors = errors
+	return problem
+}
+
+func (p *Problem) Error() string {
+	return p.Code + ": " + p.Detail
+}
+
+// Error code derived from the status text, "Method Not Allowed" becomes "method_not_allowed"
+func codeFromStatus(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return CodeInternal
+	}
+	return strings.ReplaceAll(strings.ToLower(strings.ReplaceAll(text, "-", " ")), " ", "_")
+}
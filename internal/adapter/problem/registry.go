@@ -0,0 +1,97 @@
+package problem
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+)
+
+// Stable error codes returned to clients
+const (
+	CodeInternal            = "internal_error"
+	CodeInvalidPayload      = "invalid_payload"
+	CodeInvalidProductID    = "invalid_product_id"
+	CodeInvalidQuery        = "invalid_query"
+	CodeValidationFailed    = "validation_failed"
+	CodeProductNotFound     = "product_not_found"
+	CodeProductExists       = "product_already_exists"
+	CodeInsufficientStock   = "insufficient_stock"
+	CodeWebhookNotFound     = "webhook_not_found"
+	CodeAPIKeyNotFound      = "api_key_not_found"
+	CodeUnauthenticated     = "unauthenticated"
+	CodeForbidden           = "forbidden"
+	CodeNotAcceptable       = "not_acceptable"
+	CodeRateLimited         = "rate_limited"
+	CodeInvalidTenant       = "invalid_tenant"
+	CodeTenantForbidden     = "tenant_forbidden"
+	CodeInvalidIdempotency  = "invalid_idempotency_key"
+	CodeIdempotencyMismatch = "idempotency_key_reused"
+	CodeIdempotencyConflict = "idempotency_key_in_progress"
+	CodeProfilingQuery      = "profiling_query_unsupported"
+	CodeRouteStats          = "route_stats_unsupported"
+)
+
+type mapping struct {
+	err    error
+	status int
+	code   string
+}
+
+/*
+ * Registry of the domain errors known by the api,
+ * errors are matched with errors.Is in registration order,
+ * so wrapped errors map to the status of the sentinel they wrap
+ */
+var mappings = []mapping{
+	{domain.ErrProductNotFound, http.StatusNotFound, CodeProductNotFound},
+	{domain.ErrProductAlreadyExists, http.StatusConflict, CodeProductExists},
+	{domain.ErrInsufficientStock, http.StatusConflict, CodeInsufficientStock},
+	{domain.ErrWebhookNotFound, http.StatusNotFound, CodeWebhookNotFound},
+	{domain.ErrAPIKeyNotFound, http.StatusNotFound, CodeAPIKeyNotFound},
+	{domain.ErrUnauthenticated, http.StatusUnauthorized, CodeUnauthenticated},
+	{domain.ErrForbidden, http.StatusForbidden, CodeForbidden},
+	{domain.ErrProfilingQueryUnsupported, http.StatusNotImplemented, CodeProfilingQuery},
+	{domain.ErrRouteStatsUnsupported, http.StatusNotImplemented, CodeRouteStats},
+	{domain.ErrInternal, http.StatusInternalServerError, CodeInternal},
+}
+
+// Register maps a domain error to a status and code, it must be called before serving requests
+func Register(err error, status int, code string) {
+	mappings = append(mappings, mapping{err, status, code})
+}
+
+/*
+ * From converts any error to a problem,
+ * the detail of domain errors is the sentinel message so wrapped causes are not leaked,
+ * internal errors have no detail,
+ * broken business rules become a validation problem listing the fields,
+ * unknown errors become an internal error without detail
+ */
+func From(err error) (*Problem, bool) {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		return problem, true
+	}
+
+	var validationErr *domain.ValidationError
+	if errors.As(err, &validationErr) {
+		fields := make(map[string]string, len(validationErr.Errors))
+		for _, fieldErr := range validationErr.Errors {
+			fields[fieldErr.Field] = fieldErr.Message
+		}
+		return Validation(fields), true
+	}
+
+	for _, m := range mappings {
+		if errors.Is(err, m.err) {
+			detail := m.err.Error()
+			if m.status == http.StatusInternalServerError {
+				detail = ""
+			}
+			return New(m.status, m.code, detail), true
+		}
+	}
+
+	return New(http.StatusInternalServerError, CodeInternal, ""), false
+}
@@ -0,0 +1,55 @@
+package profiling
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+/*
+ * Fan-out sink writes every batch to all sinks, a failing sink (e.g. mongo being down)
+ * does not stop the others and the batch fails only with the errors of the failed sinks.
+ * Queries are answered by the first sink that supports them
+ */
+type FanoutSink struct {
+	sinks []port.ProfilingRepository
+}
+
+func NewFanoutSink(sinks ...port.ProfilingRepository) port.ProfilingRepository {
+	return &FanoutSink{
+		sinks: sinks,
+	}
+}
+
+func (s *FanoutSink) InsertProfilingBatch(ctx context.Context, data []domain.Profiling) error {
+	var errs []error
+	for _, sink := range s.sinks {
+		if err := sink.InsertProfilingBatch(ctx, data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *FanoutSink) GetProfilings(ctx context.Context, filter domain.ProfilingFilter) ([]domain.Profiling, int64, error) {
+	for _, sink := range s.sinks {
+		profilings, totalCount, err := sink.GetProfilings(ctx, filter)
+		if !errors.Is(err, domain.ErrProfilingQueryUnsupported) {
+			return profilings, totalCount, err
+		}
+	}
+	return nil, 0, domain.ErrProfilingQueryUnsupported
+}
+
+func (s *FanoutSink) GetRouteStats(ctx context.Context, filter domain.ProfilingFilter) ([]domain.RouteStats, error) {
+	for _, sink := range s.sinks {
+		stats, err := sink.GetRouteStats(ctx, filter)
+		if !errors.Is(err, domain.ErrProfilingQueryUnsupported) {
+			return stats, err
+		}
+	}
+	return nil, domain.ErrProfilingQueryUnsupported
+}
@@ -0,0 +1,58 @@
+package profiling_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/profiling"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// Sink that is down, like mongo during an outage
+type FailingSink struct {
+	profiling.NoopSink
+}
+
+func (s *FailingSink) InsertProfilingBatch(ctx context.Context, data []domain.Profiling) error {
+	return errors.New("server selection timeout")
+}
+
+/*
+ * Test Fanout Sink
+ * Failing sink does not stop the others, queries use the first queryable sink
+ */
+func TestFanoutSink_FailingSink(t *testing.T) {
+	var output bytes.Buffer
+	ring := profiling.NewRingSink(10)
+	sink := profiling.NewFanoutSink(&FailingSink{}, profiling.NewStdoutSink(&output), ring)
+
+	err := sink.InsertProfilingBatch(context.Background(), []domain.Profiling{record("/a", 200, 1, time.Now())})
+
+	assert.ErrorContains(t, err, "server selection timeout")
+	assert.Contains(t, output.String(), `"route":"/a"`)
+	_, totalCount, _ := ring.GetProfilings(context.Background(), domain.ProfilingFilter{Page: 1, Limit: 10})
+	assert.Equal(t, int64(1), totalCount)
+}
+
+func TestFanoutSink_Queries(t *testing.T) {
+	ring := profiling.NewRingSink(10)
+	ring.InsertProfilingBatch(context.Background(), []domain.Profiling{record("/a", 200, 1, time.Now())})
+	sink := profiling.NewFanoutSink(profiling.NewNoopSink(), ring)
+
+	_, totalCount, err := sink.GetProfilings(context.Background(), domain.ProfilingFilter{Page: 1, Limit: 10})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), totalCount)
+}
+
+func TestFanoutSink_QueriesUnsupported(t *testing.T) {
+	sink := profiling.NewFanoutSink(profiling.NewNoopSink(), profiling.NewStdoutSink(&bytes.Buffer{}))
+
+	_, err := sink.GetRouteStats(context.Background(), domain.ProfilingFilter{})
+
+	assert.ErrorIs(t, err, domain.ErrProfilingQueryUnsupported)
+}
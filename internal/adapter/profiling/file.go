@@ -0,0 +1,102 @@
+package profiling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+/*
+ * Append records to a local file as JSON lines,
+ * the file is rotated to path.1, path.2 and so on before it would exceed maxSize bytes,
+ * and only maxBackups rotated files are kept
+ */
+type FileSink struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+}
+
+func NewFileSink(path string, maxSize int64, maxBackups int) (port.ProfilingRepository, error) {
+	if path == "" {
+		return nil, errors.New("profiling file path is required")
+	}
+
+	return &FileSink{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}, nil
+}
+
+func (s *FileSink) InsertProfilingBatch(ctx context.Context, data []domain.Profiling) error {
+	lines, err := jsonLines(data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.rotateFor(int64(len(lines))); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(lines)
+	return err
+}
+
+func (s *FileSink) GetProfilings(ctx context.Context, filter domain.ProfilingFilter) ([]domain.Profiling, int64, error) {
+	return nil, 0, domain.ErrProfilingQueryUnsupported
+}
+
+func (s *FileSink) GetRouteStats(ctx context.Context, filter domain.ProfilingFilter) ([]domain.RouteStats, error) {
+	return nil, domain.ErrProfilingQueryUnsupported
+}
+
+// Rotate the file when writing size more bytes would exceed the maximum size, an empty file is never rotated
+func (s *FileSink) rotateFor(size int64) error {
+	if s.maxSize <= 0 {
+		return nil
+	}
+
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 || info.Size()+size <= s.maxSize {
+		return nil
+	}
+
+	if s.maxBackups <= 0 {
+		return os.Remove(s.path)
+	}
+
+	// The oldest backup is overwritten by the rename of the one before it
+	for i := s.maxBackups - 1; i >= 1; i-- {
+		err := os.Rename(s.backupPath(i), s.backupPath(i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return os.Rename(s.path, s.backupPath(1))
+}
+
+func (s *FileSink) backupPath(n int) string {
+	return fmt.Sprintf("%s.%d", s.path, n)
+}
@@ -0,0 +1,67 @@
+package profiling_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/profiling"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lineCount(t *testing.T, path string) int {
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return strings.Count(string(content), "\n")
+}
+
+/*
+ * Test File Sink
+ * Json lines, rotation keeps max backups
+ */
+func TestFileSink_JSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiling.log")
+	sink, err := profiling.NewFileSink(path, 0, 0)
+	require.NoError(t, err)
+
+	err = sink.InsertProfilingBatch(context.Background(), []domain.Profiling{
+		record("/a", 200, 1, time.Now()),
+		record("/b", 200, 1, time.Now()),
+	})
+
+	assert.NoError(t, err)
+	content, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[1], `"route":"/b"`)
+}
+
+func TestFileSink_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiling.log")
+	// Room for a single record per file
+	sink, err := profiling.NewFileSink(path, 300, 2)
+	require.NoError(t, err)
+
+	for _, route := range []string{"/a", "/b", "/c", "/d"} {
+		err := sink.InsertProfilingBatch(context.Background(), []domain.Profiling{record(route, 200, 1, time.Now())})
+		require.NoError(t, err)
+	}
+
+	current, _ := os.ReadFile(path)
+	backup, _ := os.ReadFile(path + ".1")
+	assert.Contains(t, string(current), `"route":"/d"`)
+	assert.Contains(t, string(backup), `"route":"/c"`)
+	assert.Equal(t, 1, lineCount(t, path+".2"))
+	assert.NoFileExists(t, path+".3")
+}
+
+func TestFileSink_PathRequired(t *testing.T) {
+	_, err := profiling.NewFileSink("", 0, 0)
+
+	assert.Error(t, err)
+}
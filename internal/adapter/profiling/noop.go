@@ -0,0 +1,27 @@
+package profiling
+
+import (
+	"context"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+// Discard the records, profiling stays enabled without storing anything
+type NoopSink struct{}
+
+func NewNoopSink() port.ProfilingRepository {
+	return &NoopSink{}
+}
+
+func (s *NoopSink) InsertProfilingBatch(ctx context.Context, data []domain.Profiling) error {
+	return nil
+}
+
+func (s *NoopSink) GetProfilings(ctx context.Context, filter domain.ProfilingFilter) ([]domain.Profiling, int64, error) {
+	return nil, 0, domain.ErrProfilingQueryUnsupported
+}
+
+func (s *NoopSink) GetRouteStats(ctx context.Context, filter domain.ProfilingFilter) ([]domain.RouteStats, error) {
+	return nil, domain.ErrProfilingQueryUnsupported
+}
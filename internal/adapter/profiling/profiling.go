@@ -0,0 +1,49 @@
+package profiling
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/config"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+/*
+ * Create the sinks selected in configuration, several sinks are written through a fan-out sink.
+ * The mongo repository is only required when the "mongo" sink is selected
+ */
+func New(config *config.Profiling, mongo port.ProfilingRepository) (port.ProfilingRepository, error) {
+	var sinks []port.ProfilingRepository
+	for _, name := range config.Sinks {
+		switch name {
+		case "mongo":
+			if mongo == nil {
+				return nil, fmt.Errorf("mongo profiling sink is not available")
+			}
+			sinks = append(sinks, mongo)
+		case "stdout":
+			sinks = append(sinks, NewStdoutSink(os.Stdout))
+		case "file":
+			fileSink, err := NewFileSink(config.FilePath, config.FileMaxSize, config.FileMaxBackups)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, fileSink)
+		case "ring":
+			sinks = append(sinks, NewRingSink(config.RingCapacity))
+		case "noop":
+			sinks = append(sinks, NewNoopSink())
+		default:
+			return nil, fmt.Errorf("unknown profiling sink: %s", name)
+		}
+	}
+
+	switch len(sinks) {
+	case 0:
+		return NewNoopSink(), nil
+	case 1:
+		return sinks[0], nil
+	default:
+		return NewFanoutSink(sinks...), nil
+	}
+}
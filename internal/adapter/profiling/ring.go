@@ -0,0 +1,140 @@
+package profiling
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+/*
+ * Keep the latest records in memory, the oldest record is overwritten when the buffer is full.
+ * Unlike the other local sinks it answers queries, so the profiling api works without mongo
+ */
+type RingSink struct {
+	mu      sync.RWMutex
+	records []domain.Profiling
+	// Position of the next write, the oldest record once the buffer is full
+	next int
+	full bool
+}
+
+func NewRingSink(capacity int) port.ProfilingRepository {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &RingSink{
+		records: make([]domain.Profiling, capacity),
+	}
+}
+
+func (s *RingSink) InsertProfilingBatch(ctx context.Context, data []domain.Profiling) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range data {
+		s.records[s.next] = record
+		s.next = (s.next + 1) % len(s.records)
+		if s.next == 0 {
+			s.full = true
+		}
+	}
+	return nil
+}
+
+func (s *RingSink) GetProfilings(ctx context.Context, filter domain.ProfilingFilter) ([]domain.Profiling, int64, error) {
+	matched := s.matching(ctx, filter)
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].Timestamp.After(matched[j].Timestamp)
+	})
+
+	totalCount := int64(len(matched))
+	start := min((filter.Page-1)*filter.Limit, totalCount)
+	end := min(start+filter.Limit, totalCount)
+
+	return matched[start:end], totalCount, nil
+}
+
+// Same stats as the mongo repository: server errors, nearest-rank percentiles in microseconds
+func (s *RingSink) GetRouteStats(ctx context.Context, filter domain.ProfilingFilter) ([]domain.RouteStats, error) {
+	type routeKey struct{ method, route string }
+	durations := make(map[routeKey][]int64)
+	errorCounts := make(map[routeKey]int64)
+
+	for _, record := range s.matching(ctx, filter) {
+		key := routeKey{record.Method, record.Route}
+		duration := record.DurationUs
+		if duration == 0 {
+			duration = record.Duration * 1000
+		}
+		durations[key] = append(durations[key], duration)
+		if record.Status >= 500 {
+			errorCounts[key]++
+		}
+	}
+
+	stats := make([]domain.RouteStats, 0, len(durations))
+	for key, routeDurations := range durations {
+		sort.Slice(routeDurations, func(i, j int) bool { return routeDurations[i] < routeDurations[j] })
+		count := int64(len(routeDurations))
+		stats = append(stats, domain.RouteStats{
+			Method:    key.method,
+			Route:     key.route,
+			Count:     count,
+			Errors:    errorCounts[key],
+			ErrorRate: float64(errorCounts[key]) / float64(count),
+			P50Us:     nearestRank(routeDurations, 0.5),
+			P90Us:     nearestRank(routeDurations, 0.9),
+			P99Us:     nearestRank(routeDurations, 0.99),
+		})
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+		if stats[i].Route != stats[j].Route {
+			return stats[i].Route < stats[j].Route
+		}
+		return stats[i].Method < stats[j].Method
+	})
+	return stats, nil
+}
+
+// Records of the tenant in the context that match the filter, paging is not applied
+func (s *RingSink) matching(ctx context.Context, filter domain.ProfilingFilter) []domain.Profiling {
+	tenantID := domain.TenantIDFromContext(ctx)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records := s.records[:s.next]
+	if s.full {
+		records = s.records
+	}
+
+	matched := []domain.Profiling{}
+	for _, record := range records {
+		switch {
+		case record.TenantID != tenantID,
+			filter.Method != "" && record.Method != filter.Method,
+			filter.Route != "" && record.Route != filter.Route,
+			filter.Status != 0 && record.Status != filter.Status,
+			!filter.From.IsZero() && record.Timestamp.Before(filter.From),
+			!filter.To.IsZero() && record.Timestamp.After(filter.To):
+			continue
+		}
+		matched = append(matched, record)
+	}
+	return matched
+}
+
+// Value at rank ceil(p*n) of sorted values
+func nearestRank(sorted []int64, p float64) int64 {
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
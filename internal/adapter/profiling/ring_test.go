@@ -0,0 +1,101 @@
+package profiling_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/profiling"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func record(route string, status int, durationUs int64, at time.Time) domain.Profiling {
+	return domain.Profiling{
+		TenantID:   domain.DefaultTenantID,
+		Method:     "GET",
+		Route:      route,
+		Status:     status,
+		DurationUs: durationUs,
+		Timestamp:  at,
+	}
+}
+
+/*
+ * Test Ring Sink
+ * Oldest records are overwritten, filter and pagination, tenant scope, route stats
+ */
+func TestRingSink_OverwritesOldest(t *testing.T) {
+	sink := profiling.NewRingSink(2)
+	start := time.Now()
+
+	err := sink.InsertProfilingBatch(context.Background(), []domain.Profiling{
+		record("/a", 200, 1, start),
+		record("/b", 200, 1, start.Add(time.Second)),
+		record("/c", 200, 1, start.Add(2*time.Second)),
+	})
+	profilings, totalCount, _ := sink.GetProfilings(context.Background(), domain.ProfilingFilter{Page: 1, Limit: 10})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), totalCount)
+	assert.Equal(t, "/c", profilings[0].Route)
+	assert.Equal(t, "/b", profilings[1].Route)
+}
+
+func TestRingSink_FilterAndPagination(t *testing.T) {
+	sink := profiling.NewRingSink(10)
+	start := time.Now()
+	sink.InsertProfilingBatch(context.Background(), []domain.Profiling{
+		record("/products", 200, 1, start),
+		record("/products/:id", 404, 1, start.Add(time.Second)),
+		record("/products", 200, 1, start.Add(2*time.Second)),
+		record("/products", 500, 1, start.Add(3*time.Second)),
+	})
+
+	profilings, totalCount, err := sink.GetProfilings(context.Background(), domain.ProfilingFilter{
+		Route: "/products",
+		From:  start.Add(time.Second),
+		Page:  2,
+		Limit: 1,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), totalCount)
+	assert.Len(t, profilings, 1)
+	assert.Equal(t, 200, profilings[0].Status)
+}
+
+func TestRingSink_TenantScope(t *testing.T) {
+	sink := profiling.NewRingSink(10)
+	other := record("/products", 200, 1, time.Now())
+	other.TenantID = "brand-b"
+	sink.InsertProfilingBatch(context.Background(), []domain.Profiling{other})
+
+	_, totalCount, err := sink.GetProfilings(context.Background(), domain.ProfilingFilter{Page: 1, Limit: 10})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), totalCount)
+}
+
+func TestRingSink_RouteStats(t *testing.T) {
+	sink := profiling.NewRingSink(200)
+	var records []domain.Profiling
+	for i := int64(1); i <= 100; i++ {
+		status := 200
+		if i%10 == 0 {
+			status = 500
+		}
+		records = append(records, record("/products", status, i, time.Now()))
+	}
+	records = append(records, record("/products/:id", 200, 0, time.Now()))
+	records[len(records)-1].Duration = 3
+	sink.InsertProfilingBatch(context.Background(), records)
+
+	stats, err := sink.GetRouteStats(context.Background(), domain.ProfilingFilter{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.RouteStats{
+		{Method: "GET", Route: "/products", Count: 100, Errors: 10, ErrorRate: 0.1, P50Us: 50, P90Us: 90, P99Us: 99},
+		{Method: "GET", Route: "/products/:id", Count: 1, P50Us: 3000, P90Us: 3000, P99Us: 3000},
+	}, stats)
+}
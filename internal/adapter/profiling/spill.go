@@ -0,0 +1,369 @@
+package profiling
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	spillSegmentPrefix = "profiling-spill-"
+	spillSegmentSuffix = ".jsonl"
+)
+
+type SpillConfig struct {
+	// Directory of the segment files
+	Dir string
+	// A new segment is started before the current one would exceed this size in bytes
+	SegmentSize int64
+	// Total size in bytes of the segments, the oldest ones are dropped beyond it
+	MaxSize         int64
+	ReplayInterval  time.Duration
+	ReplayBatchSize int
+	// Timeout of a single replayed batch insert
+	ReplayTimeout time.Duration
+}
+
+/*
+ * Spill sink wraps a sink (usually mongo) and appends the batches it fails to write
+ * to local segment files as JSON lines, Run replays them once the sink is back.
+ * Records get their ID before the first insert and keep it in the spill,
+ * so the records the sink stored before failing are skipped as duplicates on replay.
+ * A segment that fails halfway through a replay is rewritten with the records left.
+ * The inserts of a replay are made without holding the lock of the segment files,
+ * so spills and stats never wait for the sink
+ */
+type SpillSink struct {
+	sink   port.ProfilingRepository
+	config SpillConfig
+
+	// Guards the segment files
+	mu sync.Mutex
+	// Segment being appended to, empty when the next spill starts a new one
+	current string
+	// Segment being replayed, the size cap does not drop it meanwhile
+	replaying string
+	nextSeq   uint64
+
+	// Only one replay at a time
+	replayMu sync.Mutex
+
+	spilled  atomic.Uint64
+	replayed atomic.Uint64
+	dropped  atomic.Uint64
+}
+
+func NewSpillSink(sink port.ProfilingRepository, config SpillConfig) (*SpillSink, error) {
+	if config.Dir == "" {
+		return nil, errors.New("profiling spill directory is required")
+	}
+	if config.ReplayInterval <= 0 {
+		config.ReplayInterval = 30 * time.Second
+	}
+	if config.ReplayBatchSize <= 0 {
+		config.ReplayBatchSize = 100
+	}
+	if config.ReplayTimeout <= 0 {
+		config.ReplayTimeout = 10 * time.Second
+	}
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &SpillSink{
+		sink:   sink,
+		config: config,
+	}
+
+	// Continue after the segments left by a previous run, they are replayed first
+	segments, err := s.segments()
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) > 0 {
+		s.nextSeq = segmentSeq(segments[len(segments)-1]) + 1
+	}
+
+	return s, nil
+}
+
+var _ port.ProfilingRepository = (*SpillSink)(nil)
+
+// A batch is only lost when both the sink and the spill fail
+func (s *SpillSink) InsertProfilingBatch(ctx context.Context, data []domain.Profiling) error {
+	for i := range data {
+		if data[i].ID.IsZero() {
+			data[i].ID = primitive.NewObjectID()
+		}
+	}
+
+	err := s.sink.InsertProfilingBatch(ctx, data)
+	if err == nil {
+		return nil
+	}
+
+	if spillErr := s.spill(data); spillErr != nil {
+		return errors.Join(err, spillErr)
+	}
+	log.Printf("profiling sink failed, %d records spilled: %v", len(data), err)
+	return nil
+}
+
+func (s *SpillSink) GetProfilings(ctx context.Context, filter domain.ProfilingFilter) ([]domain.Profiling, int64, error) {
+	return s.sink.GetProfilings(ctx, filter)
+}
+
+func (s *SpillSink) GetRouteStats(ctx context.Context, filter domain.ProfilingFilter) ([]domain.RouteStats, error) {
+	return s.sink.GetRouteStats(ctx, filter)
+}
+
+// Replay the spilled records every replay interval until ctx is done
+func (s *SpillSink) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.ReplayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if replayed, err := s.Replay(ctx); err != nil {
+				log.Printf("error when replaying profiling spill after %d records: %v", replayed, err)
+			}
+		}
+	}
+}
+
+/*
+ * Replay writes the spilled records to the sink, oldest segment first,
+ * it stops at the first failure and returns the number of records replayed
+ */
+func (s *SpillSink) Replay(ctx context.Context) (int, error) {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	s.mu.Lock()
+	segments, err := s.segments()
+	// The current segment is replayed too, later spills start a new one
+	s.current = ""
+	s.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for _, segment := range segments {
+		n, err := s.replaySegment(ctx, segment)
+		replayed += n
+		if err != nil {
+			return replayed, err
+		}
+	}
+	return replayed, nil
+}
+
+func (s *SpillSink) Stats() domain.ProfilingSpillStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := domain.ProfilingSpillStats{
+		Spilled:  s.spilled.Load(),
+		Replayed: s.replayed.Load(),
+		Dropped:  s.dropped.Load(),
+	}
+	segments, _ := s.segments()
+	for _, segment := range segments {
+		stats.Segments++
+		stats.Bytes += fileSize(segment)
+	}
+	return stats
+}
+
+func (s *SpillSink) spill(data []domain.Profiling) error {
+	lines, err := jsonLines(data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current == "" || (s.config.SegmentSize > 0 && fileSize(s.current) > 0 && fileSize(s.current)+int64(len(lines)) > s.config.SegmentSize) {
+		s.current = filepath.Join(s.config.Dir, fmt.Sprintf("%s%010d%s", spillSegmentPrefix, s.nextSeq, spillSegmentSuffix))
+		s.nextSeq++
+	}
+
+	file, err := os.OpenFile(s.current, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(lines); err != nil {
+		return err
+	}
+	s.spilled.Add(uint64(len(data)))
+
+	return s.enforceMaxSize()
+}
+
+// Drop the oldest segments while the spill is over its size cap, the current segment is kept
+func (s *SpillSink) enforceMaxSize() error {
+	if s.config.MaxSize <= 0 {
+		return nil
+	}
+
+	segments, err := s.segments()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, segment := range segments {
+		total += fileSize(segment)
+	}
+	for _, segment := range segments {
+		if total <= s.config.MaxSize || segment == s.current {
+			break
+		}
+		if segment == s.replaying {
+			continue
+		}
+
+		size := fileSize(segment)
+		records, _ := countLines(segment)
+		if err := os.Remove(segment); err != nil {
+			return err
+		}
+		total -= size
+		s.dropped.Add(uint64(records))
+		log.Printf("profiling spill is over %d bytes, dropped %d records of %s", s.config.MaxSize, records, segment)
+	}
+	return nil
+}
+
+func (s *SpillSink) replaySegment(ctx context.Context, segment string) (int, error) {
+	s.mu.Lock()
+	records, err := readSegment(segment)
+	s.replaying = segment
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.replaying = ""
+		s.mu.Unlock()
+	}()
+	if errors.Is(err, os.ErrNotExist) {
+		// Dropped by the size cap since the segments were listed
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for replayed < len(records) {
+		batch := records[replayed:min(replayed+s.config.ReplayBatchSize, len(records))]
+		if err := s.insert(ctx, batch); err != nil {
+			s.mu.Lock()
+			rewriteErr := rewriteSegment(segment, records[replayed:])
+			s.mu.Unlock()
+			if rewriteErr != nil {
+				return replayed, errors.Join(err, rewriteErr)
+			}
+			return replayed, err
+		}
+		replayed += len(batch)
+		s.replayed.Add(uint64(len(batch)))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return replayed, os.Remove(segment)
+}
+
+func (s *SpillSink) insert(ctx context.Context, batch []domain.Profiling) error {
+	ctx, cancel := context.WithTimeout(ctx, s.config.ReplayTimeout)
+	defer cancel()
+
+	return s.sink.InsertProfilingBatch(ctx, batch)
+}
+
+// Segment files sorted from the oldest
+func (s *SpillSink) segments() ([]string, error) {
+	segments, err := filepath.Glob(filepath.Join(s.config.Dir, spillSegmentPrefix+"*"+spillSegmentSuffix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(segments, func(i, j int) bool { return segmentSeq(segments[i]) < segmentSeq(segments[j]) })
+	return segments, nil
+}
+
+func segmentSeq(segment string) uint64 {
+	name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(segment), spillSegmentPrefix), spillSegmentSuffix)
+	seq, _ := strconv.ParseUint(name, 10, 64)
+	return seq
+}
+
+// Records of a segment, a line that can't be decoded (e.g. cut by a crash) is skipped
+func readSegment(segment string) ([]domain.Profiling, error) {
+	content, err := os.ReadFile(segment)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []domain.Profiling
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(nil, len(content)+1)
+	for scanner.Scan() {
+		var record domain.Profiling
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			log.Printf("skipping invalid profiling record in %s: %v", segment, err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// Replace the segment with the records left, through a temporary file so a crash keeps the old one
+func rewriteSegment(segment string, records []domain.Profiling) error {
+	lines, err := jsonLines(records)
+	if err != nil {
+		return err
+	}
+
+	tmp := segment + ".tmp"
+	if err := os.WriteFile(tmp, lines, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, segment)
+}
+
+func countLines(path string) (int, error) {
+	content, err := os.ReadFile(path)
+	return bytes.Count(content, []byte{'\n'}), err
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
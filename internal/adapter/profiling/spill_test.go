@@ -0,0 +1,238 @@
+package profiling_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/profiling"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Ring sink that can be taken down, when limited it goes down once budget records are written
+type FlakySink struct {
+	*profiling.RingSink
+	down    bool
+	limited bool
+	budget  int
+}
+
+func (s *FlakySink) InsertProfilingBatch(ctx context.Context, data []domain.Profiling) error {
+	if s.limited && len(data) > s.budget {
+		s.down = true
+	}
+	if s.down {
+		return errors.New("server selection timeout")
+	}
+	s.budget -= len(data)
+	return s.RingSink.InsertProfilingBatch(ctx, data)
+}
+
+/*
+ * Sink storing records by ID like mongo, a record written again is not stored twice.
+ * When partial is set the next insert stores only that many records of the batch and fails,
+ * like an unordered insert failing halfway
+ */
+type PartialSink struct {
+	stored  map[primitive.ObjectID]domain.Profiling
+	partial int
+}
+
+func (s *PartialSink) InsertProfilingBatch(ctx context.Context, data []domain.Profiling) error {
+	for i, record := range data {
+		if s.partial > 0 && i == s.partial {
+			s.partial = 0
+			return errors.New("write concern timeout")
+		}
+		s.stored[record.ID] = record
+	}
+	return nil
+}
+
+func (s *PartialSink) GetProfilings(ctx context.Context, filter domain.ProfilingFilter) ([]domain.Profiling, int64, error) {
+	return nil, int64(len(s.stored)), nil
+}
+
+func (s *PartialSink) GetRouteStats(ctx context.Context, filter domain.ProfilingFilter) ([]domain.RouteStats, error) {
+	return nil, nil
+}
+
+// Sink that blocks until the insert is cancelled
+type BlockingSink struct {
+	PartialSink
+	entered chan struct{}
+	blocked bool
+}
+
+func (s *BlockingSink) InsertProfilingBatch(ctx context.Context, data []domain.Profiling) error {
+	if !s.blocked {
+		return errors.New("server selection timeout")
+	}
+	close(s.entered)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func newFlakySink() *FlakySink {
+	return &FlakySink{RingSink: profiling.NewRingSink(1000).(*profiling.RingSink)}
+}
+
+func storedCount(t *testing.T, sink *FlakySink) int64 {
+	_, totalCount, err := sink.GetProfilings(context.Background(), domain.ProfilingFilter{Page: 1, Limit: 1})
+	require.NoError(t, err)
+	return totalCount
+}
+
+func spilledBatch(n int) []domain.Profiling {
+	var batch []domain.Profiling
+	for i := 0; i < n; i++ {
+		batch = append(batch, record("/products", 200, int64(i), time.Now()))
+	}
+	return batch
+}
+
+/*
+ * Test Spill Sink
+ * Spill while down and replay when back, partial replay is not written twice,
+ * records stored by a partly failed insert are not written twice,
+ * a slow replay times out without blocking the stats,
+ * size cap drops the oldest segments, segments of a previous run are replayed
+ */
+func TestSpillSink_SpillAndReplay(t *testing.T) {
+	inner := newFlakySink()
+	inner.down = true
+	sink, err := profiling.NewSpillSink(inner, profiling.SpillConfig{Dir: t.TempDir(), ReplayBatchSize: 2})
+	require.NoError(t, err)
+
+	assert.NoError(t, sink.InsertProfilingBatch(context.Background(), spilledBatch(3)))
+	assert.NoError(t, sink.InsertProfilingBatch(context.Background(), spilledBatch(2)))
+	_, err = sink.Replay(context.Background())
+	assert.Error(t, err)
+
+	inner.down = false
+	replayed, err := sink.Replay(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 5, replayed)
+	assert.Equal(t, int64(5), storedCount(t, inner))
+	stats := sink.Stats()
+	assert.Equal(t, uint64(5), stats.Spilled)
+	assert.Equal(t, uint64(5), stats.Replayed)
+	assert.Equal(t, 0, stats.Segments)
+}
+
+func TestSpillSink_PartialReplay(t *testing.T) {
+	inner := newFlakySink()
+	inner.down = true
+	sink, err := profiling.NewSpillSink(inner, profiling.SpillConfig{Dir: t.TempDir(), ReplayBatchSize: 2})
+	require.NoError(t, err)
+	require.NoError(t, sink.InsertProfilingBatch(context.Background(), spilledBatch(5)))
+
+	// The sink goes down again after the first batch of the replay
+	inner.down, inner.limited, inner.budget = false, true, 2
+	replayed, err := sink.Replay(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 2, replayed)
+
+	inner.down, inner.limited = false, false
+	replayed, err = sink.Replay(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, replayed)
+	assert.Equal(t, int64(5), storedCount(t, inner))
+}
+
+func TestSpillSink_PartialInsert(t *testing.T) {
+	inner := &PartialSink{stored: make(map[primitive.ObjectID]domain.Profiling), partial: 2}
+	sink, err := profiling.NewSpillSink(inner, profiling.SpillConfig{Dir: t.TempDir()})
+	require.NoError(t, err)
+
+	// Two records land before the insert fails, the whole batch is spilled
+	require.NoError(t, sink.InsertProfilingBatch(context.Background(), spilledBatch(5)))
+	assert.Len(t, inner.stored, 2)
+
+	replayed, err := sink.Replay(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 5, replayed)
+	// The two records stored first keep their ID in the spill, so they are not stored twice
+	assert.Len(t, inner.stored, 5)
+	for id := range inner.stored {
+		assert.False(t, id.IsZero())
+	}
+}
+
+func TestSpillSink_ReplayTimeout(t *testing.T) {
+	inner := &BlockingSink{entered: make(chan struct{})}
+	sink, err := profiling.NewSpillSink(inner, profiling.SpillConfig{Dir: t.TempDir(), ReplayTimeout: 200 * time.Millisecond})
+	require.NoError(t, err)
+	require.NoError(t, sink.InsertProfilingBatch(context.Background(), spilledBatch(2)))
+
+	inner.blocked = true
+	done := make(chan error)
+	go func() {
+		_, err := sink.Replay(context.Background())
+		done <- err
+	}()
+	<-inner.entered
+
+	// The segment files are not locked while the sink is called
+	stats := make(chan domain.ProfilingSpillStats)
+	go func() { stats <- sink.Stats() }()
+	select {
+	case s := <-stats:
+		assert.Equal(t, 1, s.Segments)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("stats waited for the replay")
+	}
+
+	assert.ErrorIs(t, <-done, context.DeadlineExceeded)
+	assert.Equal(t, 1, sink.Stats().Segments)
+}
+
+func TestSpillSink_MaxSize(t *testing.T) {
+	inner := newFlakySink()
+	inner.down = true
+	// Every batch gets its own segment, and only two segments fit
+	sink, err := profiling.NewSpillSink(inner, profiling.SpillConfig{Dir: t.TempDir(), SegmentSize: 300, MaxSize: 600})
+	require.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, sink.InsertProfilingBatch(context.Background(), spilledBatch(1)))
+	}
+
+	stats := sink.Stats()
+	assert.Equal(t, uint64(4), stats.Spilled)
+	assert.Equal(t, uint64(2), stats.Dropped)
+	assert.Equal(t, 2, stats.Segments)
+}
+
+func TestSpillSink_PreviousRun(t *testing.T) {
+	dir := t.TempDir()
+	inner := newFlakySink()
+	inner.down = true
+	previous, err := profiling.NewSpillSink(inner, profiling.SpillConfig{Dir: dir})
+	require.NoError(t, err)
+	require.NoError(t, previous.InsertProfilingBatch(context.Background(), spilledBatch(2)))
+
+	inner.down = false
+	sink, err := profiling.NewSpillSink(inner, profiling.SpillConfig{Dir: dir})
+	require.NoError(t, err)
+	replayed, err := sink.Replay(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, replayed)
+	segments, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Empty(t, segments)
+}
+
+func TestSpillSink_DirRequired(t *testing.T) {
+	_, err := profiling.NewSpillSink(newFlakySink(), profiling.SpillConfig{})
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+package profiling
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+// Write records as JSON lines, usually to stdout for a log collector
+type StdoutSink struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func NewStdoutSink(writer io.Writer) port.ProfilingRepository {
+	return &StdoutSink{
+		writer: writer,
+	}
+}
+
+func (s *StdoutSink) InsertProfilingBatch(ctx context.Context, data []domain.Profiling) error {
+	lines, err := jsonLines(data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.writer.Write(lines)
+	return err
+}
+
+func (s *StdoutSink) GetProfilings(ctx context.Context, filter domain.ProfilingFilter) ([]domain.Profiling, int64, error) {
+	return nil, 0, domain.ErrProfilingQueryUnsupported
+}
+
+func (s *StdoutSink) GetRouteStats(ctx context.Context, filter domain.ProfilingFilter) ([]domain.RouteStats, error) {
+	return nil, domain.ErrProfilingQueryUnsupported
+}
+
+// One JSON document per record, each followed by a new line
+func jsonLines(data []domain.Profiling) ([]byte, error) {
+	var lines []byte
+	for i := range data {
+		line, err := json.Marshal(&data[i])
+		if err != nil {
+			return nil, err
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+	return lines, nil
+}
@@ -0,0 +1,52 @@
+package profiling
+
+import (
+	"context"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+/*
+ * Tracked sink reports the earliest timestamp of every batch the sink wrote,
+ * so the rollups of hours that got records after they were rolled up
+ * (late records, or records replayed from the spill) are rolled up again
+ */
+type TrackedSink struct {
+	sink  port.ProfilingRepository
+	track func(earliest time.Time)
+}
+
+func NewTrackedSink(sink port.ProfilingRepository, track func(earliest time.Time)) port.ProfilingRepository {
+	return &TrackedSink{
+		sink:  sink,
+		track: track,
+	}
+}
+
+func (s *TrackedSink) InsertProfilingBatch(ctx context.Context, data []domain.Profiling) error {
+	if err := s.sink.InsertProfilingBatch(ctx, data); err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	earliest := data[0].Timestamp
+	for _, record := range data[1:] {
+		if record.Timestamp.Before(earliest) {
+			earliest = record.Timestamp
+		}
+	}
+	s.track(earliest)
+	return nil
+}
+
+func (s *TrackedSink) GetProfilings(ctx context.Context, filter domain.ProfilingFilter) ([]domain.Profiling, int64, error) {
+	return s.sink.GetProfilings(ctx, filter)
+}
+
+func (s *TrackedSink) GetRouteStats(ctx context.Context, filter domain.ProfilingFilter) ([]domain.RouteStats, error) {
+	return s.sink.GetRouteStats(ctx, filter)
+}
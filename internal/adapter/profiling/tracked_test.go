@@ -0,0 +1,42 @@
+package profiling_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/profiling"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+ * Test Tracked Sink
+ * Earliest timestamp of a written batch is reported, failed batches are not
+ */
+func TestTrackedSink_ReportsEarliest(t *testing.T) {
+	var tracked []time.Time
+	sink := profiling.NewTrackedSink(newFlakySink(), func(earliest time.Time) { tracked = append(tracked, earliest) })
+	now := time.Now()
+
+	err := sink.InsertProfilingBatch(context.Background(), []domain.Profiling{
+		record("/products", 200, 10, now),
+		record("/products", 200, 10, now.Add(-2*time.Hour)),
+		record("/products", 200, 10, now.Add(-time.Hour)),
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{now.Add(-2 * time.Hour)}, tracked)
+}
+
+func TestTrackedSink_FailedBatch(t *testing.T) {
+	inner := newFlakySink()
+	inner.down = true
+	tracked := false
+	sink := profiling.NewTrackedSink(inner, func(time.Time) { tracked = true })
+
+	err := sink.InsertProfilingBatch(context.Background(), spilledBatch(2))
+
+	assert.Error(t, err)
+	assert.False(t, tracked)
+}
@@ -0,0 +1,34 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+/*
+ * Fan-out publisher hands every event to all publishers,
+ * the event fails when any of them fails so the relay retries it
+ */
+type FanoutPublisher struct {
+	publishers []port.EventPublisher
+}
+
+func NewFanoutPublisher(publishers ...port.EventPublisher) port.EventPublisher {
+	return &FanoutPublisher{
+		publishers: publishers,
+	}
+}
+
+func (p *FanoutPublisher) Publish(ctx context.Context, event *domain.Event) error {
+	var errs []error
+	for _, publisher := range p.publishers {
+		if err := publisher.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,21 @@
+package publisher
+
+import (
+	"context"
+	"log"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+// Write published events to the application log
+type LogPublisher struct{}
+
+func NewLogPublisher() port.EventPublisher {
+	return &LogPublisher{}
+}
+
+func (p *LogPublisher) Publish(ctx context.Context, event *domain.Event) error {
+	log.Printf("Event %s %s product %d: %s", event.ID, event.Type, event.ProductID, event.Payload)
+	return nil
+}
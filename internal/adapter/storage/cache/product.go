@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+	"golang.org/x/sync/singleflight"
+)
+
+type productList struct {
+	products   []domain.Product
+	totalCount int64
+}
+
+/*
+ * Product repository decorator that caches reads of the wrapped repository,
+ * single products and list queries are kept in separate LRU caches.
+ * Every write removes the product and bumps the generation of its tenant,
+ * list keys contain the generation so all cached lists of the tenant become unreachable.
+ * Concurrent misses of the same key are collapsed into one call to the wrapped repository,
+ * it is not cancelled with the caller that started it, every caller only stops waiting for it
+ */
+type ProductRepository struct {
+	next     port.ProductRepository
+	products *lru
+	lists    *lru
+	group    singleflight.Group
+
+	mu          sync.Mutex
+	generations map[string]uint64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func NewProductRepository(next port.ProductRepository, capacity int, ttl time.Duration) *ProductRepository {
+	return &ProductRepository{
+		next:        next,
+		products:    newLRU(capacity, ttl),
+		lists:       newLRU(capacity, ttl),
+		generations: make(map[string]uint64),
+	}
+}
+
+var _ port.ProductRepository = (*ProductRepository)(nil)
+
+func (r *ProductRepository) Stats() domain.CacheStats {
+	products, productEvictions := r.products.stats()
+	lists, listEvictions := r.lists.stats()
+
+	return domain.CacheStats{
+		Hits:      r.hits.Load(),
+		Misses:    r.misses.Load(),
+		Evictions: productEvictions + listEvictions,
+		Entries:   products + lists,
+	}
+}
+
+func (r *ProductRepository) GetProductById(ctx context.Context, id int64) (*domain.Product, error) {
+	tenantID := domain.TenantIDFromContext(ctx)
+	key := productKey(tenantID, id)
+
+	value, err := r.read(ctx, r.products, tenantID, key, func(ctx context.Context) (interface{}, error) {
+		return r.next.GetProductById(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	product := *value.(*domain.Product)
+	return &product, nil
+}
+
+func (r *ProductRepository) GetProducts(
+	ctx context.Context,
+	page uint64,
+	limit uint64,
+	name string,
+	stock string,
+	price string,
+	sortBy string) ([]domain.Product, int64, error) {
+
+	tenantID := domain.TenantIDFromContext(ctx)
+	key := fmt.Sprintf("%s:%d:list:%d:%d:%q:%q:%q:%q", tenantID, r.generation(tenantID), page, limit, name, stock, price, sortBy)
+
+	value, err := r.read(ctx, r.lists, tenantID, key, func(ctx context.Context) (interface{}, error) {
+		products, totalCount, err := r.next.GetProducts(ctx, page, limit, name, stock, price, sortBy)
+		if err != nil {
+			return nil, err
+		}
+		return &productList{products: products, totalCount: totalCount}, nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	list := value.(*productList)
+	return copyProducts(list.products), list.totalCount, nil
+}
+
+func (r *ProductRepository) GetLowStockProducts(ctx context.Context) ([]domain.Product, error) {
+	tenantID := domain.TenantIDFromContext(ctx)
+	key := fmt.Sprintf("%s:%d:low-stock", tenantID, r.generation(tenantID))
+
+	value, err := r.read(ctx, r.lists, tenantID, key, func(ctx context.Context) (interface{}, error) {
+		products, err := r.next.GetLowStockProducts(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &productList{products: products}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return copyProducts(value.(*productList).products), nil
+}
+
+func (r *ProductRepository) CreateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error) {
+	defer r.invalidate(ctx, 0)
+	return r.next.CreateProduct(ctx, product)
+}
+
+func (r *ProductRepository) UpdateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error) {
+	defer r.invalidate(ctx, product.ID)
+	return r.next.UpdateProduct(ctx, product)
+}
+
+func (r *ProductRepository) AdjustStock(ctx context.Context, id int64, delta int) (*domain.Product, error) {
+	defer r.invalidate(ctx, id)
+	return r.next.AdjustStock(ctx, id, delta)
+}
+
+func (r *ProductRepository) DeleteProduct(ctx context.Context, id int64) error {
+	defer r.invalidate(ctx, id)
+	return r.next.DeleteProduct(ctx, id)
+}
+
+/*
+ * Return the cached value of key or load it,
+ * a loaded value is only cached when no write happened in the tenant meanwhile,
+ * otherwise a read racing with a write could cache the old state.
+ * The load is shared by the concurrent misses, so it keeps the values of the context (tenant)
+ * but not its cancellation, and each caller stops waiting when its own context is done
+ */
+func (r *ProductRepository) read(ctx context.Context, cache *lru, tenantID string, key string, load func(context.Context) (interface{}, error)) (interface{}, error) {
+	if value, ok := cache.get(key); ok {
+		r.hits.Add(1)
+		return value, nil
+	}
+	r.misses.Add(1)
+
+	loadCtx := context.WithoutCancel(ctx)
+	result := r.group.DoChan(key, func() (interface{}, error) {
+		generation := r.generation(tenantID)
+		value, err := load(loadCtx)
+		if err != nil {
+			return nil, err
+		}
+		if r.generation(tenantID) == generation {
+			cache.set(key, value)
+		}
+		return value, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-result:
+		return res.Val, res.Err
+	}
+}
+
+// Invalidate the product and every list of its tenant, the write may have failed after changing data so it is always done
+func (r *ProductRepository) invalidate(ctx context.Context, id int64) {
+	tenantID := domain.TenantIDFromContext(ctx)
+
+	r.mu.Lock()
+	r.generations[tenantID]++
+	r.mu.Unlock()
+
+	if id != 0 {
+		r.products.remove(productKey(tenantID, id))
+	}
+}
+
+func (r *ProductRepository) generation(tenantID string) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.generations[tenantID]
+}
+
+func productKey(tenantID string, id int64) string {
+	return tenantID + ":product:" + strconv.FormatInt(id, 10)
+}
+
+// Callers get their own slice, so they can not change the cached one
+func copyProducts(products []domain.Product) []domain.Product {
+	if products == nil {
+		return nil
+	}
+	return append([]domain.Product(nil), products...)
+}
@@ -0,0 +1,182 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/storage/cache"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Counts the calls that reach the database
+type fakeProductRepository struct {
+	getCalls  atomic.Int64
+	listCalls atomic.Int64
+	delay     time.Duration
+	stock     atomic.Int64
+}
+
+func (r *fakeProductRepository) CreateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error) {
+	return product, nil
+}
+
+func (r *fakeProductRepository) GetProductById(ctx context.Context, id int64) (*domain.Product, error) {
+	r.getCalls.Add(1)
+	time.Sleep(r.delay)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if id == 404 {
+		return nil, domain.ErrProductNotFound
+	}
+	return &domain.Product{ID: id, Name: domain.TenantIDFromContext(ctx), Stock: int(r.stock.Load())}, nil
+}
+
+func (r *fakeProductRepository) GetProducts(ctx context.Context, page uint64, limit uint64, name string, stock string, price string, sortBy string) ([]domain.Product, int64, error) {
+	r.listCalls.Add(1)
+	return []domain.Product{{ID: 1, Name: name}}, 1, nil
+}
+
+func (r *fakeProductRepository) GetLowStockProducts(ctx context.Context) ([]domain.Product, error) {
+	r.listCalls.Add(1)
+	return []domain.Product{}, nil
+}
+
+func (r *fakeProductRepository) UpdateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error) {
+	r.stock.Store(int64(product.Stock))
+	return product, nil
+}
+
+func (r *fakeProductRepository) AdjustStock(ctx context.Context, id int64, delta int) (*domain.Product, error) {
+	return &domain.Product{ID: id, Stock: int(r.stock.Add(int64(delta)))}, nil
+}
+
+func (r *fakeProductRepository) DeleteProduct(ctx context.Context, id int64) error {
+	return nil
+}
+
+/*
+ * Test Product Cache
+ * Hit after miss, invalidate on write, tenant separation, collapsed misses,
+ * cancelled first caller doesn't fail the others, eviction, errors are not cached
+ */
+func TestProductCache_HitAfterMiss(t *testing.T) {
+	next := &fakeProductRepository{}
+	repo := cache.NewProductRepository(next, 10, time.Minute)
+
+	_, err := repo.GetProductById(context.Background(), 1)
+	require.NoError(t, err)
+	_, err = repo.GetProductById(context.Background(), 1)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(1), next.getCalls.Load())
+	stats := repo.Stats()
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+}
+
+func TestProductCache_InvalidateOnWrite(t *testing.T) {
+	next := &fakeProductRepository{}
+	repo := cache.NewProductRepository(next, 10, time.Minute)
+	ctx := context.Background()
+
+	repo.GetProductById(ctx, 1)
+	repo.GetProducts(ctx, 1, 10, "", "", "", "")
+
+	_, err := repo.AdjustStock(ctx, 1, 5)
+	require.NoError(t, err)
+
+	product, _ := repo.GetProductById(ctx, 1)
+	repo.GetProducts(ctx, 1, 10, "", "", "", "")
+
+	assert.Equal(t, 5, product.Stock)
+	assert.Equal(t, int64(2), next.getCalls.Load())
+	assert.Equal(t, int64(2), next.listCalls.Load())
+}
+
+func TestProductCache_TenantSeparation(t *testing.T) {
+	next := &fakeProductRepository{}
+	repo := cache.NewProductRepository(next, 10, time.Minute)
+
+	a, _ := repo.GetProductById(domain.WithTenantID(context.Background(), "brand-a"), 1)
+	b, _ := repo.GetProductById(domain.WithTenantID(context.Background(), "brand-b"), 1)
+
+	assert.Equal(t, "brand-a", a.Name)
+	assert.Equal(t, "brand-b", b.Name)
+	assert.Equal(t, int64(2), next.getCalls.Load())
+}
+
+func TestProductCache_CollapsesConcurrentMisses(t *testing.T) {
+	next := &fakeProductRepository{delay: 50 * time.Millisecond}
+	repo := cache.NewProductRepository(next, 10, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			repo.GetProductById(context.Background(), 1)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(1), next.getCalls.Load())
+}
+
+func TestProductCache_CancelledCallerDoesNotFailOthers(t *testing.T) {
+	next := &fakeProductRepository{delay: 50 * time.Millisecond}
+	repo := cache.NewProductRepository(next, 10, time.Minute)
+
+	ctx, cancel := context.WithCancel(domain.WithTenantID(context.Background(), "brand-a"))
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	var wg sync.WaitGroup
+	var cancelledErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, cancelledErr = repo.GetProductById(ctx, 1)
+	}()
+
+	// Joins the load started by the first caller
+	time.Sleep(5 * time.Millisecond)
+	product, err := repo.GetProductById(domain.WithTenantID(context.Background(), "brand-a"), 1)
+	wg.Wait()
+
+	assert.ErrorIs(t, cancelledErr, context.Canceled)
+	require.NoError(t, err)
+	assert.Equal(t, "brand-a", product.Name)
+	assert.Equal(t, int64(1), next.getCalls.Load())
+}
+
+func TestProductCache_Eviction(t *testing.T) {
+	next := &fakeProductRepository{}
+	repo := cache.NewProductRepository(next, 2, time.Minute)
+	ctx := context.Background()
+
+	repo.GetProductById(ctx, 1)
+	repo.GetProductById(ctx, 2)
+	repo.GetProductById(ctx, 3)
+	repo.GetProductById(ctx, 1)
+
+	assert.Equal(t, int64(4), next.getCalls.Load())
+	assert.Equal(t, 2, repo.Stats().Entries)
+	assert.Equal(t, uint64(2), repo.Stats().Evictions)
+}
+
+func TestProductCache_ErrorIsNotCached(t *testing.T) {
+	next := &fakeProductRepository{}
+	repo := cache.NewProductRepository(next, 10, time.Minute)
+
+	_, err := repo.GetProductById(context.Background(), 404)
+	assert.Equal(t, domain.ErrProductNotFound, err)
+	_, err = repo.GetProductById(context.Background(), 404)
+	assert.Equal(t, domain.ErrProductNotFound, err)
+
+	assert.Equal(t, int64(2), next.getCalls.Load())
+}
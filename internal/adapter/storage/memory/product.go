@@ -0,0 +1,245 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+/*
+ * Product repository keeps the products in memory, it is meant for tests and local runs,
+ * products are lost on restart and no outbox events are written.
+ * Names are unique per tenant, compared case-insensitively like the MySQL collation does
+ */
+type ProductRepository struct {
+	mu       sync.RWMutex
+	nextID   int64
+	products map[int64]storedProduct
+}
+
+type storedProduct struct {
+	tenantID string
+	product  domain.Product
+}
+
+func NewProductRepository() *ProductRepository {
+	return &ProductRepository{
+		products: make(map[int64]storedProduct),
+	}
+}
+
+var _ port.ProductRepository = (*ProductRepository)(nil)
+
+func (r *ProductRepository) CreateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error) {
+	tenantID := domain.TenantIDFromContext(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.nameTaken(tenantID, product.Name, 0) {
+		return nil, domain.ErrProductAlreadyExists
+	}
+
+	r.nextID++
+	product.ID = r.nextID
+	product.UpdatedAt = time.Now().UTC()
+	r.products[product.ID] = storedProduct{tenantID, *product}
+
+	return product, nil
+}
+
+func (r *ProductRepository) GetProductById(ctx context.Context, id int64) (*domain.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.products[id]
+	if !ok || stored.tenantID != domain.TenantIDFromContext(ctx) {
+		return nil, domain.ErrProductNotFound
+	}
+
+	product := stored.product
+	return &product, nil
+}
+
+func (r *ProductRepository) GetProducts(
+	ctx context.Context,
+	page uint64,
+	limit uint64,
+	name string,
+	stock string,
+	price string,
+	sortBy string) ([]domain.Product, int64, error) {
+
+	matched := r.tenantProducts(ctx, func(p domain.Product) bool {
+		return (name == "" || strings.Contains(strings.ToLower(p.Name), strings.ToLower(name))) &&
+			inRange(p.Stock, stock) &&
+			inRange(p.Price, price)
+	})
+	sortProducts(matched, sortBy)
+
+	totalCount := int64(len(matched))
+	start := (page - 1) * limit
+	if start >= uint64(len(matched)) {
+		return []domain.Product{}, totalCount, nil
+	}
+	end := start + limit
+	if end > uint64(len(matched)) {
+		end = uint64(len(matched))
+	}
+
+	return matched[start:end], totalCount, nil
+}
+
+func (r *ProductRepository) GetLowStockProducts(ctx context.Context) ([]domain.Product, error) {
+	products := r.tenantProducts(ctx, func(p domain.Product) bool {
+		return p.IsLowStock()
+	})
+	sortProducts(products, "stock,asc")
+
+	return products, nil
+}
+
+func (r *ProductRepository) UpdateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error) {
+	tenantID := domain.TenantIDFromContext(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.products[product.ID]
+	if !ok || stored.tenantID != tenantID {
+		return nil, domain.ErrProductNotFound
+	}
+	if r.nameTaken(tenantID, product.Name, product.ID) {
+		return nil, domain.ErrProductAlreadyExists
+	}
+
+	setAuditedProduct(ctx, stored.product)
+	product.UpdatedAt = time.Now().UTC()
+	r.products[product.ID] = storedProduct{tenantID, *product}
+
+	return product, nil
+}
+
+func (r *ProductRepository) AdjustStock(ctx context.Context, id int64, delta int) (*domain.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.products[id]
+	if !ok || stored.tenantID != domain.TenantIDFromContext(ctx) {
+		return nil, domain.ErrProductNotFound
+	}
+	if stored.product.Stock+delta < 0 {
+		return nil, domain.ErrInsufficientStock
+	}
+	setAuditedProduct(ctx, stored.product)
+
+	stored.product.Stock += delta
+	stored.product.UpdatedAt = time.Now().UTC()
+	r.products[id] = stored
+
+	product := stored.product
+	return &product, nil
+}
+
+func (r *ProductRepository) DeleteProduct(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.products[id]
+	if !ok || stored.tenantID != domain.TenantIDFromContext(ctx) {
+		return domain.ErrProductNotFound
+	}
+
+	setAuditedProduct(ctx, stored.product)
+	delete(r.products, id)
+	return nil
+}
+
+// Report the state the write replaces to the audit pending in the context, must hold the lock
+func setAuditedProduct(ctx context.Context, before domain.Product) {
+	if pending, ok := domain.PendingAuditFromContext(ctx); ok {
+		pending.Before = &before
+	}
+}
+
+// Whether another product of the tenant than exceptID already has the name, must hold the lock
+func (r *ProductRepository) nameTaken(tenantID string, name string, exceptID int64) bool {
+	for id, stored := range r.products {
+		if id != exceptID && stored.tenantID == tenantID && strings.EqualFold(stored.product.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Copies of the tenant products that match, ordered by ID
+func (r *ProductRepository) tenantProducts(ctx context.Context, match func(domain.Product) bool) []domain.Product {
+	tenantID := domain.TenantIDFromContext(ctx)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	products := []domain.Product{}
+	for _, stored := range r.products {
+		if stored.tenantID == tenantID && match(stored.product) {
+			products = append(products, stored.product)
+		}
+	}
+	sortProducts(products, "")
+
+	return products
+}
+
+// Match the "min-max" or "min" filter format of the product list, invalid filters match everything
+func inRange(value int, filter string) bool {
+	if filter == "" {
+		return true
+	}
+
+	minPart, maxPart, isRange := strings.Cut(filter, "-")
+	if min, err := strconv.Atoi(minPart); err == nil && value < min {
+		return false
+	}
+	if isRange {
+		if max, err := strconv.Atoi(maxPart); err == nil && value > max {
+			return false
+		}
+	}
+	return true
+}
+
+// Sort by the "field,direction" format of the product list, by ID when the field is unknown
+func sortProducts(products []domain.Product, sortBy string) {
+	field, direction, _ := strings.Cut(sortBy, ",")
+	desc := strings.EqualFold(direction, "desc")
+
+	less := func(a, b domain.Product) bool {
+		switch field {
+		case "name":
+			return a.Name < b.Name
+		case "stock":
+			return a.Stock < b.Stock
+		case "price":
+			return a.Price < b.Price
+		case "reorder_threshold":
+			return a.ReorderThreshold < b.ReorderThreshold
+		case "updated_at":
+			return a.UpdatedAt.Before(b.UpdatedAt)
+		default:
+			return a.ID < b.ID
+		}
+	}
+
+	sort.SliceStable(products, func(i, j int) bool {
+		if desc {
+			return less(products[j], products[i])
+		}
+		return less(products[i], products[j])
+	})
+}
@@ -0,0 +1,95 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/storage/memory"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+ * Test Product Repository
+ * Duplicate name on create and update, names per tenant, list filters, stock adjustment
+ */
+func TestProductRepository_DuplicateName(t *testing.T) {
+	repo := memory.NewProductRepository()
+	ctx := context.Background()
+
+	_, err := repo.CreateProduct(ctx, &domain.Product{Name: "Samsung A1", Stock: 10, Price: 1000})
+	require.NoError(t, err)
+
+	_, err = repo.CreateProduct(ctx, &domain.Product{Name: "samsung a1", Stock: 5, Price: 900})
+	assert.ErrorIs(t, err, domain.ErrProductAlreadyExists)
+}
+
+func TestProductRepository_RenameToExistingName(t *testing.T) {
+	repo := memory.NewProductRepository()
+	ctx := context.Background()
+
+	_, err := repo.CreateProduct(ctx, &domain.Product{Name: "Samsung A1", Stock: 10, Price: 1000})
+	require.NoError(t, err)
+	pixel, err := repo.CreateProduct(ctx, &domain.Product{Name: "Pixel 8", Stock: 10, Price: 2000})
+	require.NoError(t, err)
+
+	_, err = repo.UpdateProduct(ctx, &domain.Product{ID: pixel.ID, Name: "Samsung A1", Stock: 10, Price: 2000})
+	assert.ErrorIs(t, err, domain.ErrProductAlreadyExists)
+
+	// Keeping its own name is not a duplicate
+	_, err = repo.UpdateProduct(ctx, &domain.Product{ID: pixel.ID, Name: "Pixel 8", Stock: 8, Price: 2000})
+	assert.NoError(t, err)
+}
+
+func TestProductRepository_SameNameOtherTenant(t *testing.T) {
+	repo := memory.NewProductRepository()
+
+	_, err := repo.CreateProduct(domain.WithTenantID(context.Background(), "brand-a"), &domain.Product{Name: "Samsung A1", Stock: 10, Price: 1000})
+	require.NoError(t, err)
+
+	created, err := repo.CreateProduct(domain.WithTenantID(context.Background(), "brand-b"), &domain.Product{Name: "Samsung A1", Stock: 10, Price: 1000})
+	assert.NoError(t, err)
+
+	_, err = repo.GetProductById(domain.WithTenantID(context.Background(), "brand-a"), created.ID)
+	assert.ErrorIs(t, err, domain.ErrProductNotFound)
+}
+
+func TestProductRepository_GetProductsFilters(t *testing.T) {
+	repo := memory.NewProductRepository()
+	ctx := context.Background()
+
+	for _, p := range []domain.Product{
+		{Name: "Samsung A1", Stock: 10, Price: 1000},
+		{Name: "Samsung A2", Stock: 3, Price: 1500},
+		{Name: "Pixel 8", Stock: 20, Price: 2000},
+	} {
+		_, err := repo.CreateProduct(ctx, &p)
+		require.NoError(t, err)
+	}
+
+	products, total, err := repo.GetProducts(ctx, 1, 10, "samsung", "5-15", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, "Samsung A1", products[0].Name)
+
+	products, total, _ = repo.GetProducts(ctx, 2, 2, "", "", "", "price,desc")
+	assert.Equal(t, int64(3), total)
+	assert.Len(t, products, 1)
+	assert.Equal(t, "Samsung A1", products[0].Name)
+}
+
+func TestProductRepository_AdjustStock(t *testing.T) {
+	repo := memory.NewProductRepository()
+	ctx := context.Background()
+
+	created, err := repo.CreateProduct(ctx, &domain.Product{Name: "Samsung A1", Stock: 10, Price: 1000})
+	require.NoError(t, err)
+
+	adjusted, err := repo.AdjustStock(ctx, created.ID, -4)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, adjusted.Stock)
+
+	_, err = repo.AdjustStock(ctx, created.ID, -7)
+	assert.ErrorIs(t, err, domain.ErrInsufficientStock)
+}
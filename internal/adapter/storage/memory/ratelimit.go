@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+type rateLimitShard struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+/*
+ * Rate limit store keeps a token bucket per key in memory,
+ * buckets are spread over shards so concurrent clients rarely share a lock.
+ * A bucket holds limit.Requests tokens and is refilled evenly over limit.Period
+ */
+type RateLimitStore struct {
+	shards []*rateLimitShard
+}
+
+func NewRateLimitStore(shardCount int) *RateLimitStore {
+	if shardCount < 1 {
+		shardCount = 1
+	}
+
+	store := &RateLimitStore{
+		shards: make([]*rateLimitShard, shardCount),
+	}
+	for i := range store.shards {
+		store.shards[i] = &rateLimitShard{buckets: make(map[string]*bucket)}
+	}
+
+	return store
+}
+
+var _ port.RateLimitStore = (*RateLimitStore)(nil)
+
+func (s *RateLimitStore) Take(ctx context.Context, key string, limit domain.RateLimit) (domain.RateLimitResult, error) {
+	capacity := float64(limit.Requests)
+	rate := capacity / limit.Period.Seconds() // tokens per second
+	now := time.Now()
+
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	b, ok := shard.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		shard.buckets[key] = b
+	}
+	b.period = limit.Period
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	result := domain.RateLimitResult{Limit: limit.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.ResetAfter = secondsToDuration((capacity - b.tokens) / rate)
+
+	return result, nil
+}
+
+// Remove the buckets that are full again until the context is cancelled, so idle clients do not use memory
+func (s *RateLimitStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.removeIdleBuckets(now)
+		}
+	}
+}
+
+func (s *RateLimitStore) removeIdleBuckets(now time.Time) {
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		for key, b := range shard.buckets {
+			if now.Sub(b.updated) >= b.period {
+				delete(shard.buckets, key)
+			}
+		}
+		shard.mu.Unlock()
+	}
+}
+
+func (s *RateLimitStore) shard(key string) *rateLimitShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
@@ -0,0 +1,72 @@
+package memory_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/storage/memory"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+ * Test Rate Limit Store
+ * Budget exhausted, separate keys, concurrent takes
+ */
+func TestRateLimitStore_Exhausted(t *testing.T) {
+	store := memory.NewRateLimitStore(4)
+	limit := domain.RateLimit{Requests: 2, Period: time.Second}
+
+	first, err := store.Take(context.Background(), "ip:1.1.1.1", limit)
+	require.NoError(t, err)
+	assert.True(t, first.Allowed)
+	assert.Equal(t, 1, first.Remaining)
+
+	second, _ := store.Take(context.Background(), "ip:1.1.1.1", limit)
+	assert.True(t, second.Allowed)
+	assert.Equal(t, 0, second.Remaining)
+
+	third, _ := store.Take(context.Background(), "ip:1.1.1.1", limit)
+	assert.False(t, third.Allowed)
+	assert.Equal(t, 2, third.Limit)
+	assert.Greater(t, third.RetryAfter, time.Duration(0))
+	assert.LessOrEqual(t, third.RetryAfter, 500*time.Millisecond)
+}
+
+func TestRateLimitStore_SeparateKeys(t *testing.T) {
+	store := memory.NewRateLimitStore(4)
+	limit := domain.RateLimit{Requests: 1, Period: time.Minute}
+
+	a, _ := store.Take(context.Background(), "ip:1.1.1.1", limit)
+	b, _ := store.Take(context.Background(), "ip:2.2.2.2", limit)
+
+	assert.True(t, a.Allowed)
+	assert.True(t, b.Allowed)
+}
+
+func TestRateLimitStore_Concurrent(t *testing.T) {
+	store := memory.NewRateLimitStore(4)
+	limit := domain.RateLimit{Requests: 50, Period: time.Hour}
+
+	var mu sync.Mutex
+	allowed := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, _ := store.Take(context.Background(), "apikey:1", limit)
+			if result.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 50, allowed)
+}
@@ -27,6 +27,7 @@ func New(ctx context.Context, config *config.ProfilingDB) (*DB, error) {
 	// Ping the database to verify connection
 	err = client.Ping(ctx, nil)
 	if err != nil {
+		_ = client.Disconnect(context.Background())
 		return nil, fmt.Errorf("error pinging MongoDB: %w", err)
 	}
 
@@ -42,7 +43,8 @@ func New(ctx context.Context, config *config.ProfilingDB) (*DB, error) {
 func (db *DB) Close(ctx context.Context) {
 	err := db.Client.Disconnect(ctx)
 	if err != nil {
-		fmt.Errorf("Error disconnecting from MongoDB", "error", err)
+		fmt.Println("Error disconnecting from MongoDB", "error", err)
+		return
 	}
 	fmt.Println("Disconnected from MongoDB")
 }
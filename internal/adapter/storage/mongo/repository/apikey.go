@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type APIKeyRepository struct {
+	collection *mongo.Collection
+}
+
+func NewAPIKeyRepository(db *mongo.Database, collectionName string) *APIKeyRepository {
+	return &APIKeyRepository{
+		collection: db.Collection(collectionName),
+	}
+}
+
+var _ port.APIKeyRepository = (*APIKeyRepository)(nil)
+
+// Create the unique index used to look up a key by the hash of its secret
+func (r *APIKeyRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+	})
+	return err
+}
+
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, key *domain.APIKey) (*domain.APIKey, error) {
+	result, err := r.collection.InsertOne(ctx, key)
+	if err != nil {
+		log.Println("error when try to insert api key:", err)
+		return nil, domain.ErrInternal
+	}
+
+	key.ID = result.InsertedID.(primitive.ObjectID)
+	return key, nil
+}
+
+func (r *APIKeyRepository) GetAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, tenantFilter(ctx, bson.M{}), opts)
+	if err != nil {
+		log.Println("error when try to retrieve api keys:", err)
+		return nil, domain.ErrInternal
+	}
+	defer cursor.Close(ctx)
+
+	keys := []domain.APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		log.Println("error when try to decode api keys:", err)
+		return nil, domain.ErrInternal
+	}
+
+	return keys, nil
+}
+
+// Lookup is not tenant scoped, the key itself determines the tenant of the request
+func (r *APIKeyRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*domain.APIKey, error) {
+	var key domain.APIKey
+	err := r.collection.FindOne(ctx, bson.M{"hash": hash}).Decode(&key)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, domain.ErrAPIKeyNotFound
+		}
+		log.Println("error when try to retrieve api key:", err)
+		return nil, domain.ErrInternal
+	}
+
+	return &key, nil
+}
+
+func (r *APIKeyRepository) RevokeAPIKey(ctx context.Context, id string, revokedAt time.Time) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrAPIKeyNotFound
+	}
+
+	// An already revoked key keeps its original revocation time
+	result, err := r.collection.UpdateOne(ctx,
+		tenantFilter(ctx, bson.M{"_id": objectID}),
+		bson.A{bson.M{"$set": bson.M{"revoked_at": bson.M{"$ifNull": bson.A{"$revoked_at", revokedAt}}}}},
+	)
+	if err != nil {
+		log.Println("error when try to revoke api key:", err)
+		return domain.ErrInternal
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+func (r *APIKeyRepository) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrAPIKeyNotFound
+	}
+
+	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{"$set": bson.M{"last_used_at": usedAt}})
+	if err != nil {
+		log.Println("error when try to update api key last used time:", err)
+		return domain.ErrInternal
+	}
+
+	return nil
+}
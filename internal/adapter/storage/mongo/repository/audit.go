@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"log"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AuditRepository struct {
+	collection *mongo.Collection
+}
+
+func NewAuditRepository(db *mongo.Database, collectionName string) *AuditRepository {
+	return &AuditRepository{
+		collection: db.Collection(collectionName),
+	}
+}
+
+var _ port.AuditRepository = (*AuditRepository)(nil)
+
+// Create indexes for the supported audit filters
+func (r *AuditRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "product_id", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "actor", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "event_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"event_id": bson.M{"$exists": true}}),
+		},
+	})
+	return err
+}
+
+// An entry with the ID or event ID of a stored one is a retry or redelivery, it is not stored twice
+func (r *AuditRepository) InsertAuditEntry(ctx context.Context, entry *domain.AuditEntry) (*domain.AuditEntry, error) {
+	result, err := r.collection.InsertOne(ctx, entry)
+	if mongo.IsDuplicateKeyError(err) {
+		return entry, nil
+	}
+	if err != nil {
+		log.Println("error when try to insert audit entry:", err)
+		return nil, domain.ErrInternal
+	}
+
+	entry.ID = result.InsertedID.(primitive.ObjectID)
+	return entry, nil
+}
+
+func (r *AuditRepository) GetAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, int64, error) {
+	query := tenantFilter(ctx, bson.M{})
+	if filter.ProductID != 0 {
+		query["product_id"] = filter.ProductID
+	}
+	if filter.Actor != "" {
+		query["actor"] = filter.Actor
+	}
+	timestamp := bson.M{}
+	if !filter.From.IsZero() {
+		timestamp["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		timestamp["$lte"] = filter.To
+	}
+	if len(timestamp) > 0 {
+		query["timestamp"] = timestamp
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}}).
+		SetSkip((filter.Page - 1) * filter.Limit).
+		SetLimit(filter.Limit)
+
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		log.Println("error when try to retrieve audit entries:", err)
+		return nil, 0, domain.ErrInternal
+	}
+	defer cursor.Close(ctx)
+
+	entries := []domain.AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		log.Println("error when try to decode audit entries:", err)
+		return nil, 0, domain.ErrInternal
+	}
+
+	totalCount, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		log.Println("error when trying to count audit entries:", err)
+		return nil, 0, domain.ErrInternal
+	}
+
+	return entries, totalCount, nil
+}
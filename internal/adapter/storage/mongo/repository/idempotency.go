@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+ * Idempotency store keeps the records in MongoDB, so they are shared by every instance,
+ * the record key is the document id which makes reserving a key atomic
+ */
+type IdempotencyStore struct {
+	collection *mongo.Collection
+}
+
+func NewIdempotencyStore(db *mongo.Database, collectionName string) *IdempotencyStore {
+	return &IdempotencyStore{
+		collection: db.Collection(collectionName),
+	}
+}
+
+var _ port.IdempotencyStore = (*IdempotencyStore)(nil)
+
+// Create the TTL index that removes expired records
+func (s *IdempotencyStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+func (s *IdempotencyStore) Reserve(ctx context.Context, record *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error) {
+	_, err := s.collection.InsertOne(ctx, record)
+	if err == nil {
+		return nil, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		log.Println("error when try to insert idempotency record:", err)
+		return nil, domain.ErrInternal
+	}
+
+	var existing domain.IdempotencyRecord
+	err = s.collection.FindOne(ctx, bson.M{"_id": record.Key}).Decode(&existing)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			// Removed in the meantime, try again
+			return s.Reserve(ctx, record)
+		}
+		log.Println("error when try to retrieve idempotency record:", err)
+		return nil, domain.ErrInternal
+	}
+
+	// The TTL monitor runs only periodically, an expired record may still exist
+	if !time.Now().Before(existing.ExpiresAt) {
+		result, err := s.collection.ReplaceOne(ctx, bson.M{"_id": record.Key, "expires_at": existing.ExpiresAt}, record)
+		if err != nil {
+			log.Println("error when try to replace idempotency record:", err)
+			return nil, domain.ErrInternal
+		}
+		if result.MatchedCount == 1 {
+			return nil, nil
+		}
+		return s.Reserve(ctx, record)
+	}
+
+	return &existing, nil
+}
+
+func (s *IdempotencyStore) Complete(ctx context.Context, record *domain.IdempotencyRecord) error {
+	_, err := s.collection.ReplaceOne(ctx, bson.M{"_id": record.Key}, record, options.Replace().SetUpsert(true))
+	if err != nil {
+		log.Println("error when try to store idempotent response:", err)
+		return domain.ErrInternal
+	}
+
+	return nil
+}
+
+func (s *IdempotencyStore) Release(ctx context.Context, key string) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": key, "completed": false})
+	if err != nil {
+		log.Println("error when try to delete idempotency record:", err)
+		return domain.ErrInternal
+	}
+
+	return nil
+}
@@ -1,128 +1,322 @@
 package repository
 
 import (
+	"context"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Sortable fields of the product list and their document field
+var productSortFields = map[string]string{
+	"id":                "_id",
+	"name":              "name",
+	"stock":             "stock",
+	"price":             "price",
+	"reorder_threshold": "reorder_threshold",
+	"updated_at":        "updated_at",
+}
+
+/*
+ * Product repository backed by MongoDB, product IDs are numeric like in MySQL
+ * and come from a counter document. Unlike the MySQL repository no outbox events are written.
+ * Names are unique per tenant through a case-insensitive unique index
+ */
 type ProductRepository struct {
 	collection *mongo.Collection
+	counters   *mongo.Collection
+}
+
+type productDocument struct {
+	ID               int64     `bson:"_id"`
+	TenantID         string    `bson:"tenant_id"`
+	Name             string    `bson:"name"`
+	Stock            int       `bson:"stock"`
+	Price            int       `bson:"price"`
+	ReorderThreshold int       `bson:"reorder_threshold"`
+	UpdatedAt        time.Time `bson:"updated_at"`
+}
+
+func (d productDocument) toDomain() domain.Product {
+	return domain.Product{
+		ID:               d.ID,
+		Name:             d.Name,
+		Stock:            d.Stock,
+		Price:            d.Price,
+		ReorderThreshold: d.ReorderThreshold,
+		UpdatedAt:        d.UpdatedAt,
+	}
 }
 
 func NewProductRepository(db *mongo.Database, collectionName string) *ProductRepository {
 	return &ProductRepository{
 		collection: db.Collection(collectionName),
+		counters:   db.Collection("counters"),
+	}
+}
+
+var _ port.ProductRepository = (*ProductRepository)(nil)
+
+// Create the unique index on the product name of a tenant, and the index of the low stock query
+func (r *ProductRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "name", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetCollation(&options.Collation{Locale: "en", Strength: 2}),
+		},
+		{
+			Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "stock", Value: 1}},
+		},
+	})
+	return err
+}
+
+func (r *ProductRepository) CreateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error) {
+	id, err := r.nextID(ctx)
+	if err != nil {
+		log.Println("error when try to generate product id:", err)
+		return nil, domain.ErrInternal
+	}
+
+	product.ID = id
+	product.UpdatedAt = time.Now().UTC().Truncate(time.Millisecond)
+	_, err = r.collection.InsertOne(ctx, productDocument{
+		ID:               product.ID,
+		TenantID:         domain.TenantIDFromContext(ctx),
+		Name:             product.Name,
+		Stock:            product.Stock,
+		Price:            product.Price,
+		ReorderThreshold: product.ReorderThreshold,
+		UpdatedAt:        product.UpdatedAt,
+	})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			log.Println("product name already exists:", err)
+			return nil, domain.ErrProductAlreadyExists
+		}
+		log.Println("error when try to insert product:", err)
+		return nil, domain.ErrInternal
+	}
+
+	return product, nil
+}
+
+func (r *ProductRepository) GetProductById(ctx context.Context, id int64) (*domain.Product, error) {
+	var doc productDocument
+	err := r.collection.FindOne(ctx, tenantFilter(ctx, bson.M{"_id": id})).Decode(&doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, domain.ErrProductNotFound
+		}
+		log.Println("error when try to retrieve product:", err)
+		return nil, domain.ErrInternal
 	}
+
+	product := doc.toDomain()
+	return &product, nil
 }
 
-// func (r *ProductRepository) CreateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error) {
-// 	startTime := time.Now()
-// 	result, err := r.collection.InsertOne(ctx, product)
-// 	if err != nil {
-// 		log.Println("error when try to inserting new product collection", err)
-// 		return nil, domain.ErrInternal
-// 	}
-
-// 	product.ID = result.InsertedID.(primitive.ObjectID)
-// 	log.Printf("product inserted, duration: %v\n", time.Since(startTime))
-// 	return product, nil
-// }
-
-// func (r *ProductRepository) GetProductById(ctx context.Context, id string) (*domain.Product, error) {
-// 	startTime := time.Now()
-// 	objectID, err := primitive.ObjectIDFromHex(id)
-// 	if err != nil {
-// 		log.Println("error when try to convert object id", err)
-// 		return nil, err
-// 	}
-
-// 	var product domain.Product
-// 	err = r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&product)
-// 	if err != nil {
-// 		if err == mongo.ErrNoDocuments {
-// 			log.Println("error when try to retrieve product from collection, product not found", err)
-// 			return nil, domain.ErrProductNotFound
-// 		}
-// 		return nil, err
-// 	}
-
-// 	log.Printf("product retrieved, duration: %v\n", time.Since(startTime))
-// 	return &product, nil
-// }
-
-// func (r *ProductRepository) GetProducts(ctx context.Context, page int64, limit int64) ([]domain.Product, int64, error) {
-// 	startTime := time.Now()
-// 	skip := (page - 1) * limit
-
-// 	// Retrieve products with pagination
-// 	cursor, err := r.collection.Find(ctx, bson.M{}, &options.FindOptions{
-// 		Skip:  &skip,
-// 		Limit: &limit,
-// 	})
-// 	if err != nil {
-// 		log.Println("error when try to retrieve products from collection", err)
-// 		return nil, 0, domain.ErrInternal
-// 	}
-// 	defer cursor.Close(ctx)
-
-// 	var products []domain.Product
-// 	if err = cursor.All(ctx, &products); err != nil {
-// 		log.Println("error when try to retrieve all product cursor", err)
-// 		return nil, 0, domain.ErrInternal
-// 	}
-
-// 	// Retrieve total count of all products
-// 	totalCount, err := r.collection.CountDocuments(ctx, bson.M{})
-// 	if err != nil {
-// 		log.Println("error when trying to count products in collection", err)
-// 		return nil, 0, domain.ErrInternal
-// 	}
-
-// 	log.Printf("products retrieved, duration: %v\n", time.Since(startTime))
-// 	return products, totalCount, nil
-// }
-
-// func (r *ProductRepository) UpdateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error) {
-// 	startTime := time.Now()
-// 	filter := bson.M{"_id": product.ID}
-// 	update := bson.M{"$set": bson.M{
-// 		"name":  product.Name,
-// 		"stock": product.Stock,
-// 		"price": product.Price,
-// 	}}
-
-// 	result, err := r.collection.UpdateOne(ctx, filter, update)
-// 	if err != nil {
-// 		log.Println("error when try to update product in product collection", err)
-// 		return nil, domain.ErrInternal
-// 	}
-
-// 	if result.MatchedCount == 0 {
-// 		log.Println("error there is no match product to update", err)
-// 		return nil, domain.ErrProductNotFound
-// 	}
-
-// 	log.Printf("product updated, duration: %v\n", time.Since(startTime))
-// 	return product, nil
-// }
-
-// func (r *ProductRepository) DeleteProduct(ctx context.Context, id string) error {
-// 	startTime := time.Now()
-// 	objectID, err := primitive.ObjectIDFromHex(id)
-// 	if err != nil {
-// 		return domain.ErrInternal
-// 	}
-
-// 	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objectID})
-// 	if err != nil {
-// 		log.Println("error when try to delete product in product collection", err)
-// 		return domain.ErrInternal
-// 	}
-
-// 	if result.DeletedCount == 0 {
-// 		log.Println("error there is no match product to delete", err)
-// 		return domain.ErrProductNotFound
-// 	}
-
-// 	log.Printf("product deleted, duration: %v\n", time.Since(startTime))
-// 	return nil
-// }
+func (r *ProductRepository) GetProducts(
+	ctx context.Context,
+	page uint64,
+	limit uint64,
+	name string,
+	stock string,
+	price string,
+	sortBy string) ([]domain.Product, int64, error) {
+
+	filter := tenantFilter(ctx, bson.M{})
+	if name != "" {
+		filter["name"] = bson.M{"$regex": regexp.QuoteMeta(name), "$options": "i"}
+	}
+	if rangeFilter := productRangeFilter(stock); rangeFilter != nil {
+		filter["stock"] = rangeFilter
+	}
+	if rangeFilter := productRangeFilter(price); rangeFilter != nil {
+		filter["price"] = rangeFilter
+	}
+
+	sort := bson.D{{Key: "_id", Value: 1}}
+	if field, direction, found := strings.Cut(sortBy, ","); found {
+		if docField, ok := productSortFields[field]; ok {
+			order := 1
+			if strings.EqualFold(direction, "desc") {
+				order = -1
+			}
+			sort = bson.D{{Key: docField, Value: order}}
+		}
+	}
+
+	opts := options.Find().
+		SetSort(sort).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	products, err := r.findProducts(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	totalCount, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		log.Println("error when try to count products:", err)
+		return nil, 0, domain.ErrInternal
+	}
+
+	return products, totalCount, nil
+}
+
+func (r *ProductRepository) GetLowStockProducts(ctx context.Context) ([]domain.Product, error) {
+	filter := tenantFilter(ctx, bson.M{"$expr": bson.M{"$lt": bson.A{"$stock", "$reorder_threshold"}}})
+	opts := options.Find().SetSort(bson.D{{Key: "stock", Value: 1}})
+
+	return r.findProducts(ctx, filter, opts)
+}
+
+func (r *ProductRepository) UpdateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error) {
+	product.UpdatedAt = time.Now().UTC().Truncate(time.Millisecond)
+	update := bson.M{"$set": bson.M{
+		"name":              product.Name,
+		"stock":             product.Stock,
+		"price":             product.Price,
+		"reorder_threshold": product.ReorderThreshold,
+		"updated_at":        product.UpdatedAt,
+	}}
+
+	// The document before the update is returned for the audit trail
+	var before productDocument
+	err := r.collection.FindOneAndUpdate(ctx, tenantFilter(ctx, bson.M{"_id": product.ID}), update).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		return nil, domain.ErrProductNotFound
+	}
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			log.Println("product name already exists:", err)
+			return nil, domain.ErrProductAlreadyExists
+		}
+		log.Println("error when try to update product:", err)
+		return nil, domain.ErrInternal
+	}
+	setAuditedProduct(ctx, before.toDomain())
+
+	return product, nil
+}
+
+// The stock condition and the increment are a single update, so concurrent adjustments can't go below zero
+func (r *ProductRepository) AdjustStock(ctx context.Context, id int64, delta int) (*domain.Product, error) {
+	filter := tenantFilter(ctx, bson.M{"_id": id, "stock": bson.M{"$gte": -delta}})
+	update := bson.M{
+		"$inc": bson.M{"stock": delta},
+		"$set": bson.M{"updated_at": time.Now().UTC().Truncate(time.Millisecond)},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var doc productDocument
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		// Either the product does not exist or the stock is not enough
+		if _, err := r.GetProductById(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, domain.ErrInsufficientStock
+	}
+	if err != nil {
+		log.Println("error when try to adjust product stock:", err)
+		return nil, domain.ErrInternal
+	}
+
+	product := doc.toDomain()
+	before := product
+	before.Stock -= delta
+	setAuditedProduct(ctx, before)
+
+	return &product, nil
+}
+
+func (r *ProductRepository) DeleteProduct(ctx context.Context, id int64) error {
+	var before productDocument
+	err := r.collection.FindOneAndDelete(ctx, tenantFilter(ctx, bson.M{"_id": id})).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		return domain.ErrProductNotFound
+	}
+	if err != nil {
+		log.Println("error when try to delete product:", err)
+		return domain.ErrInternal
+	}
+	setAuditedProduct(ctx, before.toDomain())
+
+	return nil
+}
+
+// Report the document the write replaced to the audit pending in the context
+func setAuditedProduct(ctx context.Context, before domain.Product) {
+	if pending, ok := domain.PendingAuditFromContext(ctx); ok {
+		pending.Before = &before
+	}
+}
+
+func (r *ProductRepository) findProducts(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]domain.Product, error) {
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		log.Println("error when try to retrieve products:", err)
+		return nil, domain.ErrInternal
+	}
+	defer cursor.Close(ctx)
+
+	var docs []productDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		log.Println("error when try to decode products:", err)
+		return nil, domain.ErrInternal
+	}
+
+	products := make([]domain.Product, 0, len(docs))
+	for _, doc := range docs {
+		products = append(products, doc.toDomain())
+	}
+	return products, nil
+}
+
+// Next product ID from the counter document of the collection
+func (r *ProductRepository) nextID(ctx context.Context) (int64, error) {
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := r.counters.FindOneAndUpdate(ctx,
+		bson.M{"_id": r.collection.Name()},
+		bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+
+	return counter.Seq, err
+}
+
+// Range condition of the "min-max" or "min" filter format of the product list, nil when invalid
+func productRangeFilter(filter string) bson.M {
+	if filter == "" {
+		return nil
+	}
+
+	minPart, maxPart, isRange := strings.Cut(filter, "-")
+	min, err := strconv.Atoi(minPart)
+	if err != nil {
+		return nil
+	}
+	condition := bson.M{"$gte": min}
+	if isRange {
+		max, err := strconv.Atoi(maxPart)
+		if err != nil {
+			return nil
+		}
+		condition["$lte"] = max
+	}
+	return condition
+}
@@ -2,33 +2,252 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"log"
+	"time"
 
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Name of the TTL index enforcing the profiling retention
+const profilingRetentionIndex = "timestamp_ttl"
+
+// Error codes of MongoDB index commands and writes
+const (
+	mongoIndexNotFound        = 27
+	mongoIndexOptionsConflict = 85
+	mongoDuplicateKey         = 11000
+)
+
+// Latency percentiles of the route stats and their output field
+var profilingPercentiles = []struct {
+	field string
+	rank  float64
+}{
+	{"p50_us", 0.5},
+	{"p90_us", 0.9},
+	{"p99_us", 0.99},
+}
+
 type ProfilingRepository struct {
 	collection *mongo.Collection
 }
 
-func NewProfilingRepository(db *mongo.Database, collectionName string) port.ProfilingRepository {
+func NewProfilingRepository(db *mongo.Database, collectionName string) *ProfilingRepository {
 	return &ProfilingRepository{
 		collection: db.Collection(collectionName),
 	}
 }
 
-func (r *ProfilingRepository) InsertProfilingData(ctx context.Context, data *domain.Profiling) (*domain.Profiling, error) {
-	// startTime := time.Now()
-	result, err := r.collection.InsertOne(ctx, data)
-	if err != nil {
+var _ port.ProfilingRepository = (*ProfilingRepository)(nil)
+
+// Create indexes for the supported profiling filters
+func (r *ProfilingRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "route", Value: 1}, {Key: "timestamp", Value: -1}}},
+	})
+	return err
+}
+
+/*
+ * Keep the records for the retention period with a TTL index on the timestamp,
+ * the period of an existing index is updated and a zero retention removes the index
+ */
+func (r *ProfilingRepository) EnsureRetention(ctx context.Context, retention time.Duration) error {
+	var cmdErr mongo.CommandError
+	if retention <= 0 {
+		_, err := r.collection.Indexes().DropOne(ctx, profilingRetentionIndex)
+		if errors.As(err, &cmdErr) && cmdErr.Code == mongoIndexNotFound {
+			return nil
+		}
+		return err
+	}
+
+	expireAfter := int32(retention.Seconds())
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "timestamp", Value: 1}},
+		Options: options.Index().SetName(profilingRetentionIndex).SetExpireAfterSeconds(expireAfter),
+	})
+	if !errors.As(err, &cmdErr) || cmdErr.Code != mongoIndexOptionsConflict {
+		return err
+	}
+
+	// The index exists with another period
+	return r.collection.Database().RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: r.collection.Name()},
+		{Key: "index", Value: bson.D{
+			{Key: "name", Value: profilingRetentionIndex},
+			{Key: "expireAfterSeconds", Value: expireAfter},
+		}},
+	}).Err()
+}
+
+/*
+ * Unordered insert, so one bad document does not stop the rest of the batch.
+ * A batch written again after a partial failure (e.g. replayed from the spill)
+ * keeps the _id of its records, the ones already stored fail as duplicates
+ * and the batch is successful when they are the only failures
+ */
+func (r *ProfilingRepository) InsertProfilingBatch(ctx context.Context, data []domain.Profiling) error {
+	documents := make([]interface{}, len(data))
+	for i := range data {
+		documents[i] = data[i]
+	}
+
+	_, err := r.collection.InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+	if err != nil && !onlyDuplicateKeys(err) {
 		log.Println("error when try to insert profiling data:", err)
-		return nil, err
+		return err
+	}
+
+	return nil
+}
+
+func onlyDuplicateKeys(err error) bool {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil || len(bulkErr.WriteErrors) == 0 {
+		return false
+	}
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Code != mongoDuplicateKey {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *ProfilingRepository) GetProfilings(ctx context.Context, filter domain.ProfilingFilter) ([]domain.Profiling, int64, error) {
+	query := profilingQuery(ctx, filter)
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}}).
+		SetSkip((filter.Page - 1) * filter.Limit).
+		SetLimit(filter.Limit)
+
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		log.Println("error when try to retrieve profiling data:", err)
+		return nil, 0, domain.ErrInternal
+	}
+	defer cursor.Close(ctx)
+
+	profilings := []domain.Profiling{}
+	if err := cursor.All(ctx, &profilings); err != nil {
+		log.Println("error when try to decode profiling data:", err)
+		return nil, 0, domain.ErrInternal
 	}
 
-	data.ID = result.InsertedID.(primitive.ObjectID)
-	// log.Printf("profiling data inserted, duration: %v\n", time.Since(startTime))
-	return data, nil
+	totalCount, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		log.Println("error when trying to count profiling data:", err)
+		return nil, 0, domain.ErrInternal
+	}
+
+	return profilings, totalCount, nil
+}
+
+/*
+ * Count, error rate and latency percentiles per method and route.
+ * Percentiles are computed by the server with $percentile (MongoDB 7.0 or later),
+ * so the durations of a route are never gathered into one document, an older server
+ * rejecting the operator returns domain.ErrRouteStatsUnsupported.
+ * Records written before duration_us existed fall back to the millisecond duration
+ */
+func (r *ProfilingRepository) GetRouteStats(ctx context.Context, filter domain.ProfilingFilter) ([]domain.RouteStats, error) {
+	ranks := make(bson.A, len(profilingPercentiles))
+	project := bson.M{
+		"_id":        0,
+		"method":     "$_id.method",
+		"route":      "$_id.route",
+		"count":      1,
+		"errors":     1,
+		"error_rate": bson.M{"$divide": bson.A{"$errors", "$count"}},
+	}
+	for i, percentile := range profilingPercentiles {
+		ranks[i] = percentile.rank
+		project[percentile.field] = bson.M{"$toLong": bson.M{"$round": bson.A{
+			bson.M{"$arrayElemAt": bson.A{"$percentiles", i}},
+			0,
+		}}}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: profilingQuery(ctx, filter)}},
+		{{Key: "$addFields", Value: bson.M{
+			"duration_us": bson.M{"$ifNull": bson.A{"$duration_us", bson.M{"$multiply": bson.A{"$duration", 1000}}}},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":    bson.M{"method": "$method", "route": "$route"},
+			"count":  bson.M{"$sum": 1},
+			"errors": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gte": bson.A{"$status", 500}}, 1, 0}}},
+			"percentiles": bson.M{"$percentile": bson.M{
+				"input":  "$duration_us",
+				"p":      ranks,
+				"method": "approximate",
+			}},
+		}}},
+		{{Key: "$project", Value: project}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "route", Value: 1}, {Key: "method", Value: 1}}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		log.Println("error when try to aggregate profiling data:", err)
+		if percentileUnsupported(err) {
+			return nil, domain.ErrRouteStatsUnsupported
+		}
+		return nil, domain.ErrInternal
+	}
+	defer cursor.Close(ctx)
+
+	stats := []domain.RouteStats{}
+	if err := cursor.All(ctx, &stats); err != nil {
+		log.Println("error when try to decode profiling stats:", err)
+		return nil, domain.ErrInternal
+	}
+
+	return stats, nil
+}
+
+// Server errors of a MongoDB older than 7.0, or of a 7.0 server whose feature compatibility version is older
+const (
+	errCodeUnknownGroupOperator   = 15952
+	errCodeQueryFeatureNotAllowed = 224
+)
+
+func percentileUnsupported(err error) bool {
+	var serverErr mongo.ServerError
+	if !errors.As(err, &serverErr) {
+		return false
+	}
+	return serverErr.HasErrorCode(errCodeUnknownGroupOperator) ||
+		(serverErr.HasErrorCode(errCodeQueryFeatureNotAllowed) && serverErr.HasErrorMessage("$percentile"))
+}
+
+func profilingQuery(ctx context.Context, filter domain.ProfilingFilter) bson.M {
+	query := tenantFilter(ctx, bson.M{})
+	if filter.Method != "" {
+		query["method"] = filter.Method
+	}
+	if filter.Route != "" {
+		query["route"] = filter.Route
+	}
+	if filter.Status != 0 {
+		query["status"] = filter.Status
+	}
+	timestamp := bson.M{}
+	if !filter.From.IsZero() {
+		timestamp["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		timestamp["$lte"] = filter.To
+	}
+	if len(timestamp) > 0 {
+		query["timestamp"] = timestamp
+	}
+	return query
 }
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Fields identifying a rollup, $merge replaces the rollup matching them
+var rollupKeyFields = []string{"tenant_id", "method", "route", "period"}
+
+// One bucket per histogram bound, plus the bucket above the last bound
+var rollupBucketCount = len(domain.RollupHistogramBounds) + 1
+
+/*
+ * Profiling rollup repository summarizes the request profiling with $merge,
+ * hours are rolled up from the raw records and days from the hourly rollups,
+ * so raw records are only needed until their hour is rolled up
+ */
+type ProfilingRollupRepository struct {
+	profiling *mongo.Collection
+	hourly    *mongo.Collection
+	daily     *mongo.Collection
+}
+
+func NewProfilingRollupRepository(db *mongo.Database, profilingCollection string, hourlyCollection string, dailyCollection string) *ProfilingRollupRepository {
+	return &ProfilingRollupRepository{
+		profiling: db.Collection(profilingCollection),
+		hourly:    db.Collection(hourlyCollection),
+		daily:     db.Collection(dailyCollection),
+	}
+}
+
+var _ port.ProfilingRollupRepository = (*ProfilingRollupRepository)(nil)
+
+// Create the unique index $merge matches the rollups on, it also serves the trend queries
+func (r *ProfilingRollupRepository) EnsureIndexes(ctx context.Context) error {
+	keys := bson.D{}
+	for _, field := range rollupKeyFields {
+		keys = append(keys, bson.E{Key: field, Value: 1})
+	}
+	for _, collection := range []*mongo.Collection{r.hourly, r.daily} {
+		_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    keys,
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ProfilingRollupRepository) RollupHour(ctx context.Context, hour time.Time) error {
+	// Bucket of a record is the number of bounds its duration is above
+	bucket := bson.M{"$size": bson.M{"$filter": bson.M{
+		"input": domain.RollupHistogramBounds,
+		"cond":  bson.M{"$gt": bson.A{"$duration_us", "$$this"}},
+	}}}
+
+	group := bson.M{
+		"_id":    bson.M{"tenant_id": "$tenant_id", "method": "$method", "route": "$route"},
+		"count":  bson.M{"$sum": 1},
+		"errors": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gte": bson.A{"$status", 500}}, 1, 0}}},
+		"min_us": bson.M{"$min": "$duration_us"},
+		"max_us": bson.M{"$max": "$duration_us"},
+		"sum_us": bson.M{"$sum": "$duration_us"},
+	}
+	for i := 0; i < rollupBucketCount; i++ {
+		group[bucketField(i)] = bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$bucket", i}}, 1, 0}}}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"timestamp": bson.M{"$gte": hour, "$lt": hour.Add(time.Hour)}}}},
+		{{Key: "$addFields", Value: bson.M{
+			"duration_us": bson.M{"$ifNull": bson.A{"$duration_us", bson.M{"$multiply": bson.A{"$duration", 1000}}}},
+		}}},
+		{{Key: "$addFields", Value: bson.M{"bucket": bucket}}},
+		{{Key: "$group", Value: group}},
+	}
+	return r.merge(ctx, r.profiling, pipeline, hour, r.hourly)
+}
+
+func (r *ProfilingRollupRepository) RollupDay(ctx context.Context, day time.Time) error {
+	group := bson.M{
+		"_id":    bson.M{"tenant_id": "$tenant_id", "method": "$method", "route": "$route"},
+		"count":  bson.M{"$sum": "$count"},
+		"errors": bson.M{"$sum": "$errors"},
+		"min_us": bson.M{"$min": "$min_us"},
+		"max_us": bson.M{"$max": "$max_us"},
+		"sum_us": bson.M{"$sum": "$sum_us"},
+	}
+	for i := 0; i < rollupBucketCount; i++ {
+		group[bucketField(i)] = bson.M{"$sum": bson.M{"$arrayElemAt": bson.A{"$buckets", i}}}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"period": bson.M{"$gte": day, "$lt": day.AddDate(0, 0, 1)}}}},
+		{{Key: "$group", Value: group}},
+	}
+	return r.merge(ctx, r.hourly, pipeline, day, r.daily)
+}
+
+func (r *ProfilingRollupRepository) GetRollups(ctx context.Context, filter domain.RollupFilter) ([]domain.ProfilingRollup, error) {
+	collection := r.hourly
+	if filter.Granularity == domain.RollupDaily {
+		collection = r.daily
+	}
+
+	query := tenantFilter(ctx, bson.M{})
+	if filter.Method != "" {
+		query["method"] = filter.Method
+	}
+	if filter.Route != "" {
+		query["route"] = filter.Route
+	}
+	period := bson.M{}
+	if !filter.From.IsZero() {
+		period["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		period["$lte"] = filter.To
+	}
+	if len(period) > 0 {
+		query["period"] = period
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "period", Value: 1}, {Key: "route", Value: 1}, {Key: "method", Value: 1}})
+	cursor, err := collection.Find(ctx, query, opts)
+	if err != nil {
+		log.Println("error when try to retrieve profiling rollups:", err)
+		return nil, domain.ErrInternal
+	}
+	defer cursor.Close(ctx)
+
+	rollups := []domain.ProfilingRollup{}
+	if err := cursor.All(ctx, &rollups); err != nil {
+		log.Println("error when try to decode profiling rollups:", err)
+		return nil, domain.ErrInternal
+	}
+
+	return rollups, nil
+}
+
+// Shape the grouped documents as rollups of the period and merge them into the target collection
+func (r *ProfilingRollupRepository) merge(ctx context.Context, source *mongo.Collection, pipeline mongo.Pipeline, period time.Time, target *mongo.Collection) error {
+	buckets := bson.A{}
+	for i := 0; i < rollupBucketCount; i++ {
+		buckets = append(buckets, "$"+bucketField(i))
+	}
+
+	pipeline = append(pipeline,
+		bson.D{{Key: "$project", Value: bson.M{
+			"_id":       0,
+			"tenant_id": "$_id.tenant_id",
+			"method":    "$_id.method",
+			"route":     "$_id.route",
+			"period":    period,
+			"count":     1,
+			"errors":    1,
+			"min_us":    1,
+			"max_us":    1,
+			"sum_us":    1,
+			"avg_us":    bson.M{"$divide": bson.A{"$sum_us", "$count"}},
+			"buckets":   buckets,
+		}}},
+		bson.D{{Key: "$merge", Value: bson.M{
+			"into":           target.Name(),
+			"on":             rollupKeyFields,
+			"whenMatched":    "replace",
+			"whenNotMatched": "insert",
+		}}},
+	)
+
+	cursor, err := source.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		log.Printf("error when try to roll up profiling into %s: %v", target.Name(), err)
+		return domain.ErrInternal
+	}
+	return cursor.Close(ctx)
+}
+
+func bucketField(i int) string {
+	return fmt.Sprintf("bucket_%d", i)
+}
@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Scope the filter to the tenant of the request, so documents of other tenants are never matched
+func tenantFilter(ctx context.Context, filter bson.M) bson.M {
+	filter["tenant_id"] = domain.TenantIDFromContext(ctx)
+	return filter
+}
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WebhookRepository struct {
+	subscriptions *mongo.Collection
+	deliveries    *mongo.Collection
+}
+
+func NewWebhookRepository(db *mongo.Database, subscriptionCollection string, deliveryCollection string) *WebhookRepository {
+	return &WebhookRepository{
+		subscriptions: db.Collection(subscriptionCollection),
+		deliveries:    db.Collection(deliveryCollection),
+	}
+}
+
+var _ port.WebhookRepository = (*WebhookRepository)(nil)
+
+/*
+ * Create indexes used by the webhook repository,
+ * the unique index makes a delivery of the same event to the same subscription idempotent
+ */
+func (r *WebhookRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.deliveries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "subscription_id", Value: 1}, {Key: "event_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "subscription_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.subscriptions.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "event_types", Value: 1}},
+	})
+	return err
+}
+
+func (r *WebhookRepository) CreateSubscription(ctx context.Context, subscription *domain.WebhookSubscription) (*domain.WebhookSubscription, error) {
+	result, err := r.subscriptions.InsertOne(ctx, subscription)
+	if err != nil {
+		log.Println("error when try to insert webhook subscription:", err)
+		return nil, domain.ErrInternal
+	}
+
+	subscription.ID = result.InsertedID.(primitive.ObjectID)
+	return subscription, nil
+}
+
+func (r *WebhookRepository) GetSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	return r.findSubscriptions(ctx, tenantFilter(ctx, bson.M{}))
+}
+
+func (r *WebhookRepository) GetSubscriptionById(ctx context.Context, id string) (*domain.WebhookSubscription, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrWebhookNotFound
+	}
+
+	var subscription domain.WebhookSubscription
+	err = r.subscriptions.FindOne(ctx, tenantFilter(ctx, bson.M{"_id": objectID})).Decode(&subscription)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, domain.ErrWebhookNotFound
+		}
+		log.Println("error when try to retrieve webhook subscription:", err)
+		return nil, domain.ErrInternal
+	}
+
+	return &subscription, nil
+}
+
+func (r *WebhookRepository) GetSubscriptionsByEventType(ctx context.Context, eventType domain.EventType) ([]domain.WebhookSubscription, error) {
+	return r.findSubscriptions(ctx, tenantFilter(ctx, bson.M{"event_types": eventType}))
+}
+
+func (r *WebhookRepository) DeleteSubscription(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrWebhookNotFound
+	}
+
+	result, err := r.subscriptions.DeleteOne(ctx, tenantFilter(ctx, bson.M{"_id": objectID}))
+	if err != nil {
+		log.Println("error when try to delete webhook subscription:", err)
+		return domain.ErrInternal
+	}
+	if result.DeletedCount == 0 {
+		return domain.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+func (r *WebhookRepository) CreateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	result, err := r.deliveries.InsertOne(ctx, delivery)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			// Event was already queued for this subscription
+			return nil
+		}
+		log.Println("error when try to insert webhook delivery:", err)
+		return domain.ErrInternal
+	}
+
+	delivery.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// Due deliveries of every tenant, only used by the background delivery worker
+func (r *WebhookRepository) GetDueDeliveries(ctx context.Context, now time.Time, limit int64) ([]domain.WebhookDelivery, error) {
+	filter := bson.M{
+		"status":          domain.WebhookDeliveryPending,
+		"next_attempt_at": bson.M{"$lte": now},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetLimit(limit)
+
+	return r.findDeliveries(ctx, filter, opts)
+}
+
+func (r *WebhookRepository) UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	_, err := r.deliveries.ReplaceOne(ctx, bson.M{"_id": delivery.ID}, delivery)
+	if err != nil {
+		log.Println("error when try to update webhook delivery:", err)
+		return domain.ErrInternal
+	}
+
+	return nil
+}
+
+func (r *WebhookRepository) GetDeliveries(ctx context.Context, subscriptionID string, page int64, limit int64) ([]domain.WebhookDelivery, int64, error) {
+	objectID, err := primitive.ObjectIDFromHex(subscriptionID)
+	if err != nil {
+		return nil, 0, domain.ErrWebhookNotFound
+	}
+
+	filter := tenantFilter(ctx, bson.M{"subscription_id": objectID})
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip((page - 1) * limit).
+		SetLimit(limit)
+
+	deliveries, err := r.findDeliveries(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	totalCount, err := r.deliveries.CountDocuments(ctx, filter)
+	if err != nil {
+		log.Println("error when trying to count webhook deliveries:", err)
+		return nil, 0, domain.ErrInternal
+	}
+
+	return deliveries, totalCount, nil
+}
+
+func (r *WebhookRepository) findSubscriptions(ctx context.Context, filter interface{}) ([]domain.WebhookSubscription, error) {
+	cursor, err := r.subscriptions.Find(ctx, filter)
+	if err != nil {
+		log.Println("error when try to retrieve webhook subscriptions:", err)
+		return nil, domain.ErrInternal
+	}
+	defer cursor.Close(ctx)
+
+	subscriptions := []domain.WebhookSubscription{}
+	if err := cursor.All(ctx, &subscriptions); err != nil {
+		log.Println("error when try to decode webhook subscriptions:", err)
+		return nil, domain.ErrInternal
+	}
+
+	return subscriptions, nil
+}
+
+func (r *WebhookRepository) findDeliveries(ctx context.Context, filter interface{}, opts *options.FindOptions) ([]domain.WebhookDelivery, error) {
+	cursor, err := r.deliveries.Find(ctx, filter, opts)
+	if err != nil {
+		log.Println("error when try to retrieve webhook deliveries:", err)
+		return nil, domain.ErrInternal
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := []domain.WebhookDelivery{}
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		log.Println("error when try to decode webhook deliveries:", err)
+		return nil, domain.ErrInternal
+	}
+
+	return deliveries, nil
+}
@@ -3,13 +3,14 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/go-sql-driver/mysql"
-	_ "github.com/go-sql-driver/mysql"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/config"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
 )
 
 /*
@@ -35,10 +36,17 @@ func New(ctx context.Context, config *config.DB) (*DB, error) {
 		config.Name,
 	)
 
-	db, err := sql.Open("mysql", url)
+	mysqlConfig, err := mysql.ParseDSN(url)
 	if err != nil {
 		return nil, err
 	}
+	connector, err := mysql.NewConnector(mysqlConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Statements are timed and added to the query log of the request, see domain.RecordQuery
+	db := sql.OpenDB(NewTracedConnector(connector, domain.RecordQuery))
 
 	// Ping to check the connection
 	if err := db.PingContext(ctx); err != nil {
@@ -54,9 +62,21 @@ func New(ctx context.Context, config *config.DB) (*DB, error) {
 	}, nil
 }
 
+// MySQL server error codes handled by the repositories
+const (
+	// Duplicate entry for a unique key
+	ErrCodeDuplicateEntry = "1062"
+)
+
 // ErrorCode returns the error code of the given error.
 func (db *DB) ErrorCode(err error) string {
-	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+	return ErrorCode(err)
+}
+
+// ErrorCode returns the MySQL error code of the given error, or an empty string for other errors.
+func ErrorCode(err error) string {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
 		return fmt.Sprintf("%d", mysqlErr.Number)
 	}
 	return ""
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+/*
+ * Outbox repository reads the events written by the product repository,
+ * events are returned in insertion order so they can be relayed in order.
+ * Dead lettered events and the events held back by a failed event of the same product
+ * are filtered out by the query, so a batch always has events that can be published.
+ * Only events of the selected types are returned and hold back the others
+ */
+type OutboxRepository struct {
+	db           *sql.DB
+	queryBuilder squirrel.StatementBuilderType
+}
+
+func NewOutboxRepository(db *sql.DB) port.OutboxRepository {
+	return &OutboxRepository{
+		db:           db,
+		queryBuilder: squirrel.StatementBuilder.PlaceholderFormat(squirrel.Question),
+	}
+}
+
+func (r *OutboxRepository) GetPendingEvents(ctx context.Context, selector domain.EventSelector, limit uint64) ([]domain.Event, error) {
+	heldBack := r.queryBuilder.Select("1").
+		From("outbox_events AS f").
+		Where("f.product_id = e.product_id AND f.id < e.id " +
+			"AND f.published_at IS NULL AND f.dead_lettered_at IS NULL AND f.attempts > 0")
+	query := r.queryBuilder.Select("e.id", "e.event_id", "e.tenant_id", "e.event_type", "e.product_id", "e.payload", "e.occurred_at", "e.attempts").
+		From("outbox_events AS e").
+		Where("e.published_at IS NULL").
+		Where("e.dead_lettered_at IS NULL")
+	if len(selector.Types) > 0 {
+		query = query.Where(eventTypeCondition("e.event_type", selector))
+		heldBack = heldBack.Where(eventTypeCondition("f.event_type", selector))
+	}
+
+	heldBackSQL, heldBackArgs, err := heldBack.ToSql()
+	if err != nil {
+		log.Println("error when building outbox held back query", err)
+		return nil, domain.ErrInternal
+	}
+	query = query.
+		Where("NOT EXISTS ("+heldBackSQL+")", heldBackArgs...).
+		OrderBy("e.id ASC").
+		Limit(limit)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		log.Println("error when building outbox select query", err)
+		return nil, domain.ErrInternal
+	}
+
+	rows, err := r.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		log.Println("error when trying to retrieve outbox events", err)
+		return nil, domain.ErrInternal
+	}
+	defer rows.Close()
+
+	events := []domain.Event{}
+	for rows.Next() {
+		var event domain.Event
+		var payload []byte
+		if err := rows.Scan(&event.Sequence, &event.ID, &event.TenantID, &event.Type, &event.ProductID, &payload, &event.OccurredAt, &event.Attempts); err != nil {
+			log.Println("error when scanning outbox event row", err)
+			return nil, domain.ErrInternal
+		}
+		event.Payload = payload
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func (r *OutboxRepository) MarkEventPublished(ctx context.Context, sequence int64) error {
+	query := r.queryBuilder.Update("outbox_events").
+		Set("published_at", squirrel.Expr("NOW(6)")).
+		Set("attempts", squirrel.Expr("attempts + 1")).
+		Set("last_error", nil).
+		Where(squirrel.Eq{"id": sequence})
+
+	return r.exec(ctx, query)
+}
+
+func (r *OutboxRepository) MarkEventFailed(ctx context.Context, sequence int64, reason string) error {
+	query := r.queryBuilder.Update("outbox_events").
+		Set("attempts", squirrel.Expr("attempts + 1")).
+		Set("last_error", reason).
+		Where(squirrel.Eq{"id": sequence})
+
+	return r.exec(ctx, query)
+}
+
+// The event is never relayed again, the events of its product behind it are released
+func (r *OutboxRepository) MarkEventDeadLettered(ctx context.Context, sequence int64, reason string) error {
+	query := r.queryBuilder.Update("outbox_events").
+		Set("attempts", squirrel.Expr("attempts + 1")).
+		Set("last_error", reason).
+		Set("dead_lettered_at", squirrel.Expr("NOW(6)")).
+		Where(squirrel.Eq{"id": sequence})
+
+	return r.exec(ctx, query)
+}
+
+func (r *OutboxRepository) exec(ctx context.Context, query squirrel.UpdateBuilder) error {
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		log.Println("error when building outbox update query", err)
+		return domain.ErrInternal
+	}
+
+	if _, err := r.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		log.Println("error when trying to update outbox event", err)
+		return domain.ErrInternal
+	}
+
+	return nil
+}
+
+// Insert the event into the outbox using the caller transaction
+func insertOutboxEvent(ctx context.Context, tx *sql.Tx, queryBuilder squirrel.StatementBuilderType, event *domain.Event) error {
+	query := queryBuilder.Insert("outbox_events").
+		Columns("event_id", "tenant_id", "event_type", "product_id", "payload", "occurred_at").
+		Values(event.ID, event.TenantID, event.Type, event.ProductID, []byte(event.Payload), event.OccurredAt)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, sqlStr, args...)
+	return err
+}
+
+// Condition on the event type column of the types the selector relays
+func eventTypeCondition(column string, selector domain.EventSelector) squirrel.Sqlizer {
+	types := make([]string, len(selector.Types))
+	for i, eventType := range selector.Types {
+		types[i] = string(eventType)
+	}
+
+	if selector.Exclude {
+		return squirrel.NotEq{column: types}
+	}
+	return squirrel.Eq{column: types}
+}
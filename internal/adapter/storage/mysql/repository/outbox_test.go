@@ -0,0 +1,110 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/storage/mysql/repository"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+ * Test Outbox Repository
+ * Get pending events, of the selected types only, mark published, mark failed, mark dead lettered
+ */
+func TestGetPendingEvents_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewOutboxRepository(db)
+
+	occurredAt := time.Now()
+	mock.ExpectQuery(`^SELECT e\.id, e\.event_id, e\.tenant_id, e\.event_type, e\.product_id, e\.payload, e\.occurred_at, e\.attempts ` +
+		`FROM outbox_events AS e WHERE e\.published_at IS NULL AND e\.dead_lettered_at IS NULL ` +
+		`AND NOT EXISTS \(SELECT 1 FROM outbox_events AS f WHERE f\.product_id = e\.product_id AND f\.id < e\.id ` +
+		`AND f\.published_at IS NULL AND f\.dead_lettered_at IS NULL AND f\.attempts > 0\) ORDER BY e\.id ASC LIMIT 10$`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "tenant_id", "event_type", "product_id", "payload", "occurred_at", "attempts"}).
+			AddRow(1, "evt-1", "brand-a", "ProductCreated", 7, []byte(`{"id":7}`), occurredAt, 2).
+			AddRow(2, "evt-2", "brand-a", "StockAdjusted", 7, []byte(`{"delta":-1}`), occurredAt, 0))
+
+	events, err := repo.GetPendingEvents(context.Background(), domain.EventSelector{}, 10)
+
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, int64(1), events[0].Sequence)
+	assert.Equal(t, 2, events[0].Attempts)
+	assert.Equal(t, "brand-a", events[0].TenantID)
+	assert.Equal(t, domain.EventProductCreated, events[0].Type)
+	assert.Equal(t, domain.EventStockAdjusted, events[1].Type)
+	assert.JSONEq(t, `{"delta":-1}`, string(events[1].Payload))
+}
+
+func TestGetPendingEvents_ExcludedTypes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewOutboxRepository(db)
+
+	// Excluded events are neither returned nor hold back the others
+	query := `^SELECT .* FROM outbox_events AS e WHERE e\.published_at IS NULL AND e\.dead_lettered_at IS NULL ` +
+		`AND e\.event_type NOT IN \(\?\) ` +
+		`AND NOT EXISTS \(SELECT 1 FROM outbox_events AS f WHERE f\.product_id = e\.product_id AND f\.id < e\.id ` +
+		`AND f\.published_at IS NULL AND f\.dead_lettered_at IS NULL AND f\.attempts > 0 AND f\.event_type NOT IN \(\?\)\) ` +
+		`ORDER BY e\.id ASC LIMIT 10$`
+	mock.ExpectQuery(query).
+		WithArgs("AuditRecorded", "AuditRecorded").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "tenant_id", "event_type", "product_id", "payload", "occurred_at", "attempts"}))
+
+	selector := domain.EventSelector{Types: []domain.EventType{domain.EventAuditRecorded}, Exclude: true}
+	events, err := repo.GetPendingEvents(context.Background(), selector, 10)
+
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkEventPublished_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewOutboxRepository(db)
+
+	mock.ExpectExec(`^UPDATE outbox_events SET published_at = NOW\(6\), attempts = attempts \+ 1, last_error = \? WHERE id = \?$`).
+		WithArgs(nil, int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, repo.MarkEventPublished(context.Background(), 1))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkEventFailed_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewOutboxRepository(db)
+
+	mock.ExpectExec(`^UPDATE outbox_events SET attempts = attempts \+ 1, last_error = \? WHERE id = \?$`).
+		WithArgs("broker unavailable", int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, repo.MarkEventFailed(context.Background(), 1, "broker unavailable"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkEventDeadLettered_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewOutboxRepository(db)
+
+	mock.ExpectExec(`^UPDATE outbox_events SET attempts = attempts \+ 1, last_error = \?, dead_lettered_at = NOW\(6\) WHERE id = \?$`).
+		WithArgs("invalid payload", int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, repo.MarkEventDeadLettered(context.Background(), 1, "invalid payload"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
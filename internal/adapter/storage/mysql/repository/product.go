@@ -3,15 +3,28 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 	_ "github.com/go-sql-driver/mysql"
+	mysqldb "github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/storage/mysql"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
 )
 
+var productColumns = []string{"id", "name", "stock", "price", "reorder_threshold", "updated_at"}
+
+// Returned inside a transaction when the statement did not match any row
+var errNoRowsAffected = errors.New("no rows affected")
+
+// Implemented by both *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 type ProductRepository struct {
 	db           *sql.DB
 	queryBuilder squirrel.StatementBuilderType
@@ -25,60 +38,53 @@ func NewProductRepository(db *sql.DB) port.ProductRepository {
 }
 
 func (r *ProductRepository) CreateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error) {
+	product.UpdatedAt = now()
+
 	// Build the insert query
 	query := r.queryBuilder.Insert("products").
-		Columns("name", "stock", "price").
-		Values(product.Name, product.Stock, product.Price)
+		Columns("tenant_id", "name", "stock", "price", "reorder_threshold", "updated_at").
+		Values(domain.TenantIDFromContext(ctx), product.Name, product.Stock, product.Price, product.ReorderThreshold, product.UpdatedAt)
 
 	// Get SQL query and arguments
-	sql, args, err := query.ToSql()
+	sqlStr, args, err := query.ToSql()
 	if err != nil {
 		log.Println("error when building insert query", err)
 		return nil, domain.ErrInternal
 	}
 
-	// Execute the query
-	_, err = r.db.ExecContext(ctx, sql, args...)
-	if err != nil {
-		log.Println("error when trying to insert new product", err)
-		return nil, domain.ErrInternal
-	}
+	err = r.withTx(ctx, func(tx *sql.Tx) error {
+		// Execute the query
+		if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+			if mysqldb.ErrorCode(err) == mysqldb.ErrCodeDuplicateEntry {
+				log.Println("product name already exists", err)
+				return domain.ErrProductAlreadyExists
+			}
+			log.Println("error when trying to insert new product", err)
+			return domain.ErrInternal
+		}
 
-	// Retrieve the last inserted ID
-	var id int64
-	err = r.db.QueryRowContext(ctx, "SELECT LAST_INSERT_ID()").Scan(&id)
+		// Retrieve the last inserted ID
+		var id int64
+		if err := tx.QueryRowContext(ctx, "SELECT LAST_INSERT_ID()").Scan(&id); err != nil {
+			log.Println("error when retrieving last insert ID", err)
+			return domain.ErrInternal
+		}
+		product.ID = id
+
+		if err := r.insertEvent(ctx, tx, domain.EventProductCreated, product.ID, product); err != nil {
+			return err
+		}
+		return r.insertAuditEvent(ctx, tx, product.ID, product)
+	})
 	if err != nil {
-		log.Println("error when retrieving last insert ID", err)
-		return nil, domain.ErrInternal
+		return nil, err
 	}
 
-	product.ID = id
 	return product, nil
 }
 
 func (r *ProductRepository) GetProductById(ctx context.Context, id int64) (*domain.Product, error) {
-	query := r.queryBuilder.Select("id", "name", "stock", "price").
-		From("products").
-		Where(squirrel.Eq{"id": id})
-
-	sqlQueryStr, args, err := query.ToSql()
-	if err != nil {
-		log.Println("error when building select query", err)
-		return nil, domain.ErrInternal
-	}
-
-	row := r.db.QueryRowContext(ctx, sqlQueryStr, args...)
-	var product domain.Product
-	if err := row.Scan(&product.ID, &product.Name, &product.Stock, &product.Price); err != nil {
-		if err == sql.ErrNoRows {
-			log.Println("error when trying to retrieve product, product not found", err)
-			return nil, domain.ErrProductNotFound
-		}
-		log.Println("error when trying to retrieve product", err)
-		return nil, domain.ErrInternal
-	}
-
-	return &product, nil
+	return r.getProductById(ctx, r.db, id)
 }
 
 func (r *ProductRepository) GetProducts(
@@ -91,8 +97,9 @@ func (r *ProductRepository) GetProducts(
 	sortBy string) ([]domain.Product, int64, error) {
 
 	// Create the main query with filters
-	query := r.queryBuilder.Select("id", "name", "stock", "price").
+	query := r.queryBuilder.Select(productColumns...).
 		From("products").
+		Where(tenantScope(ctx)).
 		Limit(limit).
 		Offset((page - 1) * limit)
 
@@ -113,7 +120,6 @@ func (r *ProductRepository) GetProducts(
 		log.Println("error when building select query", err)
 		return nil, 0, domain.ErrInternal
 	}
-	log.Println(sql)
 
 	rows, err := r.db.QueryContext(ctx, sql, args...)
 	if err != nil {
@@ -125,7 +131,7 @@ func (r *ProductRepository) GetProducts(
 	var products []domain.Product
 	for rows.Next() {
 		var product domain.Product
-		if err := rows.Scan(&product.ID, &product.Name, &product.Stock, &product.Price); err != nil {
+		if err := scanProduct(rows, &product); err != nil {
 			log.Println("error when scanning product row", err)
 			return nil, 0, domain.ErrInternal
 		}
@@ -133,7 +139,7 @@ func (r *ProductRepository) GetProducts(
 	}
 
 	// Create the count query with the same filters
-	countQuery := r.queryBuilder.Select("COUNT(id)").From("products")
+	countQuery := r.queryBuilder.Select("COUNT(id)").From("products").Where(tenantScope(ctx))
 	countQuery = applyFilters(countQuery, name, stock, price)
 
 	// Build and execute the count query
@@ -142,7 +148,6 @@ func (r *ProductRepository) GetProducts(
 		log.Println("error when building count query", err)
 		return nil, 0, domain.ErrInternal
 	}
-	log.Println(countSQL)
 
 	countRow := r.db.QueryRowContext(ctx, countSQL, countArgs...)
 	var totalCount int64
@@ -155,32 +160,158 @@ func (r *ProductRepository) GetProducts(
 }
 
 func (r *ProductRepository) UpdateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error) {
+	product.UpdatedAt = now()
+
 	query := r.queryBuilder.Update("products").
 		Set("name", product.Name).
 		Set("stock", product.Stock).
 		Set("price", product.Price).
+		Set("reorder_threshold", product.ReorderThreshold).
+		Set("updated_at", product.UpdatedAt).
+		Where(tenantScope(ctx)).
 		Where(squirrel.Eq{"id": product.ID})
 
-	sql, args, err := query.ToSql()
+	sqlStr, args, err := query.ToSql()
 	if err != nil {
 		log.Println("error when building update query", err)
 		return nil, domain.ErrInternal
 	}
 
-	result, err := r.db.ExecContext(ctx, sql, args...)
+	err = r.withTx(ctx, func(tx *sql.Tx) error {
+		if err := r.lockAuditedProduct(ctx, tx, product.ID); err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, sqlStr, args...)
+		if err != nil {
+			if mysqldb.ErrorCode(err) == mysqldb.ErrCodeDuplicateEntry {
+				log.Println("product name already exists", err)
+				return domain.ErrProductAlreadyExists
+			}
+			log.Println("error when trying to update product", err)
+			return domain.ErrInternal
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			log.Println("error when retrieving affected rows", err)
+			return domain.ErrInternal
+		}
+		if rowsAffected == 0 {
+			log.Println("no matching product found to update")
+			return domain.ErrProductNotFound
+		}
+
+		if err := r.insertEvent(ctx, tx, domain.EventProductUpdated, product.ID, product); err != nil {
+			return err
+		}
+		return r.insertAuditEvent(ctx, tx, product.ID, product)
+	})
 	if err != nil {
-		log.Println("error when trying to update product", err)
+		return nil, err
+	}
+
+	return product, nil
+}
+
+func (r *ProductRepository) GetLowStockProducts(ctx context.Context) ([]domain.Product, error) {
+	query := r.queryBuilder.Select(productColumns...).
+		From("products").
+		Where(tenantScope(ctx)).
+		Where("stock < reorder_threshold").
+		OrderBy("stock ASC")
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		log.Println("error when building low stock query", err)
 		return nil, domain.ErrInternal
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	rows, err := r.db.QueryContext(ctx, sql, args...)
 	if err != nil {
-		log.Println("error when retrieving affected rows", err)
+		log.Println("error when trying to retrieve low stock products", err)
 		return nil, domain.ErrInternal
 	}
-	if rowsAffected == 0 {
-		log.Println("no matching product found to update")
-		return nil, domain.ErrProductNotFound
+	defer rows.Close()
+
+	products := []domain.Product{}
+	for rows.Next() {
+		var product domain.Product
+		if err := scanProduct(rows, &product); err != nil {
+			log.Println("error when scanning product row", err)
+			return nil, domain.ErrInternal
+		}
+		products = append(products, product)
+	}
+
+	return products, nil
+}
+
+/*
+ * Add delta to the product stock in a single statement,
+ * the update is refused when it would make the stock negative
+ */
+func (r *ProductRepository) AdjustStock(ctx context.Context, id int64, delta int) (*domain.Product, error) {
+	query := r.queryBuilder.Update("products").
+		Set("stock", squirrel.Expr("stock + ?", delta)).
+		Set("updated_at", now()).
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"id": id}).
+		Where("stock + ? >= 0", delta)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		log.Println("error when building adjust stock query", err)
+		return nil, domain.ErrInternal
+	}
+
+	var product *domain.Product
+	err = r.withTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, sqlStr, args...)
+		if err != nil {
+			log.Println("error when trying to adjust product stock", err)
+			return domain.ErrInternal
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			log.Println("error when retrieving affected rows", err)
+			return domain.ErrInternal
+		}
+		if rowsAffected == 0 {
+			return errNoRowsAffected
+		}
+
+		product, err = r.getProductById(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if pending, ok := domain.PendingAuditFromContext(ctx); ok {
+			// The single statement update leaves no other field changed
+			before := *product
+			before.Stock -= delta
+			pending.Before = &before
+		}
+
+		if err := r.insertEvent(ctx, tx, domain.EventStockAdjusted, id, domain.StockAdjustment{
+			ProductID: id,
+			Delta:     delta,
+			Stock:     product.Stock,
+		}); err != nil {
+			return err
+		}
+		return r.insertAuditEvent(ctx, tx, id, product)
+	})
+	if err == errNoRowsAffected {
+		// Either the product does not exist or the stock is not enough
+		if _, err := r.GetProductById(ctx, id); err != nil {
+			return nil, err
+		}
+		log.Println("product stock is not enough to adjust")
+		return nil, domain.ErrInsufficientStock
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	return product, nil
@@ -188,31 +319,46 @@ func (r *ProductRepository) UpdateProduct(ctx context.Context, product *domain.P
 
 func (r *ProductRepository) DeleteProduct(ctx context.Context, id int64) error {
 	query := r.queryBuilder.Delete("products").
+		Where(tenantScope(ctx)).
 		Where(squirrel.Eq{"id": id})
 
-	sql, args, err := query.ToSql()
+	sqlStr, args, err := query.ToSql()
 	if err != nil {
 		log.Println("error when building delete query", err)
 		return domain.ErrInternal
 	}
 
-	result, err := r.db.ExecContext(ctx, sql, args...)
-	if err != nil {
-		log.Println("error when trying to delete product", err)
-		return domain.ErrInternal
-	}
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		if err := r.lockAuditedProduct(ctx, tx, id); err != nil {
+			return err
+		}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		log.Println("error when retrieving affected rows", err)
-		return domain.ErrInternal
-	}
-	if rowsAffected == 0 {
-		log.Println("no matching product found to delete")
-		return domain.ErrProductNotFound
-	}
+		result, err := tx.ExecContext(ctx, sqlStr, args...)
+		if err != nil {
+			log.Println("error when trying to delete product", err)
+			return domain.ErrInternal
+		}
 
-	return nil
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			log.Println("error when retrieving affected rows", err)
+			return domain.ErrInternal
+		}
+		if rowsAffected == 0 {
+			log.Println("no matching product found to delete")
+			return domain.ErrProductNotFound
+		}
+
+		if err := r.insertEvent(ctx, tx, domain.EventProductDeleted, id, domain.Product{ID: id}); err != nil {
+			return err
+		}
+		return r.insertAuditEvent(ctx, tx, id, nil)
+	})
+}
+
+// Every product query is scoped to the tenant of the request, so other tenants rows are never matched
+func tenantScope(ctx context.Context) squirrel.Eq {
+	return squirrel.Eq{"tenant_id": domain.TenantIDFromContext(ctx)}
 }
 
 func applyFilters(query squirrel.SelectBuilder, name string, stock string, price string) squirrel.SelectBuilder {
@@ -243,3 +389,126 @@ func applyFilters(query squirrel.SelectBuilder, name string, stock string, price
 
 	return query
 }
+
+func (r *ProductRepository) getProductById(ctx context.Context, q queryRower, id int64) (*domain.Product, error) {
+	query := r.queryBuilder.Select(productColumns...).
+		From("products").
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"id": id})
+
+	sqlQueryStr, args, err := query.ToSql()
+	if err != nil {
+		log.Println("error when building select query", err)
+		return nil, domain.ErrInternal
+	}
+
+	row := q.QueryRowContext(ctx, sqlQueryStr, args...)
+	var product domain.Product
+	if err := scanProduct(row, &product); err != nil {
+		if err == sql.ErrNoRows {
+			log.Println("error when trying to retrieve product, product not found", err)
+			return nil, domain.ErrProductNotFound
+		}
+		log.Println("error when trying to retrieve product", err)
+		return nil, domain.ErrInternal
+	}
+
+	return &product, nil
+}
+
+/*
+ * Run fn inside a transaction, so the product change and its outbox event
+ * are committed together, the transaction is rolled back when fn fails
+ */
+func (r *ProductRepository) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("error when starting transaction", err)
+		return domain.ErrInternal
+	}
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			log.Println("error when rolling back transaction", rbErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("error when committing transaction", err)
+		return domain.ErrInternal
+	}
+
+	return nil
+}
+
+// Write the event to the outbox as part of the given transaction
+func (r *ProductRepository) insertEvent(ctx context.Context, tx *sql.Tx, eventType domain.EventType, productID int64, payload interface{}) error {
+	event, err := domain.NewEvent(eventType, productID, payload)
+	if err != nil {
+		log.Println("error when creating event", err)
+		return domain.ErrInternal
+	}
+	event.TenantID = domain.TenantIDFromContext(ctx)
+
+	if err := insertOutboxEvent(ctx, tx, r.queryBuilder, event); err != nil {
+		log.Println("error when trying to insert outbox event", err)
+		return domain.ErrInternal
+	}
+
+	return nil
+}
+
+/*
+ * Write the AuditRecorded event of the audit pending in the context as part of the given transaction,
+ * so the audit entry is relayed if and only if the change is committed
+ */
+func (r *ProductRepository) insertAuditEvent(ctx context.Context, tx *sql.Tx, productID int64, after *domain.Product) error {
+	pending, ok := domain.PendingAuditFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return r.insertEvent(ctx, tx, domain.EventAuditRecorded, productID, domain.NewAuditRecord(ctx, pending.Action, productID, pending.Before, after))
+}
+
+// Read the product the write replaces for the audit pending in the context, locked until the transaction ends
+func (r *ProductRepository) lockAuditedProduct(ctx context.Context, tx *sql.Tx, id int64) error {
+	pending, ok := domain.PendingAuditFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	query := r.queryBuilder.Select(productColumns...).
+		From("products").
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"id": id}).
+		Suffix("FOR UPDATE")
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		log.Println("error when building select for update query", err)
+		return domain.ErrInternal
+	}
+
+	var before domain.Product
+	if err := scanProduct(tx.QueryRowContext(ctx, sqlStr, args...), &before); err != nil {
+		if err == sql.ErrNoRows {
+			return domain.ErrProductNotFound
+		}
+		log.Println("error when trying to lock product", err)
+		return domain.ErrInternal
+	}
+	pending.Before = &before
+
+	return nil
+}
+
+// Scan a product row selected with productColumns
+func scanProduct(row interface{ Scan(dest ...any) error }, product *domain.Product) error {
+	return row.Scan(&product.ID, &product.Name, &product.Stock, &product.Price, &product.ReorderThreshold, &product.UpdatedAt)
+}
+
+// Current time in the precision of the updated_at column
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
@@ -4,14 +4,18 @@ import (
 	"context"
 	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/storage/mysql/repository"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+var updatedAt = time.Date(2024, 8, 1, 10, 0, 0, 0, time.UTC)
+
 func setupTestDB(t *testing.T) (*repository.ProductRepository, *sql.DB, sqlmock.Sqlmock) {
 	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
@@ -21,7 +25,7 @@ func setupTestDB(t *testing.T) (*repository.ProductRepository, *sql.DB, sqlmock.
 
 /*
  * Test Create Product
- * Success, Invalid Data (price)
+ * Success, Invalid Data (price), Duplicate Name
  */
 func TestCreateProduct_Success(t *testing.T) {
 	repo, db, mock := setupTestDB(t)
@@ -30,14 +34,21 @@ func TestCreateProduct_Success(t *testing.T) {
 	product := &domain.Product{Name: "Samsung A12", Stock: 10, Price: 4500000}
 
 	// Set up the expected behavior for the INSERT query
+	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO products").
-		WithArgs(product.Name, product.Stock, product.Price).
+		WithArgs(domain.DefaultTenantID, product.Name, product.Stock, product.Price, product.ReorderThreshold, sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	// Set up the expected behavior for retrieving the last inserted ID
 	mock.ExpectQuery("SELECT LAST_INSERT_ID()").
 		WillReturnRows(sqlmock.NewRows([]string{"LAST_INSERT_ID()"}).AddRow(1))
 
+	// The created event is written to the outbox in the same transaction
+	mock.ExpectExec("INSERT INTO outbox_events").
+		WithArgs(sqlmock.AnyArg(), domain.DefaultTenantID, domain.EventProductCreated, int64(1), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
 	// Call the repository method
 	createdProduct, err := repo.CreateProduct(context.Background(), product)
 
@@ -59,9 +70,11 @@ func TestCreateProduct_InvalidData(t *testing.T) {
 	// Invalid price below 1
 	product := &domain.Product{Name: "Samsung A12", Stock: 10, Price: -4500000}
 
+	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO products").
-		WithArgs(product.Name, product.Stock, product.Price).
+		WithArgs(domain.DefaultTenantID, product.Name, product.Stock, product.Price, product.ReorderThreshold, sqlmock.AnyArg()).
 		WillReturnError(domain.ErrInternal)
+	mock.ExpectRollback()
 
 	createdProduct, err := repo.CreateProduct(context.Background(), product)
 
@@ -70,6 +83,25 @@ func TestCreateProduct_InvalidData(t *testing.T) {
 	assert.Equal(t, domain.ErrInternal, err)
 }
 
+func TestCreateProduct_DuplicateName(t *testing.T) {
+	repo, db, mock := setupTestDB(t)
+	defer db.Close()
+
+	product := &domain.Product{Name: "Samsung A12", Stock: 10, Price: 4500000}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO products").
+		WithArgs(domain.DefaultTenantID, product.Name, product.Stock, product.Price, product.ReorderThreshold, sqlmock.AnyArg()).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'default-Samsung A12' for key 'uq_products_tenant_name'"})
+	mock.ExpectRollback()
+
+	createdProduct, err := repo.CreateProduct(context.Background(), product)
+
+	assert.Nil(t, createdProduct)
+	assert.ErrorIs(t, err, domain.ErrProductAlreadyExists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 /*
  * Test Get Product By Id
  * Success, Product Not Found
@@ -84,12 +116,14 @@ func TestGetProductById_Success(t *testing.T) {
 		Name:  "Samsung A12",
 		Stock: 10,
 		Price: 4500000,
+		// Scanned from the updated_at column
+		UpdatedAt: updatedAt,
 	}
 
-	mock.ExpectQuery("SELECT id, name, stock, price FROM products WHERE id = ?").
-		WithArgs(productID).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "price"}).
-			AddRow(expectedProduct.ID, expectedProduct.Name, expectedProduct.Stock, expectedProduct.Price))
+	mock.ExpectQuery(`^SELECT id, name, stock, price, reorder_threshold, updated_at FROM products WHERE tenant_id = \? AND id = \?$`).
+		WithArgs(domain.DefaultTenantID, productID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "price", "reorder_threshold", "updated_at"}).
+			AddRow(expectedProduct.ID, expectedProduct.Name, expectedProduct.Stock, expectedProduct.Price, expectedProduct.ReorderThreshold, updatedAt))
 
 	product, err := repo.GetProductById(context.Background(), productID)
 
@@ -102,9 +136,9 @@ func TestGetProductById_NotFound(t *testing.T) {
 	defer db.Close()
 
 	var productID int64 = 99
-	mock.ExpectQuery("SELECT id, name, stock, price FROM products WHERE id = ?").
-		WithArgs(productID).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "price"}))
+	mock.ExpectQuery(`^SELECT id, name, stock, price, reorder_threshold, updated_at FROM products WHERE tenant_id = \? AND id = \?$`).
+		WithArgs(domain.DefaultTenantID, productID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "price", "reorder_threshold", "updated_at"}))
 
 	product, err := repo.GetProductById(context.Background(), productID)
 
@@ -122,13 +156,15 @@ func TestGetProducts_Pagination(t *testing.T) {
 	defer db.Close()
 
 	// Mock the SQL query for default pagination (page 1, limit 10)
-	mock.ExpectQuery(`^SELECT id, name, stock, price FROM products LIMIT 10 OFFSET 0$`).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "price"}).
-			AddRow(1, "Product 1", 20, 3000).
-			AddRow(2, "Product 2", 30, 4000))
+	mock.ExpectQuery(`^SELECT id, name, stock, price, reorder_threshold, updated_at FROM products WHERE tenant_id = \? LIMIT 10 OFFSET 0$`).
+		WithArgs(domain.DefaultTenantID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "price", "reorder_threshold", "updated_at"}).
+			AddRow(1, "Product 1", 20, 3000, 0, updatedAt).
+			AddRow(2, "Product 2", 30, 4000, 0, updatedAt))
 
 	// Mock the SQL query to count the total number of products
-	mock.ExpectQuery(`^SELECT COUNT\(id\) FROM products$`).
+	mock.ExpectQuery(`^SELECT COUNT\(id\) FROM products WHERE tenant_id = \?$`).
+		WithArgs(domain.DefaultTenantID).
 		WillReturnRows(sqlmock.NewRows([]string{"COUNT(id)"}).AddRow(2))
 
 	// Call the method with default pagination (page 1, limit 10)
@@ -145,15 +181,15 @@ func TestGetProducts_WithNameFilter(t *testing.T) {
 	defer db.Close()
 
 	// Mock the SQL query for filtering by name "Samsung"
-	mock.ExpectQuery(`^SELECT id, name, stock, price FROM products WHERE name LIKE \? LIMIT 10 OFFSET 0$`).
-		WithArgs("%Samsung%").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "price"}).
-			AddRow(1, "Samsung Galaxy S20", 50, 1000).
-			AddRow(2, "Samsung Galaxy Note 20", 40, 1200))
+	mock.ExpectQuery(`^SELECT id, name, stock, price, reorder_threshold, updated_at FROM products WHERE tenant_id = \? AND name LIKE \? LIMIT 10 OFFSET 0$`).
+		WithArgs(domain.DefaultTenantID, "%Samsung%").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "price", "reorder_threshold", "updated_at"}).
+			AddRow(1, "Samsung Galaxy S20", 50, 1000, 0, updatedAt).
+			AddRow(2, "Samsung Galaxy Note 20", 40, 1200, 0, updatedAt))
 
 	// Mock the SQL query to count the total number of products matching the name filter
-	mock.ExpectQuery(`^SELECT COUNT\(id\) FROM products WHERE name LIKE \?$`).
-		WithArgs("%Samsung%").
+	mock.ExpectQuery(`^SELECT COUNT\(id\) FROM products WHERE tenant_id = \? AND name LIKE \?$`).
+		WithArgs(domain.DefaultTenantID, "%Samsung%").
 		WillReturnRows(sqlmock.NewRows([]string{"COUNT(id)"}).AddRow(2))
 
 	// Call the method with name filter "Samsung" and default pagination (page 1, limit 10)
@@ -170,13 +206,15 @@ func TestGetProducts_SortingDesc(t *testing.T) {
 	defer db.Close()
 
 	// Mock the SQL query for sorting by name in descending order
-	mock.ExpectQuery(`(?i)^SELECT id, name, stock, price FROM products ORDER BY name DESC LIMIT 10 OFFSET 0$`).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "price"}).
-			AddRow(1, "Samsung Galaxy A2", 40, 1200).
-			AddRow(2, "Samsung Galaxy A1", 50, 1000))
+	mock.ExpectQuery(`(?i)^SELECT id, name, stock, price, reorder_threshold, updated_at FROM products WHERE tenant_id = \? ORDER BY name DESC LIMIT 10 OFFSET 0$`).
+		WithArgs(domain.DefaultTenantID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "price", "reorder_threshold", "updated_at"}).
+			AddRow(1, "Samsung Galaxy A2", 40, 1200, 0, updatedAt).
+			AddRow(2, "Samsung Galaxy A1", 50, 1000, 0, updatedAt))
 
 	// Mock the SQL query to count the total number of products
-	mock.ExpectQuery(`^SELECT COUNT\(id\) FROM products$`).
+	mock.ExpectQuery(`^SELECT COUNT\(id\) FROM products WHERE tenant_id = \?$`).
+		WithArgs(domain.DefaultTenantID).
 		WillReturnRows(sqlmock.NewRows([]string{"COUNT(id)"}).AddRow(2))
 
 	// Call the method with sorting by name in descending order and default pagination (page 1, limit 10)
@@ -195,11 +233,13 @@ func TestGetProducts_NoResults(t *testing.T) {
 	defer db.Close()
 
 	// Mock the SQL query for retrieving products with no results
-	mock.ExpectQuery(`^SELECT id, name, stock, price FROM products LIMIT 10 OFFSET 0$`).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "price"}))
+	mock.ExpectQuery(`^SELECT id, name, stock, price, reorder_threshold, updated_at FROM products WHERE tenant_id = \? LIMIT 10 OFFSET 0$`).
+		WithArgs(domain.DefaultTenantID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "price", "reorder_threshold", "updated_at"}))
 
 	// Mock the SQL query to count the total number of products (should return 0)
-	mock.ExpectQuery(`^SELECT COUNT\(id\) FROM products$`).
+	mock.ExpectQuery(`^SELECT COUNT\(id\) FROM products WHERE tenant_id = \?$`).
+		WithArgs(domain.DefaultTenantID).
 		WillReturnRows(sqlmock.NewRows([]string{"COUNT(id)"}).AddRow(0))
 
 	// Call the method with default pagination (page 1, limit 10)
@@ -213,7 +253,8 @@ func TestGetProducts_NoResults(t *testing.T) {
 
 /*
  * Test Update Product
- * Success, Product Not Found
+ * Success, Product Not Found, Duplicate Name, pending audit reads the replaced product
+ * in the transaction and is written to the outbox with the change
  */
 func TestUpdateProduct_Success(t *testing.T) {
 	repo, db, mock := setupTestDB(t)
@@ -227,9 +268,14 @@ func TestUpdateProduct_Success(t *testing.T) {
 		Price: 2000,
 	}
 
-	mock.ExpectExec(`^UPDATE products SET name = \?, stock = \?, price = \? WHERE id = \?$`).
-		WithArgs(updateProduct.Name, updateProduct.Stock, updateProduct.Price, productID).
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE products SET name = \?, stock = \?, price = \?, reorder_threshold = \?, updated_at = \? WHERE tenant_id = \? AND id = \?$`).
+		WithArgs(updateProduct.Name, updateProduct.Stock, updateProduct.Price, updateProduct.ReorderThreshold, sqlmock.AnyArg(), domain.DefaultTenantID, productID).
 		WillReturnResult(sqlmock.NewResult(1, 1)) // 1 row affected
+	mock.ExpectExec("INSERT INTO outbox_events").
+		WithArgs(sqlmock.AnyArg(), domain.DefaultTenantID, domain.EventProductUpdated, productID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	updatedProduct, err := repo.UpdateProduct(context.Background(), &updateProduct)
 
@@ -241,6 +287,36 @@ func TestUpdateProduct_Success(t *testing.T) {
 	assert.Equal(t, updateProduct.Price, updatedProduct.Price)
 }
 
+func TestUpdateProduct_WritesPendingAudit(t *testing.T) {
+	repo, db, mock := setupTestDB(t)
+	defer db.Close()
+
+	productID := int64(1)
+	updateProduct := domain.Product{ID: productID, Name: "Updated Product", Stock: 50, Price: 2000}
+	ctx, pending := domain.WithPendingAudit(domain.WithActor(context.Background(), "alice"), domain.AuditActionUpdate)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT id, name, stock, price, reorder_threshold, updated_at FROM products WHERE tenant_id = \? AND id = \? FOR UPDATE$`).
+		WithArgs(domain.DefaultTenantID, productID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "price", "reorder_threshold", "updated_at"}).
+			AddRow(productID, "Updated Product", 50, 1500, 0, updatedAt))
+	mock.ExpectExec("UPDATE products").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO outbox_events").
+		WithArgs(sqlmock.AnyArg(), domain.DefaultTenantID, domain.EventProductUpdated, productID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO outbox_events").
+		WithArgs(sqlmock.AnyArg(), domain.DefaultTenantID, domain.EventAuditRecorded, productID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	_, err := repo.UpdateProduct(ctx, &updateProduct)
+
+	assert.NoError(t, err)
+	require.NotNil(t, pending.Before)
+	assert.Equal(t, 1500, pending.Before.Price)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestUpdateProduct_NotFound(t *testing.T) {
 	repo, db, mock := setupTestDB(t)
 	defer db.Close()
@@ -253,9 +329,11 @@ func TestUpdateProduct_NotFound(t *testing.T) {
 		Price: 2000,
 	}
 
-	mock.ExpectExec(`^UPDATE products SET name = \?, stock = \?, price = \? WHERE id = \?$`).
-		WithArgs(updateProduct.Name, updateProduct.Stock, updateProduct.Price, productID).
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE products SET name = \?, stock = \?, price = \?, reorder_threshold = \?, updated_at = \? WHERE tenant_id = \? AND id = \?$`).
+		WithArgs(updateProduct.Name, updateProduct.Stock, updateProduct.Price, updateProduct.ReorderThreshold, sqlmock.AnyArg(), domain.DefaultTenantID, productID).
 		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
 
 	updatedProduct, err := repo.UpdateProduct(context.Background(), &updateProduct)
 
@@ -264,6 +342,24 @@ func TestUpdateProduct_NotFound(t *testing.T) {
 	assert.Equal(t, domain.ErrProductNotFound, err)
 }
 
+func TestUpdateProduct_DuplicateName(t *testing.T) {
+	repo, db, mock := setupTestDB(t)
+	defer db.Close()
+
+	updateProduct := domain.Product{ID: 1, Name: "Samsung A12", Stock: 50, Price: 2000}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE products SET`).
+		WithArgs(updateProduct.Name, updateProduct.Stock, updateProduct.Price, updateProduct.ReorderThreshold, sqlmock.AnyArg(), domain.DefaultTenantID, updateProduct.ID).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	mock.ExpectRollback()
+
+	updatedProduct, err := repo.UpdateProduct(context.Background(), &updateProduct)
+
+	assert.Nil(t, updatedProduct)
+	assert.ErrorIs(t, err, domain.ErrProductAlreadyExists)
+}
+
 /*
  * Test Delete Product
  * Success, Product Not Found
@@ -274,9 +370,14 @@ func TestDeleteProduct_Success(t *testing.T) {
 
 	productID := int64(1)
 
-	mock.ExpectExec(`^DELETE FROM products WHERE id = \?$`).
-		WithArgs(productID).
+	mock.ExpectBegin()
+	mock.ExpectExec(`^DELETE FROM products WHERE tenant_id = \? AND id = \?$`).
+		WithArgs(domain.DefaultTenantID, productID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO outbox_events").
+		WithArgs(sqlmock.AnyArg(), domain.DefaultTenantID, domain.EventProductDeleted, productID, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	err := repo.DeleteProduct(context.Background(), productID)
 
@@ -289,12 +390,128 @@ func TestDeleteProduct_NotFound(t *testing.T) {
 
 	productID := int64(99)
 
-	mock.ExpectExec(`^DELETE FROM products WHERE id = \?$`).
-		WithArgs(productID).
+	mock.ExpectBegin()
+	mock.ExpectExec(`^DELETE FROM products WHERE tenant_id = \? AND id = \?$`).
+		WithArgs(domain.DefaultTenantID, productID).
 		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
 
 	err := repo.DeleteProduct(context.Background(), productID)
 
 	assert.Error(t, err)
 	assert.Equal(t, domain.ErrProductNotFound, err)
 }
+
+/*
+ * Test Get Low Stock Products
+ * Success
+ */
+func TestGetLowStockProducts_Success(t *testing.T) {
+	repo, db, mock := setupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery(`^SELECT id, name, stock, price, reorder_threshold, updated_at FROM products WHERE tenant_id = \? AND stock < reorder_threshold ORDER BY stock ASC$`).
+		WithArgs(domain.DefaultTenantID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "price", "reorder_threshold", "updated_at"}).
+			AddRow(1, "Samsung A12", 2, 4500000, 5, updatedAt))
+
+	products, err := repo.GetLowStockProducts(context.Background())
+
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.True(t, products[0].IsLowStock())
+}
+
+/*
+ * Test Adjust Stock
+ * Success, Insufficient Stock, Product Not Found
+ */
+func TestAdjustStock_Success(t *testing.T) {
+	repo, db, mock := setupTestDB(t)
+	defer db.Close()
+
+	productID := int64(1)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE products SET stock = stock \+ \?, updated_at = \? WHERE tenant_id = \? AND id = \? AND stock \+ \? >= 0$`).
+		WithArgs(-3, sqlmock.AnyArg(), domain.DefaultTenantID, productID, -3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`^SELECT id, name, stock, price, reorder_threshold, updated_at FROM products WHERE tenant_id = \? AND id = \?$`).
+		WithArgs(domain.DefaultTenantID, productID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "price", "reorder_threshold", "updated_at"}).
+			AddRow(productID, "Samsung A12", 7, 4500000, 5, updatedAt))
+	mock.ExpectExec("INSERT INTO outbox_events").
+		WithArgs(sqlmock.AnyArg(), domain.DefaultTenantID, domain.EventStockAdjusted, productID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	product, err := repo.AdjustStock(context.Background(), productID, -3)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 7, product.Stock)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdjustStock_InsufficientStock(t *testing.T) {
+	repo, db, mock := setupTestDB(t)
+	defer db.Close()
+
+	productID := int64(1)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE products SET stock = stock \+ \?, updated_at = \? WHERE tenant_id = \? AND id = \? AND stock \+ \? >= 0$`).
+		WithArgs(-20, sqlmock.AnyArg(), domain.DefaultTenantID, productID, -20).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	mock.ExpectQuery(`^SELECT id, name, stock, price, reorder_threshold, updated_at FROM products WHERE tenant_id = \? AND id = \?$`).
+		WithArgs(domain.DefaultTenantID, productID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "price", "reorder_threshold", "updated_at"}).
+			AddRow(productID, "Samsung A12", 10, 4500000, 5, updatedAt))
+
+	product, err := repo.AdjustStock(context.Background(), productID, -20)
+
+	assert.Nil(t, product)
+	assert.Equal(t, domain.ErrInsufficientStock, err)
+}
+
+func TestAdjustStock_NotFound(t *testing.T) {
+	repo, db, mock := setupTestDB(t)
+	defer db.Close()
+
+	productID := int64(99)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE products SET stock = stock \+ \?, updated_at = \? WHERE tenant_id = \? AND id = \? AND stock \+ \? >= 0$`).
+		WithArgs(5, sqlmock.AnyArg(), domain.DefaultTenantID, productID, 5).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	mock.ExpectQuery(`^SELECT id, name, stock, price, reorder_threshold, updated_at FROM products WHERE tenant_id = \? AND id = \?$`).
+		WithArgs(domain.DefaultTenantID, productID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "price", "reorder_threshold", "updated_at"}))
+
+	product, err := repo.AdjustStock(context.Background(), productID, 5)
+
+	assert.Nil(t, product)
+	assert.Equal(t, domain.ErrProductNotFound, err)
+}
+
+/*
+ * Test Tenant Isolation
+ * Queries use the tenant from the context
+ */
+func TestGetProductById_OtherTenant(t *testing.T) {
+	repo, db, mock := setupTestDB(t)
+	defer db.Close()
+
+	var productID int64 = 1
+	mock.ExpectQuery(`^SELECT id, name, stock, price, reorder_threshold, updated_at FROM products WHERE tenant_id = \? AND id = \?$`).
+		WithArgs("brand-b", productID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "price", "reorder_threshold", "updated_at"}))
+
+	ctx := domain.WithTenantID(context.Background(), "brand-b")
+	product, err := repo.GetProductById(ctx, productID)
+
+	assert.Nil(t, product)
+	assert.Equal(t, domain.ErrProductNotFound, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
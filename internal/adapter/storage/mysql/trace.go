@@ -0,0 +1,350 @@
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+)
+
+// Called once per statement with its fingerprint, duration, rows and error
+type QueryHook func(ctx context.Context, query domain.QueryTrace)
+
+/*
+ * Traced connector wraps the connections of a driver,
+ * so every statement run through database/sql is reported to the hook,
+ * including the ones of prepared statements and transactions.
+ * A query is reported when its rows are closed, so the duration
+ * includes reading the rows
+ */
+type tracedConnector struct {
+	connector driver.Connector
+	hook      QueryHook
+}
+
+func NewTracedConnector(connector driver.Connector, hook QueryHook) driver.Connector {
+	return &tracedConnector{connector: connector, hook: hook}
+}
+
+func (c *tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{conn: conn, hook: c.hook}, nil
+}
+
+func (c *tracedConnector) Driver() driver.Driver {
+	return c.connector.Driver()
+}
+
+// ErrSkip only tells database/sql to take another path, that path is reported instead
+func report(ctx context.Context, hook QueryHook, query string, start time.Time, rows int64, err error) {
+	if errors.Is(err, driver.ErrSkip) {
+		return
+	}
+
+	trace := domain.QueryTrace{
+		Fingerprint: Fingerprint(query),
+		DurationUs:  time.Since(start).Microseconds(),
+		Rows:        rows,
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		trace.Error = err.Error()
+	}
+	hook(ctx, trace)
+}
+
+type tracedConn struct {
+	conn driver.Conn
+	hook QueryHook
+}
+
+func (c *tracedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tracedStmt{stmt: stmt, query: query, hook: c.hook}, nil
+}
+
+func (c *tracedConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *tracedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.conn.Begin()
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		report(ctx, c.hook, query, start, 0, err)
+		return nil, err
+	}
+	return &tracedRows{rows: rows, query: query, ctx: ctx, start: start, hook: c.hook}, nil
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	report(ctx, c.hook, query, start, rowsAffected(result, err), err)
+	return result, err
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *tracedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type tracedStmt struct {
+	stmt  driver.Stmt
+	query string
+	hook  QueryHook
+}
+
+func (s *tracedStmt) Close() error {
+	return s.stmt.Close()
+}
+
+func (s *tracedStmt) NumInput() int {
+	return s.stmt.NumInput()
+}
+
+func (s *tracedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	start := time.Now()
+	result, err := s.stmt.Exec(args)
+	report(context.Background(), s.hook, s.query, start, rowsAffected(result, err), err)
+	return result, err
+}
+
+func (s *tracedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	ctx := context.Background()
+	start := time.Now()
+	rows, err := s.stmt.Query(args)
+	if err != nil {
+		report(ctx, s.hook, s.query, start, 0, err)
+		return nil, err
+	}
+	return &tracedRows{rows: rows, query: s.query, ctx: ctx, start: start, hook: s.hook}, nil
+}
+
+func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := s.stmt.(driver.StmtExecContext)
+	if !ok {
+		values, err := namedValues(args)
+		if err != nil {
+			return nil, err
+		}
+		return s.Exec(values)
+	}
+
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, args)
+	report(ctx, s.hook, s.query, start, rowsAffected(result, err), err)
+	return result, err
+}
+
+func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := s.stmt.(driver.StmtQueryContext)
+	if !ok {
+		values, err := namedValues(args)
+		if err != nil {
+			return nil, err
+		}
+		return s.Query(values)
+	}
+
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, args)
+	if err != nil {
+		report(ctx, s.hook, s.query, start, 0, err)
+		return nil, err
+	}
+	return &tracedRows{rows: rows, query: s.query, ctx: ctx, start: start, hook: s.hook}, nil
+}
+
+func (s *tracedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func (s *tracedStmt) ColumnConverter(idx int) driver.ValueConverter {
+	if converter, ok := s.stmt.(driver.ColumnConverter); ok {
+		return converter.ColumnConverter(idx)
+	}
+	return driver.DefaultParameterConverter
+}
+
+// Counts the rows read and reports the query when closed
+type tracedRows struct {
+	rows     driver.Rows
+	query    string
+	ctx      context.Context
+	start    time.Time
+	hook     QueryHook
+	count    int64
+	err      error
+	reported bool
+}
+
+func (r *tracedRows) Columns() []string {
+	return r.rows.Columns()
+}
+
+func (r *tracedRows) Next(dest []driver.Value) error {
+	err := r.rows.Next(dest)
+	if err == nil {
+		r.count++
+	} else if r.err == nil {
+		r.err = err
+	}
+	return err
+}
+
+func (r *tracedRows) Close() error {
+	err := r.rows.Close()
+	if !r.reported {
+		r.reported = true
+		report(r.ctx, r.hook, r.query, r.start, r.count, r.err)
+	}
+	return err
+}
+
+func (r *tracedRows) HasNextResultSet() bool {
+	if next, ok := r.rows.(driver.RowsNextResultSet); ok {
+		return next.HasNextResultSet()
+	}
+	return false
+}
+
+func (r *tracedRows) NextResultSet() error {
+	if next, ok := r.rows.(driver.RowsNextResultSet); ok {
+		return next.NextResultSet()
+	}
+	return io.EOF
+}
+
+func (r *tracedRows) ColumnTypeScanType(index int) reflect.Type {
+	if typed, ok := r.rows.(driver.RowsColumnTypeScanType); ok {
+		return typed.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(any)).Elem()
+}
+
+func (r *tracedRows) ColumnTypeDatabaseTypeName(index int) string {
+	if typed, ok := r.rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return typed.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *tracedRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if typed, is := r.rows.(driver.RowsColumnTypeNullable); is {
+		return typed.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func rowsAffected(result driver.Result, err error) int64 {
+	if err != nil || result == nil {
+		return 0
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0
+	}
+	return rows
+}
+
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("mysql: driver does not support named parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+var (
+	stringLiteral  = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.|"")*"`)
+	numberLiteral  = regexp.MustCompile(`\b-?\d+(?:\.\d+)?\b`)
+	placeholderSet = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	valuesList     = regexp.MustCompile(`(?i)\bVALUES\s*\(\?\)(?:\s*,\s*\(\?\))+`)
+	whitespace     = regexp.MustCompile(`\s+`)
+)
+
+/*
+ * Fingerprint normalizes a statement so its executions can be grouped,
+ * literals become "?", lists of placeholders like IN (?, ?, ?) become (?)
+ * and rows of a multi row insert collapse into one
+ */
+func Fingerprint(query string) string {
+	fingerprint := stringLiteral.ReplaceAllString(query, "?")
+	fingerprint = numberLiteral.ReplaceAllString(fingerprint, "?")
+	fingerprint = placeholderSet.ReplaceAllString(fingerprint, "(?)")
+	fingerprint = valuesList.ReplaceAllString(fingerprint, "VALUES (?)")
+	fingerprint = whitespace.ReplaceAllString(fingerprint, " ")
+	return strings.TrimSpace(fingerprint)
+}
@@ -0,0 +1,159 @@
+package mysql_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/storage/mysql"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Opens connections of a registered driver, like sql.Open does for drivers without a connector
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+func setupTracedDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.QueryLog) {
+	mockDB, mock, err := sqlmock.NewWithDSN(t.Name())
+	require.NoError(t, err)
+	t.Cleanup(func() { mockDB.Close() })
+
+	db := sql.OpenDB(mysql.NewTracedConnector(dsnConnector{t.Name(), mockDB.Driver()}, domain.RecordQuery))
+	t.Cleanup(func() { db.Close() })
+
+	return db, mock, &domain.QueryLog{}
+}
+
+/*
+ * Test Traced Connector
+ * Query rows, exec rows affected, errors, prepared statements,
+ * statements without a query log in the context are not recorded
+ */
+func TestTracedConnector_Query(t *testing.T) {
+	db, mock, queryLog := setupTracedDB(t)
+	ctx := domain.WithQueryLog(context.Background(), queryLog)
+
+	mock.ExpectQuery("SELECT id, name FROM products").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Pen").AddRow(2, "Book"))
+
+	rows, err := db.QueryContext(ctx, "SELECT id, name FROM products WHERE stock > ?", 10)
+	require.NoError(t, err)
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Close())
+
+	queries := queryLog.Queries()
+	require.Len(t, queries, 1)
+	assert.Equal(t, "SELECT id, name FROM products WHERE stock > ?", queries[0].Fingerprint)
+	assert.Equal(t, int64(2), queries[0].Rows)
+	assert.Empty(t, queries[0].Error)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTracedConnector_Exec(t *testing.T) {
+	db, mock, queryLog := setupTracedDB(t)
+	ctx := domain.WithQueryLog(context.Background(), queryLog)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE products").WithArgs(5, 1).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "UPDATE products SET stock = ? WHERE id = ?", 5, 1)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	queries := queryLog.Queries()
+	require.Len(t, queries, 1)
+	assert.Equal(t, "UPDATE products SET stock = ? WHERE id = ?", queries[0].Fingerprint)
+	assert.Equal(t, int64(3), queries[0].Rows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTracedConnector_Error(t *testing.T) {
+	db, mock, queryLog := setupTracedDB(t)
+	ctx := domain.WithQueryLog(context.Background(), queryLog)
+
+	mock.ExpectQuery("SELECT COUNT").WillReturnError(errors.New("connection lost"))
+
+	var count int64
+	err := db.QueryRowContext(ctx, "SELECT COUNT(id) FROM products").Scan(&count)
+	assert.Error(t, err)
+
+	queries := queryLog.Queries()
+	require.Len(t, queries, 1)
+	assert.Equal(t, "SELECT COUNT(id) FROM products", queries[0].Fingerprint)
+	assert.Equal(t, "connection lost", queries[0].Error)
+}
+
+func TestTracedConnector_PreparedStatement(t *testing.T) {
+	db, mock, queryLog := setupTracedDB(t)
+	ctx := domain.WithQueryLog(context.Background(), queryLog)
+
+	prepared := mock.ExpectPrepare("SELECT name FROM products")
+	prepared.ExpectQuery().WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Pen"))
+	prepared.ExpectQuery().WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Book"))
+
+	stmt, err := db.PrepareContext(ctx, "SELECT name FROM products WHERE id = ?")
+	require.NoError(t, err)
+	defer stmt.Close()
+
+	for _, id := range []int{1, 2} {
+		var name string
+		require.NoError(t, stmt.QueryRowContext(ctx, id).Scan(&name))
+	}
+
+	queries := queryLog.Queries()
+	require.Len(t, queries, 2)
+	assert.Equal(t, queries[0].Fingerprint, queries[1].Fingerprint)
+	assert.Equal(t, int64(1), queries[1].Rows)
+}
+
+func TestTracedConnector_WithoutQueryLog(t *testing.T) {
+	db, mock, queryLog := setupTracedDB(t)
+
+	mock.ExpectExec("DELETE FROM products").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	_, err := db.ExecContext(context.Background(), "DELETE FROM products WHERE id = 1")
+	assert.NoError(t, err)
+	assert.Empty(t, queryLog.Queries())
+}
+
+/*
+ * Test Fingerprint
+ * Literals, placeholder lists, multi row inserts and whitespace
+ */
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"SELECT * FROM products WHERE id = 42", "SELECT * FROM products WHERE id = ?"},
+		{"SELECT * FROM products WHERE name = 'O''Brien' AND price > 10.5", "SELECT * FROM products WHERE name = ? AND price > ?"},
+		{"SELECT * FROM products WHERE id IN (?, ?, ?)", "SELECT * FROM products WHERE id IN (?)"},
+		{"SELECT * FROM products WHERE id IN (1,2,3)", "SELECT * FROM products WHERE id IN (?)"},
+		{"INSERT INTO products (name, stock) VALUES (?, ?), (?, ?)", "INSERT INTO products (name, stock) VALUES (?)"},
+		{"SELECT id\n\tFROM   products_v2\n LIMIT 10", "SELECT id FROM products_v2 LIMIT ?"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, mysql.Fingerprint(test.query), test.query)
+	}
+}
@@ -0,0 +1,102 @@
+package webhook_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/webhook"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+/*
+ * Test HTTP Sender
+ * Signed request, receiver failure, private addresses are blocked when dialing
+ */
+func TestSend_SignsPayload(t *testing.T) {
+	secret := "super-secret-value"
+	delivery := &domain.WebhookDelivery{
+		ID:        primitive.NewObjectID(),
+		EventType: domain.EventProductCreated,
+		Payload:   `{"id":"evt-1","type":"ProductCreated"}`,
+	}
+
+	var received *http.Request
+	var receivedBody []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	sender := webhook.NewHTTPSender(time.Second, true)
+	statusCode, err := sender.Send(context.Background(), receiver.URL, secret, delivery)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, statusCode)
+	assert.Equal(t, delivery.Payload, string(receivedBody))
+	timestamp := received.Header.Get(webhook.TimestampHeader)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), time.Unix(unix, 0), 5*time.Second)
+	assert.Equal(t, webhook.Sign(secret, timestamp, receivedBody), received.Header.Get(webhook.SignatureHeader))
+	assert.NotEqual(t, webhook.Sign(secret, "0", receivedBody), received.Header.Get(webhook.SignatureHeader))
+	assert.Equal(t, "ProductCreated", received.Header.Get(webhook.EventHeader))
+	assert.Equal(t, delivery.ID.Hex(), received.Header.Get(webhook.DeliveryHeader))
+}
+
+func TestSend_ReceiverFailure(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	sender := webhook.NewHTTPSender(time.Second, true)
+	statusCode, err := sender.Send(context.Background(), receiver.URL, "super-secret-value", &domain.WebhookDelivery{Payload: "{}"})
+
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, statusCode)
+}
+
+func TestSend_BlocksPrivateAddress(t *testing.T) {
+	called := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	sender := webhook.NewHTTPSender(time.Second, false)
+	// localhost passes as a host name and must be caught once resolved
+	url := strings.Replace(receiver.URL, "127.0.0.1", "localhost", 1)
+	_, err := sender.Send(context.Background(), url, "super-secret-value", &domain.WebhookDelivery{Payload: "{}"})
+
+	assert.ErrorIs(t, err, webhook.ErrBlockedAddress)
+	assert.False(t, called)
+}
+
+func TestCheckURL(t *testing.T) {
+	sender := webhook.NewHTTPSender(time.Second, false)
+
+	for _, url := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.5/hook",
+		"http://[::1]/hook",
+		"ftp://partner.example.com/hook",
+	} {
+		var verr *domain.ValidationError
+		assert.ErrorAs(t, sender.CheckURL(url), &verr, url)
+	}
+	assert.NoError(t, sender.CheckURL("https://partner.example.com/hook"))
+	assert.NoError(t, webhook.NewHTTPSender(time.Second, true).CheckURL("http://127.0.0.1:8080/hook"))
+}
@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+// LowStockAlert is raised when a product stock drops below its reorder threshold
+type LowStockAlert struct {
+	ProductID int64     `json:"product_id"`
+	Name      string    `json:"name"`
+	Stock     int       `json:"stock"`
+	Threshold int       `json:"threshold"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func NewLowStockAlert(product *Product) *LowStockAlert {
+	return &LowStockAlert{
+		ProductID: product.ID,
+		Name:      product.Name,
+		Stock:     product.Stock,
+		Threshold: product.ReorderThreshold,
+		Timestamp: time.Now(),
+	}
+}
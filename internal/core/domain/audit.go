@@ -0,0 +1,140 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AuditAction string
+
+const (
+	AuditActionCreate      AuditAction = "create"
+	AuditActionUpdate      AuditAction = "update"
+	AuditActionAdjustStock AuditAction = "adjust_stock"
+	AuditActionDelete      AuditAction = "delete"
+)
+
+// Record of who changed a product, and what was changed
+type AuditEntry struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID  string             `bson:"tenant_id" json:"tenant_id"`
+	Actor     string             `bson:"actor" json:"actor"`
+	Action    AuditAction        `bson:"action" json:"action"`
+	ProductID int64              `bson:"product_id" json:"product_id"`
+	Changes   []FieldChange      `bson:"changes" json:"changes"`
+	RequestID string             `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	Timestamp time.Time          `bson:"timestamp" json:"timestamp"`
+	// Outbox event the entry was relayed from, so a redelivered event is stored once
+	EventID string `bson:"event_id,omitempty" json:"-"`
+}
+
+/*
+ * Payload of AuditRecorded event, written to the outbox in the transaction of the product change.
+ * It keeps both versions of the product, the entry is built from them when the event is relayed
+ */
+type AuditRecord struct {
+	Action    AuditAction `json:"action"`
+	ProductID int64       `json:"product_id"`
+	Before    *Product    `json:"before,omitempty"`
+	After     *Product    `json:"after,omitempty"`
+	Actor     string      `json:"actor"`
+	RequestID string      `json:"request_id,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// Record of the change, actor and request id are taken from the context
+func NewAuditRecord(ctx context.Context, action AuditAction, productID int64, before *Product, after *Product) *AuditRecord {
+	return &AuditRecord{
+		Action:    action,
+		ProductID: productID,
+		Before:    before,
+		After:     after,
+		Actor:     ActorFromContext(ctx),
+		RequestID: RequestIDFromContext(ctx),
+		Timestamp: time.Now(),
+	}
+}
+
+func (r *AuditRecord) Entry(tenantID string) *AuditEntry {
+	return &AuditEntry{
+		TenantID:  tenantID,
+		Actor:     r.Actor,
+		Action:    r.Action,
+		ProductID: r.ProductID,
+		Changes:   DiffProducts(r.Before, r.After),
+		RequestID: r.RequestID,
+		Timestamp: r.Timestamp,
+	}
+}
+
+/*
+ * Product change being audited, carried by the context to the product repository.
+ * The repository sets Before to the state its write replaced, read within the write
+ * so a concurrent change can't skew the diff, and writes an AuditRecorded event
+ * to its outbox when it has one
+ */
+type PendingAudit struct {
+	Action AuditAction
+	Before *Product
+}
+
+func WithPendingAudit(ctx context.Context, action AuditAction) (context.Context, *PendingAudit) {
+	pending := &PendingAudit{Action: action}
+	return context.WithValue(ctx, pendingAuditContextKey, pending), pending
+}
+
+func PendingAuditFromContext(ctx context.Context) (*PendingAudit, bool) {
+	pending, ok := ctx.Value(pendingAuditContextKey).(*PendingAudit)
+	return pending, ok && pending != nil
+}
+
+type FieldChange struct {
+	Field  string      `bson:"field" json:"field"`
+	Before interface{} `bson:"before" json:"before"`
+	After  interface{} `bson:"after" json:"after"`
+}
+
+// Filter of the audit trail, zero values are not applied
+type AuditFilter struct {
+	ProductID int64
+	Actor     string
+	From      time.Time
+	To        time.Time
+	Page      int64
+	Limit     int64
+}
+
+/*
+ * Field level difference between two versions of a product,
+ * before is nil for a created product and after is nil for a deleted one
+ */
+func DiffProducts(before *Product, after *Product) []FieldChange {
+	fields := func(p *Product) map[string]interface{} {
+		if p == nil {
+			return map[string]interface{}{}
+		}
+		return map[string]interface{}{
+			"name":              p.Name,
+			"stock":             p.Stock,
+			"price":             p.Price,
+			"reorder_threshold": p.ReorderThreshold,
+		}
+	}
+
+	beforeFields, afterFields := fields(before), fields(after)
+	changes := []FieldChange{}
+	for _, field := range []string{"name", "stock", "price", "reorder_threshold"} {
+		beforeValue, afterValue := beforeFields[field], afterFields[field]
+		if beforeValue != afterValue {
+			changes = append(changes, FieldChange{
+				Field:  field,
+				Before: beforeValue,
+				After:  afterValue,
+			})
+		}
+	}
+
+	return changes
+}
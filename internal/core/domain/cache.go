@@ -0,0 +1,9 @@
+package domain
+
+// Counters of a cache, exposed for monitoring
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+}
@@ -0,0 +1,83 @@
+package domain
+
+import "context"
+
+type contextKey string
+
+const (
+	requestIDContextKey contextKey = "request_id"
+	actorContextKey     contextKey = "actor"
+	principalContextKey contextKey = "principal"
+	tenantContextKey    contextKey = "tenant_id"
+	queryLogContextKey  contextKey = "query_log"
+
+	pendingAuditContextKey contextKey = "pending_audit"
+)
+
+// Actor recorded when the request is not made on behalf of anyone
+const AnonymousActor = "anonymous"
+
+// Tenant used when the request does not name one
+const DefaultTenantID = "default"
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, requestID)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey).(string)
+	return requestID
+}
+
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey, actor)
+}
+
+// Actor is the authenticated principal subject, or the actor set with WithActor
+func ActorFromContext(ctx context.Context) string {
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		return principal.Subject
+	}
+	if actor, ok := ctx.Value(actorContextKey).(string); ok && actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey).(*Principal)
+	return principal, ok && principal != nil
+}
+
+func WithTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantContextKey, tenantID)
+}
+
+// Tenant every repository call is scoped to, DefaultTenantID when none is set
+func TenantIDFromContext(ctx context.Context) string {
+	if tenantID, ok := ctx.Value(tenantContextKey).(string); ok && tenantID != "" {
+		return tenantID
+	}
+	return DefaultTenantID
+}
+
+func WithQueryLog(ctx context.Context, queryLog *QueryLog) context.Context {
+	return context.WithValue(ctx, queryLogContextKey, queryLog)
+}
+
+// Query log of the request, nil when its queries are not recorded
+func QueryLogFromContext(ctx context.Context) *QueryLog {
+	queryLog, _ := ctx.Value(queryLogContextKey).(*QueryLog)
+	return queryLog
+}
+
+// RecordQuery adds the query to the query log of the context, if any
+func RecordQuery(ctx context.Context, query QueryTrace) {
+	if queryLog := QueryLogFromContext(ctx); queryLog != nil {
+		queryLog.Add(query)
+	}
+}
@@ -9,6 +9,20 @@ var (
 	ErrInternal = errors.New("internal error")
 	// this error throw when product that being requested is not found
 	ErrProductNotFound = errors.New("product not found")
+	// this error throw when another product of the tenant already has the same name
+	ErrProductAlreadyExists = errors.New("product already exists")
 	// this error throw when product stock can't fulfill the request
 	ErrInsufficientStock = errors.New("product stock is not enough")
+	// this error throw when webhook subscription that being requested is not found
+	ErrWebhookNotFound = errors.New("webhook subscription not found")
+	// this error throw when request credentials are missing, invalid or expired
+	ErrUnauthenticated = errors.New("authentication required")
+	// this error throw when the authenticated principal lacks the required permission
+	ErrForbidden = errors.New("permission denied")
+	// this error throw when api key that being requested is not found
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	// this error throw when the configured profiling sinks can't be queried
+	ErrProfilingQueryUnsupported = errors.New("profiling sink does not support queries")
+	// this error throw when the MongoDB server is too old to compute the route stats percentiles
+	ErrRouteStatsUnsupported = errors.New("profiling route stats need MongoDB 7.0 or later")
 )
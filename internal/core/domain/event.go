@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EventType string
+
+const (
+	EventProductCreated EventType = "ProductCreated"
+	EventProductUpdated EventType = "ProductUpdated"
+	EventProductDeleted EventType = "ProductDeleted"
+	EventStockAdjusted  EventType = "StockAdjusted"
+	EventAuditRecorded  EventType = "AuditRecorded"
+)
+
+/*
+ * Event is something that happened to a product,
+ * it is stored in the outbox together with the change and published afterwards
+ */
+type Event struct {
+	ID         string          `json:"id"`
+	Sequence   int64           `json:"-"`
+	Attempts   int             `json:"-"`
+	TenantID   string          `json:"tenant_id"`
+	Type       EventType       `json:"type"`
+	ProductID  int64           `json:"product_id"`
+	Payload    json.RawMessage `json:"payload"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+/*
+ * Event types relayed by one outbox relay, the listed types or with Exclude every other type,
+ * the zero value selects every event. Relays with disjoint selectors retry,
+ * hold back and dead letter their events independently of each other
+ */
+type EventSelector struct {
+	Types   []EventType
+	Exclude bool
+}
+
+// Payload of StockAdjusted event
+type StockAdjustment struct {
+	ProductID int64 `json:"product_id"`
+	Delta     int   `json:"delta"`
+	Stock     int   `json:"stock"`
+}
+
+func NewEvent(eventType EventType, productID int64, payload interface{}) (*Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Event{
+		ID:         uuid.NewString(),
+		Type:       eventType,
+		ProductID:  productID,
+		Payload:    data,
+		OccurredAt: time.Now().UTC(),
+	}, nil
+}
@@ -0,0 +1,18 @@
+package domain
+
+import "time"
+
+/*
+ * Idempotency record of a request made with an Idempotency-Key header,
+ * it stays pending until the response is stored so a retry can replay it
+ */
+type IdempotencyRecord struct {
+	Key         string    `bson:"_id" json:"key"`
+	Fingerprint string    `bson:"fingerprint" json:"fingerprint"`
+	Completed   bool      `bson:"completed" json:"completed"`
+	StatusCode  int       `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	ContentType string    `bson:"content_type,omitempty" json:"content_type,omitempty"`
+	Body        []byte    `bson:"body,omitempty" json:"body,omitempty"`
+	CreatedAt   time.Time `bson:"created_at" json:"created_at"`
+	ExpiresAt   time.Time `bson:"expires_at" json:"expires_at"`
+}
@@ -0,0 +1,64 @@
+package domain
+
+import "strings"
+
+// Permission is an action a principal may be allowed to perform
+type Permission string
+
+const (
+	PermissionProductsCreate Permission = "products:create"
+	PermissionProductsUpdate Permission = "products:update"
+	PermissionProductsDelete Permission = "products:delete"
+	PermissionProfilingRead  Permission = "profiling:read"
+	PermissionWebhooksManage Permission = "webhooks:manage"
+	PermissionAuditRead      Permission = "audit:read"
+	PermissionAPIKeysManage  Permission = "apikeys:manage"
+	// Lets a principal not bound to a tenant pick one with the X-Tenant-ID header
+	PermissionTenantsSwitch Permission = "tenants:switch"
+
+	// Grants every permission, intended for the admin role
+	PermissionAll Permission = "*"
+)
+
+// Every permission known by the api, checked before a permission is granted to an api key
+var Permissions = []Permission{
+	PermissionProductsCreate,
+	PermissionProductsUpdate,
+	PermissionProductsDelete,
+	PermissionProfilingRead,
+	PermissionWebhooksManage,
+	PermissionAuditRead,
+	PermissionAPIKeysManage,
+	PermissionTenantsSwitch,
+	PermissionAll,
+}
+
+// ValidatePermissions returns a *ValidationError listing the unknown permissions, or nil
+func ValidatePermissions(permissions []string) error {
+	verr := &ValidationError{}
+	for _, permission := range permissions {
+		known := false
+		for _, p := range Permissions {
+			if Permission(permission) == p {
+				known = true
+				break
+			}
+		}
+		if !known {
+			verr.add("permissions", "oneof", "unknown permission %q, must be one of %s", permission, joinPermissions(Permissions))
+		}
+	}
+
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+func joinPermissions(permissions []Permission) string {
+	names := make([]string, len(permissions))
+	for i, permission := range permissions {
+		names[i] = string(permission)
+	}
+	return strings.Join(names, ", ")
+}
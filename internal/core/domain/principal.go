@@ -0,0 +1,18 @@
+package domain
+
+type PrincipalType string
+
+const (
+	PrincipalUser   PrincipalType = "user"
+	PrincipalAPIKey PrincipalType = "api_key"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Subject     string        `json:"subject"`
+	Type        PrincipalType `json:"type"`
+	Roles       []string      `json:"roles,omitempty"`
+	Permissions []string      `json:"permissions,omitempty"`
+	// Tenant the principal belongs to, empty when it may act on any tenant
+	TenantID string `json:"tenant_id,omitempty"`
+}
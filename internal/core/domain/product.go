@@ -1,8 +1,18 @@
 package domain
 
+import "time"
+
 type Product struct {
-	ID    int64  `json:"id,omitempty"`
-	Name  string `json:"name,omitempty" validate:"required"`
-	Stock int    `json:"stock,omitempty" validate:"required,min=0"`
-	Price int    `json:"price,omitempty" validate:"required,gt=0"`
+	ID               int64  `json:"id,omitempty" xml:"id,omitempty"`
+	Name             string `json:"name,omitempty" xml:"name,omitempty" validate:"required"`
+	Stock            int    `json:"stock,omitempty" xml:"stock,omitempty" validate:"required,min=0"`
+	Price            int    `json:"price,omitempty" xml:"price,omitempty" validate:"required,gt=0"`
+	ReorderThreshold int    `json:"reorder_threshold,omitempty" xml:"reorder_threshold,omitempty" validate:"min=0"`
+	// Time of the last change, used for conditional requests
+	UpdatedAt time.Time `json:"updated_at" xml:"updated_at"`
+}
+
+// IsLowStock reports whether the product stock has dropped below its reorder threshold
+func (p *Product) IsLowStock() bool {
+	return p.Stock < p.ReorderThreshold
 }
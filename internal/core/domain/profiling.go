@@ -7,9 +7,149 @@ import (
 )
 
 type Profiling struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Method    string             `bson:"method" json:"method"`
-	Path      string             `bson:"path" json:"path"`
-	Duration  int64              `bson:"duration" json:"duration"`
-	Timestamp time.Time          `bson:"timestamp" json:"timestamp"`
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID string             `bson:"tenant_id" json:"tenant_id"`
+	Method   string             `bson:"method" json:"method"`
+	Path     string             `bson:"path" json:"path"`
+	// Template of the matched route (e.g. "/products/:id"), empty when no route matched
+	Route     string `bson:"route" json:"route"`
+	Status    int    `bson:"status" json:"status"`
+	BytesIn   int    `bson:"bytes_in" json:"bytes_in"`
+	BytesOut  int    `bson:"bytes_out" json:"bytes_out"`
+	IP        string `bson:"ip" json:"ip"`
+	UserAgent string `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	RequestID string `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	// Error returned by the handler, empty on success
+	Error string `bson:"error,omitempty" json:"error,omitempty"`
+	// Database statements of the request, QueryCount includes the ones beyond the stored limit
+	Queries     []QueryTrace `bson:"queries,omitempty" json:"queries,omitempty"`
+	QueryCount  int          `bson:"query_count" json:"query_count"`
+	DBTimeUs    int64        `bson:"db_time_us" json:"db_time_us"`
+	SlowQueries int          `bson:"slow_queries,omitempty" json:"slow_queries,omitempty"`
+	// Fingerprints run often enough in the request to look like an N+1 pattern
+	NPlusOne []string `bson:"n_plus_one,omitempty" json:"n_plus_one,omitempty"`
+	// Duration in milliseconds, kept for the records written before duration_us
+	Duration   int64     `bson:"duration" json:"duration"`
+	DurationUs int64     `bson:"duration_us" json:"duration_us"`
+	Timestamp  time.Time `bson:"timestamp" json:"timestamp"`
+}
+
+// Counters of the background profiling writer
+type ProfilingWriterStats struct {
+	// Records waiting in the queue
+	Queued int `json:"queued"`
+	// Records dropped because the queue was full
+	Dropped uint64 `json:"dropped"`
+	// Records written to the repository
+	Flushed uint64 `json:"flushed"`
+	// Records lost because their batch could not be written
+	Failed uint64 `json:"failed"`
+}
+
+// Filter of the profiling records, zero values are not applied
+type ProfilingFilter struct {
+	Method string
+	Route  string
+	Status int
+	From   time.Time
+	To     time.Time
+	Page   int64
+	Limit  int64
+}
+
+// Route stats cover the last hour by default and at most a day, so the records are never scanned whole
+const (
+	RouteStatsDefaultWindow = time.Hour
+	RouteStatsMaxWindow     = 24 * time.Hour
+)
+
+// BoundStatsWindow fills the time range of the route stats, it returns a *ValidationError when it is too long
+func (f *ProfilingFilter) BoundStatsWindow(now time.Time) error {
+	if f.To.IsZero() {
+		f.To = now
+	}
+	if f.From.IsZero() {
+		f.From = f.To.Add(-RouteStatsDefaultWindow)
+	}
+
+	verr := &ValidationError{}
+	switch {
+	case f.From.After(f.To):
+		verr.add("from", "before", "from must be before to")
+	case f.To.Sub(f.From) > RouteStatsMaxWindow:
+		verr.add("from", "max", "time range must not exceed %s", RouteStatsMaxWindow)
+	}
+
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+/*
+ * Latency of a route, percentiles are in microseconds.
+ * Requests answered with a 5xx status count as errors
+ */
+type RouteStats struct {
+	Method    string  `bson:"method" json:"method"`
+	Route     string  `bson:"route" json:"route"`
+	Count     int64   `bson:"count" json:"count"`
+	Errors    int64   `bson:"errors" json:"errors"`
+	ErrorRate float64 `bson:"error_rate" json:"error_rate"`
+	P50Us     int64   `bson:"p50_us" json:"p50_us"`
+	P90Us     int64   `bson:"p90_us" json:"p90_us"`
+	P99Us     int64   `bson:"p99_us" json:"p99_us"`
+}
+
+// Counters of the local spill of profiling records that could not be written
+type ProfilingSpillStats struct {
+	// Records appended to the spill
+	Spilled uint64 `json:"spilled"`
+	// Spilled records written to the repository once it was back
+	Replayed uint64 `json:"replayed"`
+	// Spilled records removed because the spill reached its size cap
+	Dropped uint64 `json:"dropped"`
+	// Segment files waiting to be replayed and their total size
+	Segments int   `json:"segments"`
+	Bytes    int64 `json:"bytes"`
+}
+
+type RollupGranularity string
+
+const (
+	RollupHourly RollupGranularity = "hour"
+	RollupDaily  RollupGranularity = "day"
+)
+
+/*
+ * Upper bounds in microseconds of the latency histogram buckets of the rollups,
+ * bucket i counts the requests above bound i-1 up to bound i, and a rollup has
+ * one more bucket for the requests slower than the last bound
+ */
+var RollupHistogramBounds = []int64{
+	1000, 5000, 10000, 25000, 50000, 100000, 250000, 500000, 1000000, 2500000, 5000000,
+}
+
+// Summary of the requests of a route in an hour or a day, durations are in microseconds
+type ProfilingRollup struct {
+	TenantID string    `bson:"tenant_id" json:"-"`
+	Method   string    `bson:"method" json:"method"`
+	Route    string    `bson:"route" json:"route"`
+	Period   time.Time `bson:"period" json:"period"`
+	Count    int64     `bson:"count" json:"count"`
+	Errors   int64     `bson:"errors" json:"errors"`
+	MinUs    int64     `bson:"min_us" json:"min_us"`
+	AvgUs    float64   `bson:"avg_us" json:"avg_us"`
+	MaxUs    int64     `bson:"max_us" json:"max_us"`
+	SumUs    int64     `bson:"sum_us" json:"-"`
+	Buckets  []int64   `bson:"buckets" json:"buckets"`
+}
+
+// Filter of the rollups, zero values are not applied
+type RollupFilter struct {
+	Granularity RollupGranularity
+	Method      string
+	Route       string
+	From        time.Time
+	To          time.Time
 }
@@ -0,0 +1,32 @@
+package domain
+
+import "sync"
+
+// Database statement run while handling a request
+type QueryTrace struct {
+	// Statement with its literals replaced by "?", so repeated statements are equal
+	Fingerprint string `bson:"fingerprint" json:"fingerprint"`
+	DurationUs  int64  `bson:"duration_us" json:"duration_us"`
+	// Rows returned by a query, or affected by an exec
+	Rows  int64  `bson:"rows" json:"rows"`
+	Error string `bson:"error,omitempty" json:"error,omitempty"`
+	Slow  bool   `bson:"slow,omitempty" json:"slow,omitempty"`
+}
+
+// Queries of one request, safe for concurrent use
+type QueryLog struct {
+	mu      sync.Mutex
+	queries []QueryTrace
+}
+
+func (l *QueryLog) Add(query QueryTrace) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.queries = append(l.queries, query)
+}
+
+func (l *QueryLog) Queries() []QueryTrace {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]QueryTrace(nil), l.queries...)
+}
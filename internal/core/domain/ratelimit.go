@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// Number of requests a client may make per period
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Outcome of taking one request from a client budget
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Time until the budget is fully restored
+	ResetAfter time.Duration
+	// Time until the next request is allowed, zero when allowed
+	RetryAfter time.Duration
+}
@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Rule that a field value broke
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// This error is returned when an entity breaks one or more business rules
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		messages = append(messages, fieldErr.Message)
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) add(field string, rule string, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Rule: rule, Message: fmt.Sprintf(format, args...)})
+}
+
+// Letters, digits, spaces and the punctuation used in product names
+var productNamePattern = regexp.MustCompile(`^[\p{L}\p{N} .,'&()/+#_-]+$`)
+
+/*
+ * Business rules of a product, enforced by the product service for every adapter.
+ * Zero maximums are not enforced, so a deployment only sets the limits it needs
+ */
+type ProductRules struct {
+	NameMaxLength int
+	MaxPrice      int
+	MaxStock      int
+}
+
+func DefaultProductRules() ProductRules {
+	return ProductRules{
+		NameMaxLength: 255,
+	}
+}
+
+// Validate returns a *ValidationError listing every broken rule, or nil
+func (r ProductRules) Validate(product *Product) error {
+	verr := &ValidationError{}
+
+	name := strings.TrimSpace(product.Name)
+	switch {
+	case name == "":
+		verr.add("name", "required", "name is required")
+	case r.NameMaxLength > 0 && utf8.RuneCountInString(name) > r.NameMaxLength:
+		verr.add("name", "max_length", "name must be at most %d characters", r.NameMaxLength)
+	case name != product.Name:
+		verr.add("name", "trimmed", "name must not start or end with spaces")
+	case !productNamePattern.MatchString(name):
+		verr.add("name", "charset", "name may only contain letters, digits, spaces and . , ' & ( ) / + # _ -")
+	}
+
+	if product.Price <= 0 {
+		verr.add("price", "min", "price must be greater than 0")
+	} else if r.MaxPrice > 0 && product.Price > r.MaxPrice {
+		verr.add("price", "max", "price must be at most %d", r.MaxPrice)
+	}
+
+	r.validateStock(verr, product.Stock)
+
+	if product.ReorderThreshold < 0 {
+		verr.add("reorder_threshold", "min", "reorder threshold must not be negative")
+	}
+
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// ValidateStock checks a stock level reached by a stock adjustment
+func (r ProductRules) ValidateStock(stock int) error {
+	verr := &ValidationError{}
+	r.validateStock(verr, stock)
+
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+func (r ProductRules) validateStock(verr *ValidationError, stock int) {
+	if stock < 0 {
+		verr.add("stock", "min", "stock must not be negative")
+	} else if r.MaxStock > 0 && stock > r.MaxStock {
+		verr.add("stock", "max", "stock must be at most %d", r.MaxStock)
+	}
+}
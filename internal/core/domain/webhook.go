@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Subscription of a partner URL to product events
+type WebhookSubscription struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID   string             `bson:"tenant_id" json:"tenant_id"`
+	URL        string             `bson:"url" json:"url"`
+	Secret     string             `bson:"secret" json:"-"`
+	EventTypes []EventType        `bson:"event_types" json:"event_types"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Subscribes reports whether the subscription wants to receive the event type
+func (s *WebhookSubscription) Subscribes(eventType EventType) bool {
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Shared address space of carrier-grade NAT, not covered by net.IP.IsPrivate
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// IsPublicIP reports whether webhooks may reach the address, loopback, private and link-local ones (e.g. cloud metadata) may not
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	if addr, ok := netip.AddrFromSlice(ip); ok && sharedAddressSpace.Contains(addr.Unmap()) {
+		return false
+	}
+	return true
+}
+
+/*
+ * ValidateWebhookURL returns a *ValidationError when the URL is not http(s),
+ * or names localhost or an address that is not public.
+ * Host names can resolve to anything later, so the sender checks the resolved address again when dialing
+ */
+func ValidateWebhookURL(rawURL string, allowPrivateNetworks bool) error {
+	verr := &ValidationError{}
+
+	parsed, err := url.Parse(rawURL)
+	switch {
+	case err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "":
+		verr.add("url", "http_url", "url must be an absolute http or https url")
+	case allowPrivateNetworks:
+	default:
+		host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+		ip := net.ParseIP(host)
+		if host == "localhost" || strings.HasSuffix(host, ".localhost") || (ip != nil && !IsPublicIP(ip)) {
+			verr.add("url", "public", "url must not point to a loopback, private or link-local address")
+		}
+	}
+
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending    WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded  WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryDeadLetter WebhookDeliveryStatus = "dead_letter"
+)
+
+// Delivery of one event to one subscription, with every attempt made so far
+type WebhookDelivery struct {
+	ID             primitive.ObjectID    `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID       string                `bson:"tenant_id" json:"tenant_id"`
+	SubscriptionID primitive.ObjectID    `bson:"subscription_id" json:"subscription_id"`
+	EventID        string                `bson:"event_id" json:"event_id"`
+	EventType      EventType             `bson:"event_type" json:"event_type"`
+	Payload        string                `bson:"payload" json:"-"`
+	Status         WebhookDeliveryStatus `bson:"status" json:"status"`
+	Attempts       []WebhookAttempt      `bson:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time             `bson:"next_attempt_at" json:"next_attempt_at"`
+	CreatedAt      time.Time             `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time             `bson:"updated_at" json:"updated_at"`
+}
+
+type WebhookAttempt struct {
+	Number      int       `bson:"number" json:"number"`
+	StatusCode  int       `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	Error       string    `bson:"error,omitempty" json:"error,omitempty"`
+	Duration    int64     `bson:"duration" json:"duration"`
+	AttemptedAt time.Time `bson:"attempted_at" json:"attempted_at"`
+}
@@ -0,0 +1,17 @@
+package port
+
+import (
+	"context"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+)
+
+type AuditRepository interface {
+	InsertAuditEntry(ctx context.Context, entry *domain.AuditEntry) (*domain.AuditEntry, error)
+	GetAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, int64, error)
+}
+
+type AuditService interface {
+	RecordProductChange(ctx context.Context, action domain.AuditAction, productID int64, before *domain.Product, after *domain.Product) error
+	GetAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, int64, error)
+}
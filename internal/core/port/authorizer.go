@@ -0,0 +1,12 @@
+package port
+
+import (
+	"context"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+)
+
+type Authorizer interface {
+	// Returns domain.ErrUnauthenticated without principal in ctx, domain.ErrForbidden when it lacks the permission
+	Authorize(ctx context.Context, permission domain.Permission) error
+}
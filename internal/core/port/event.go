@@ -0,0 +1,18 @@
+package port
+
+import (
+	"context"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+)
+
+type EventPublisher interface {
+	Publish(ctx context.Context, event *domain.Event) error
+}
+
+type OutboxRepository interface {
+	GetPendingEvents(ctx context.Context, selector domain.EventSelector, limit uint64) ([]domain.Event, error)
+	MarkEventPublished(ctx context.Context, sequence int64) error
+	MarkEventFailed(ctx context.Context, sequence int64, reason string) error
+	MarkEventDeadLettered(ctx context.Context, sequence int64, reason string) error
+}
@@ -0,0 +1,16 @@
+package port
+
+import (
+	"context"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+)
+
+type IdempotencyStore interface {
+	// Store the pending record, or return the unexpired record already stored under its key
+	Reserve(ctx context.Context, record *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error)
+	// Store the response of the request
+	Complete(ctx context.Context, record *domain.IdempotencyRecord) error
+	// Remove a pending record, so the request can be retried
+	Release(ctx context.Context, key string) error
+}
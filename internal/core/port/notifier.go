@@ -0,0 +1,11 @@
+package port
+
+import (
+	"context"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+)
+
+type Notifier interface {
+	NotifyLowStock(ctx context.Context, alert *domain.LowStockAlert) error
+}
@@ -10,7 +10,9 @@ type ProductRepository interface {
 	CreateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error)
 	GetProductById(ctx context.Context, id int64) (*domain.Product, error)
 	GetProducts(ctx context.Context, page uint64, limit uint64, name string, stock string, price string, sortBy string) ([]domain.Product, int64, error)
+	GetLowStockProducts(ctx context.Context) ([]domain.Product, error)
 	UpdateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error)
+	AdjustStock(ctx context.Context, id int64, delta int) (*domain.Product, error)
 	DeleteProduct(ctx context.Context, id int64) error
 }
 
@@ -18,6 +20,8 @@ type ProductService interface {
 	CreateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error)
 	GetProductById(ctx context.Context, id int64) (*domain.Product, error)
 	GetProducts(ctx context.Context, page uint64, limit uint64, name string, stock string, price string, sortBy string) ([]domain.Product, int64, error)
+	GetLowStockProducts(ctx context.Context) ([]domain.Product, error)
 	UpdateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error)
+	AdjustStock(ctx context.Context, id int64, delta int) (*domain.Product, error)
 	DeleteProduct(ctx context.Context, id int64) error
 }
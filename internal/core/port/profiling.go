@@ -2,14 +2,34 @@ package port
 
 import (
 	"context"
+	"time"
 
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
 )
 
 type ProfilingRepository interface {
-	InsertProfilingData(ctx context.Context, data *domain.Profiling) (*domain.Profiling, error)
+	InsertProfilingBatch(ctx context.Context, data []domain.Profiling) error
+	GetProfilings(ctx context.Context, filter domain.ProfilingFilter) ([]domain.Profiling, int64, error)
+	GetRouteStats(ctx context.Context, filter domain.ProfilingFilter) ([]domain.RouteStats, error)
 }
 
 type ProfilingService interface {
-	InsertProfilingData(ctx context.Context, data *domain.Profiling) (*domain.Profiling, error)
+	// RecordProfiling queues the data to be written in the background, it never blocks the request
+	RecordProfiling(data *domain.Profiling)
+	Stats() domain.ProfilingWriterStats
+	GetProfilings(ctx context.Context, filter domain.ProfilingFilter) ([]domain.Profiling, int64, error)
+	// GetRouteStats aggregates the records matching the filter per method and route, paging is ignored
+	GetRouteStats(ctx context.Context, filter domain.ProfilingFilter) ([]domain.RouteStats, error)
+}
+
+type ProfilingRollupRepository interface {
+	// RollupHour summarizes the records of the hour starting at hour, replacing its previous rollups
+	RollupHour(ctx context.Context, hour time.Time) error
+	// RollupDay summarizes the hourly rollups of the day starting at day, replacing its previous rollups
+	RollupDay(ctx context.Context, day time.Time) error
+	GetRollups(ctx context.Context, filter domain.RollupFilter) ([]domain.ProfilingRollup, error)
+}
+
+type ProfilingRollupService interface {
+	GetTrend(ctx context.Context, filter domain.RollupFilter) ([]domain.ProfilingRollup, error)
 }
@@ -0,0 +1,34 @@
+package port
+
+import (
+	"context"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+)
+
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, subscription *domain.WebhookSubscription) (*domain.WebhookSubscription, error)
+	GetSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error)
+	GetSubscriptionById(ctx context.Context, id string) (*domain.WebhookSubscription, error)
+	GetSubscriptionsByEventType(ctx context.Context, eventType domain.EventType) ([]domain.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id string) error
+	CreateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error
+	GetDueDeliveries(ctx context.Context, now time.Time, limit int64) ([]domain.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error
+	GetDeliveries(ctx context.Context, subscriptionID string, page int64, limit int64) ([]domain.WebhookDelivery, int64, error)
+}
+
+type WebhookSender interface {
+	// Returns a *domain.ValidationError when deliveries can't be sent to the url
+	CheckURL(url string) error
+	Send(ctx context.Context, url string, secret string, delivery *domain.WebhookDelivery) (int, error)
+}
+
+type WebhookService interface {
+	CreateSubscription(ctx context.Context, subscription *domain.WebhookSubscription) (*domain.WebhookSubscription, error)
+	GetSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error)
+	GetSubscriptionById(ctx context.Context, id string) (*domain.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id string) error
+	GetDeliveries(ctx context.Context, subscriptionID string, page int64, limit int64) ([]domain.WebhookDelivery, int64, error)
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+const (
+	apiKeySecretPrefix = "pk_"
+	apiKeyPrefixLength = 11
+	// Last used time is only written once per interval, not on every request
+	apiKeyTouchInterval = time.Minute
+)
+
+type APIKeyService struct {
+	apiKeyRepository port.APIKeyRepository
+	// Checks the issuer holds every permission granted to a new key
+	authorizer port.Authorizer
+}
+
+func NewAPIKeyService(apiKeyRepository port.APIKeyRepository, authorizer port.Authorizer) port.APIKeyService {
+	return &APIKeyService{
+		apiKeyRepository: apiKeyRepository,
+		authorizer:       authorizer,
+	}
+}
+
+/*
+ * Issue a new API key, the returned secret is the only time the key is visible,
+ * afterwards only its hash is known.
+ * Only known permissions can be granted, and only the ones the issuer holds itself,
+ * so "*" can only be granted by an issuer holding "*"
+ */
+func (s *APIKeyService) IssueAPIKey(ctx context.Context, name string, permissions []string, expiresAt *time.Time) (*domain.APIKey, string, error) {
+	if err := domain.ValidatePermissions(permissions); err != nil {
+		return nil, "", err
+	}
+	for _, permission := range permissions {
+		if err := s.authorizer.Authorize(ctx, domain.Permission(permission)); err != nil {
+			return nil, "", err
+		}
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		log.Println("error when generating api key", err)
+		return nil, "", domain.ErrInternal
+	}
+	secret := apiKeySecretPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	key := &domain.APIKey{
+		TenantID:    domain.TenantIDFromContext(ctx),
+		Name:        name,
+		Prefix:      secret[:apiKeyPrefixLength],
+		Hash:        hashAPIKey(secret),
+		Permissions: permissions,
+		CreatedBy:   domain.ActorFromContext(ctx),
+		CreatedAt:   time.Now(),
+		ExpiresAt:   expiresAt,
+	}
+
+	createdKey, err := s.apiKeyRepository.CreateAPIKey(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return createdKey, secret, nil
+}
+
+func (s *APIKeyService) GetAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	return s.apiKeyRepository.GetAPIKeys(ctx)
+}
+
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, id string) error {
+	return s.apiKeyRepository.RevokeAPIKey(ctx, id, time.Now())
+}
+
+// Resolve the secret to the principal of its key, unknown, revoked and expired keys are rejected
+func (s *APIKeyService) Authenticate(ctx context.Context, secret string) (*domain.Principal, error) {
+	key, err := s.apiKeyRepository.GetAPIKeyByHash(ctx, hashAPIKey(secret))
+	if err != nil {
+		if err == domain.ErrAPIKeyNotFound {
+			return nil, domain.ErrUnauthenticated
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if !key.IsActive(now) {
+		return nil, domain.ErrUnauthenticated
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.apiKeyRepository.TouchAPIKey(ctx, key.ID.Hex(), now); err != nil {
+			log.Println("error when updating api key last used time", err)
+		}
+	}
+
+	return &domain.Principal{
+		Subject:     "apikey:" + key.ID.Hex(),
+		Type:        domain.PrincipalAPIKey,
+		Permissions: key.Permissions,
+		TenantID:    key.TenantID,
+	}, nil
+}
+
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
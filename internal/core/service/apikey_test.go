@@ -0,0 +1,188 @@
+package service_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepository) CreateAPIKey(ctx context.Context, key *domain.APIKey) (*domain.APIKey, error) {
+	args := m.Called(ctx, key)
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.APIKey), nil
+}
+
+func (m *MockAPIKeyRepository) GetAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*domain.APIKey, error) {
+	args := m.Called(ctx, hash)
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.APIKey), nil
+}
+
+func (m *MockAPIKeyRepository) RevokeAPIKey(ctx context.Context, id string, revokedAt time.Time) error {
+	args := m.Called(ctx, id, revokedAt)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error {
+	args := m.Called(ctx, id, usedAt)
+	return args.Error(0)
+}
+
+func sha256Hex(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+/*
+ * Test API Key Service
+ * Issue stores only the hash, unknown permissions, permissions the issuer lacks,
+ * authenticate valid, revoked, expired and unknown keys
+ */
+func TestIssueAPIKey_StoresHash(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	apiKeyService := service.NewAPIKeyService(mockRepo, service.NewAllowAllAuthorizer())
+
+	var stored *domain.APIKey
+	mockRepo.On("CreateAPIKey", mock.Anything, mock.AnythingOfType("*domain.APIKey")).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*domain.APIKey) }).
+		Return(&domain.APIKey{}, nil)
+
+	_, secret, err := apiKeyService.IssueAPIKey(context.Background(), "nightly-sync", []string{"products:update"}, nil)
+
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, "pk_"))
+	assert.Equal(t, sha256Hex(secret), stored.Hash)
+	assert.NotContains(t, stored.Hash, secret)
+	assert.True(t, strings.HasPrefix(secret, stored.Prefix))
+	assert.Equal(t, []string{"products:update"}, stored.Permissions)
+}
+
+func TestIssueAPIKey_UnknownPermission(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	apiKeyService := service.NewAPIKeyService(mockRepo, service.NewAllowAllAuthorizer())
+
+	_, _, err := apiKeyService.IssueAPIKey(context.Background(), "typo", []string{"products:updat"}, nil)
+
+	var verr *domain.ValidationError
+	assert.ErrorAs(t, err, &verr)
+	mockRepo.AssertNotCalled(t, "CreateAPIKey", mock.Anything, mock.Anything)
+}
+
+func TestIssueAPIKey_PermissionEscalation(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	authorizer := service.NewRoleAuthorizer(map[string][]string{
+		"keymaster": {"apikeys:manage", "products:update"},
+		"admin":     {"*"},
+	})
+	apiKeyService := service.NewAPIKeyService(mockRepo, authorizer)
+	mockRepo.On("CreateAPIKey", mock.Anything, mock.AnythingOfType("*domain.APIKey")).Return(&domain.APIKey{}, nil)
+
+	keymaster := domain.WithPrincipal(context.Background(), &domain.Principal{Subject: "carol", Roles: []string{"keymaster"}})
+	admin := domain.WithPrincipal(context.Background(), &domain.Principal{Subject: "root", Roles: []string{"admin"}})
+
+	// A key manager can't mint a key with more than it holds
+	_, _, err := apiKeyService.IssueAPIKey(keymaster, "escalate", []string{"*"}, nil)
+	assert.ErrorIs(t, err, domain.ErrForbidden)
+	_, _, err = apiKeyService.IssueAPIKey(keymaster, "escalate", []string{"products:delete"}, nil)
+	assert.ErrorIs(t, err, domain.ErrForbidden)
+	mockRepo.AssertNotCalled(t, "CreateAPIKey", mock.Anything, mock.Anything)
+
+	_, _, err = apiKeyService.IssueAPIKey(keymaster, "sync", []string{"products:update"}, nil)
+	assert.NoError(t, err)
+	_, _, err = apiKeyService.IssueAPIKey(admin, "root", []string{"*"}, nil)
+	assert.NoError(t, err)
+}
+
+func TestAuthenticateAPIKey_Valid(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	apiKeyService := service.NewAPIKeyService(mockRepo, service.NewAllowAllAuthorizer())
+
+	id := primitive.NewObjectID()
+	key := &domain.APIKey{ID: id, Permissions: []string{"products:update"}}
+	mockRepo.On("GetAPIKeyByHash", mock.Anything, sha256Hex("pk_secret")).Return(key, nil)
+	mockRepo.On("TouchAPIKey", mock.Anything, id.Hex(), mock.AnythingOfType("time.Time")).Return(nil)
+
+	principal, err := apiKeyService.Authenticate(context.Background(), "pk_secret")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "apikey:"+id.Hex(), principal.Subject)
+	assert.Equal(t, domain.PrincipalAPIKey, principal.Type)
+	assert.Equal(t, []string{"products:update"}, principal.Permissions)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthenticateAPIKey_RecentlyUsedIsNotTouched(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	apiKeyService := service.NewAPIKeyService(mockRepo, service.NewAllowAllAuthorizer())
+
+	lastUsed := time.Now().Add(-10 * time.Second)
+	key := &domain.APIKey{ID: primitive.NewObjectID(), LastUsedAt: &lastUsed}
+	mockRepo.On("GetAPIKeyByHash", mock.Anything, sha256Hex("pk_secret")).Return(key, nil)
+
+	_, err := apiKeyService.Authenticate(context.Background(), "pk_secret")
+
+	assert.NoError(t, err)
+	mockRepo.AssertNotCalled(t, "TouchAPIKey", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthenticateAPIKey_Revoked(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	apiKeyService := service.NewAPIKeyService(mockRepo, service.NewAllowAllAuthorizer())
+
+	revokedAt := time.Now().Add(-time.Hour)
+	key := &domain.APIKey{ID: primitive.NewObjectID(), RevokedAt: &revokedAt}
+	mockRepo.On("GetAPIKeyByHash", mock.Anything, sha256Hex("pk_secret")).Return(key, nil)
+
+	principal, err := apiKeyService.Authenticate(context.Background(), "pk_secret")
+
+	assert.Nil(t, principal)
+	assert.ErrorIs(t, err, domain.ErrUnauthenticated)
+}
+
+func TestAuthenticateAPIKey_Expired(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	apiKeyService := service.NewAPIKeyService(mockRepo, service.NewAllowAllAuthorizer())
+
+	expiresAt := time.Now().Add(-time.Minute)
+	key := &domain.APIKey{ID: primitive.NewObjectID(), ExpiresAt: &expiresAt}
+	mockRepo.On("GetAPIKeyByHash", mock.Anything, sha256Hex("pk_secret")).Return(key, nil)
+
+	principal, err := apiKeyService.Authenticate(context.Background(), "pk_secret")
+
+	assert.Nil(t, principal)
+	assert.ErrorIs(t, err, domain.ErrUnauthenticated)
+}
+
+func TestAuthenticateAPIKey_Unknown(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	apiKeyService := service.NewAPIKeyService(mockRepo, service.NewAllowAllAuthorizer())
+
+	mockRepo.On("GetAPIKeyByHash", mock.Anything, sha256Hex("pk_unknown")).Return(nil, domain.ErrAPIKeyNotFound)
+
+	principal, err := apiKeyService.Authenticate(context.Background(), "pk_unknown")
+
+	assert.Nil(t, principal)
+	assert.ErrorIs(t, err, domain.ErrUnauthenticated)
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+/*
+ * Audit service stores the audit trail of product changes,
+ * either recorded directly by the product service or relayed from the outbox
+ * as AuditRecorded events, it implements port.EventPublisher for the latter.
+ * A recorded entry that fails to be stored is kept in memory and retried by Run,
+ * it gets its ID up front so a retry of an entry stored after all is not stored twice
+ */
+type AuditService struct {
+	auditRepository port.AuditRepository
+	maxPending      int
+
+	mu      sync.Mutex
+	pending []*domain.AuditEntry
+}
+
+// Entries waiting for a retry, the oldest ones are dropped beyond it
+const defaultAuditMaxPending = 10000
+
+func NewAuditService(auditRepository port.AuditRepository) *AuditService {
+	return &AuditService{
+		auditRepository: auditRepository,
+		maxPending:      defaultAuditMaxPending,
+	}
+}
+
+var (
+	_ port.AuditService   = (*AuditService)(nil)
+	_ port.EventPublisher = (*AuditService)(nil)
+)
+
+// Store the change, tenant, actor and request id are taken from the context
+func (s *AuditService) RecordProductChange(ctx context.Context, action domain.AuditAction, productID int64, before *domain.Product, after *domain.Product) error {
+	entry := domain.NewAuditRecord(ctx, action, productID, before, after).Entry(domain.TenantIDFromContext(ctx))
+	entry.ID = primitive.NewObjectID()
+
+	if _, err := s.auditRepository.InsertAuditEntry(ctx, entry); err != nil {
+		s.queue(entry)
+		return err
+	}
+	return nil
+}
+
+// Retry the entries that failed to be stored every interval until ctx is done
+func (s *AuditService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if retried, err := s.RetryPending(ctx); err != nil {
+				log.Printf("error when retrying audit entries after %d entries: %v", retried, err)
+			}
+		}
+	}
+}
+
+// Store the queued entries oldest first, it stops at the first failure and returns the number stored
+func (s *AuditService) RetryPending(ctx context.Context) (int, error) {
+	retried := 0
+	for {
+		s.mu.Lock()
+		if len(s.pending) == 0 {
+			s.mu.Unlock()
+			return retried, nil
+		}
+		entry := s.pending[0]
+		s.mu.Unlock()
+
+		if _, err := s.auditRepository.InsertAuditEntry(domain.WithTenantID(ctx, entry.TenantID), entry); err != nil {
+			return retried, err
+		}
+		retried++
+
+		// The entry may have been dropped by the queue cap meanwhile
+		s.mu.Lock()
+		if len(s.pending) > 0 && s.pending[0] == entry {
+			s.pending = s.pending[1:]
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *AuditService) queue(entry *domain.AuditEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = append(s.pending, entry)
+	if len(s.pending) > s.maxPending {
+		log.Printf("audit retry queue is over %d entries, dropped the entry of product %d", s.maxPending, s.pending[0].ProductID)
+		s.pending = s.pending[1:]
+	}
+}
+
+// Store the entry of an AuditRecorded event, the other events are ignored
+func (s *AuditService) Publish(ctx context.Context, event *domain.Event) error {
+	if event.Type != domain.EventAuditRecorded {
+		return nil
+	}
+
+	var record domain.AuditRecord
+	if err := json.Unmarshal(event.Payload, &record); err != nil {
+		return err
+	}
+	entry := record.Entry(event.TenantID)
+	entry.EventID = event.ID
+
+	_, err := s.auditRepository.InsertAuditEntry(domain.WithTenantID(ctx, event.TenantID), entry)
+	return err
+}
+
+func (s *AuditService) GetAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, int64, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Limit < 1 {
+		filter.Limit = 10
+	}
+
+	return s.auditRepository.GetAuditEntries(ctx, filter)
+}
@@ -0,0 +1,161 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAuditRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditRepository) InsertAuditEntry(ctx context.Context, entry *domain.AuditEntry) (*domain.AuditEntry, error) {
+	args := m.Called(ctx, entry)
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.AuditEntry), nil
+}
+
+func (m *MockAuditRepository) GetAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, int64, error) {
+	args := m.Called(ctx, filter)
+	if args.Error(2) != nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]domain.AuditEntry), args.Get(1).(int64), nil
+}
+
+type MockAuditService struct {
+	mock.Mock
+}
+
+func (m *MockAuditService) RecordProductChange(ctx context.Context, action domain.AuditAction, productID int64, before *domain.Product, after *domain.Product) error {
+	args := m.Called(ctx, action, productID, before, after)
+	return args.Error(0)
+}
+
+func (m *MockAuditService) GetAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, int64, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]domain.AuditEntry), args.Get(1).(int64), args.Error(2)
+}
+
+/*
+ * Test Audit Service
+ * Record diff with actor and request id, default pagination,
+ * entry of a relayed AuditRecorded event, other events are ignored,
+ * an entry that failed to be stored is retried with the same ID and the tenant of the change
+ */
+func TestRecordProductChange_FieldDiff(t *testing.T) {
+	mockRepo := new(MockAuditRepository)
+	auditService := service.NewAuditService(mockRepo)
+
+	ctx := domain.WithActor(domain.WithRequestID(context.Background(), "req-1"), "alice")
+	before := &domain.Product{ID: 1, Name: "Samsung A1", Stock: 10, Price: 1000}
+	after := &domain.Product{ID: 1, Name: "Samsung A1", Stock: 8, Price: 1200}
+
+	mockRepo.On("InsertAuditEntry", ctx, mock.MatchedBy(func(entry *domain.AuditEntry) bool {
+		return entry.Actor == "alice" &&
+			entry.RequestID == "req-1" &&
+			entry.Action == domain.AuditActionUpdate &&
+			assert.ObjectsAreEqual([]domain.FieldChange{
+				{Field: "stock", Before: 10, After: 8},
+				{Field: "price", Before: 1000, After: 1200},
+			}, entry.Changes)
+	})).Return(&domain.AuditEntry{}, nil)
+
+	err := auditService.RecordProductChange(ctx, domain.AuditActionUpdate, 1, before, after)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRecordProductChange_AnonymousCreate(t *testing.T) {
+	mockRepo := new(MockAuditRepository)
+	auditService := service.NewAuditService(mockRepo)
+
+	created := &domain.Product{ID: 1, Name: "Samsung A1", Stock: 10, Price: 1000}
+
+	mockRepo.On("InsertAuditEntry", context.Background(), mock.MatchedBy(func(entry *domain.AuditEntry) bool {
+		return entry.Actor == domain.AnonymousActor && len(entry.Changes) == 4 && entry.Changes[0].Before == nil
+	})).Return(&domain.AuditEntry{}, nil)
+
+	err := auditService.RecordProductChange(context.Background(), domain.AuditActionCreate, 1, nil, created)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetAuditEntries_DefaultPagination(t *testing.T) {
+	mockRepo := new(MockAuditRepository)
+	auditService := service.NewAuditService(mockRepo)
+
+	filter := domain.AuditFilter{ProductID: 1}
+	expectedFilter := domain.AuditFilter{ProductID: 1, Page: 1, Limit: 10}
+	mockRepo.On("GetAuditEntries", context.Background(), expectedFilter).Return([]domain.AuditEntry{}, int64(0), nil)
+
+	_, _, err := auditService.GetAuditEntries(context.Background(), filter)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPublish_AuditRecordedEvent(t *testing.T) {
+	mockRepo := new(MockAuditRepository)
+	auditService := service.NewAuditService(mockRepo)
+
+	ctx := domain.WithActor(domain.WithRequestID(context.Background(), "req-1"), "alice")
+	before := &domain.Product{ID: 1, Name: "Samsung A1", Stock: 10, Price: 1000}
+	after := &domain.Product{ID: 1, Name: "Samsung A1", Stock: 8, Price: 1000}
+	payload, _ := json.Marshal(domain.NewAuditRecord(ctx, domain.AuditActionAdjustStock, 1, before, after))
+	event := &domain.Event{ID: "evt-1", TenantID: "brand-a", Type: domain.EventAuditRecorded, ProductID: 1, Payload: payload}
+
+	mockRepo.On("InsertAuditEntry", mock.Anything, mock.MatchedBy(func(entry *domain.AuditEntry) bool {
+		return entry.EventID == "evt-1" &&
+			entry.TenantID == "brand-a" &&
+			entry.Actor == "alice" &&
+			entry.RequestID == "req-1" &&
+			assert.ObjectsAreEqual([]domain.FieldChange{{Field: "stock", Before: 10, After: 8}}, entry.Changes)
+	})).Return(&domain.AuditEntry{}, nil)
+
+	assert.NoError(t, auditService.Publish(context.Background(), event))
+	assert.NoError(t, auditService.Publish(context.Background(), &domain.Event{ID: "evt-2", Type: domain.EventProductUpdated}))
+	mockRepo.AssertNumberOfCalls(t, "InsertAuditEntry", 1)
+}
+
+func TestRetryPending_StoresFailedEntry(t *testing.T) {
+	mockRepo := new(MockAuditRepository)
+	auditService := service.NewAuditService(mockRepo)
+
+	ctx := domain.WithTenantID(domain.WithActor(context.Background(), "alice"), "brand-a")
+	after := &domain.Product{ID: 1, Name: "Samsung A1", Stock: 10, Price: 1000}
+
+	var failed *domain.AuditEntry
+	mockRepo.On("InsertAuditEntry", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		failed = args.Get(1).(*domain.AuditEntry)
+	}).Return(nil, domain.ErrInternal).Once()
+
+	err := auditService.RecordProductChange(ctx, domain.AuditActionCreate, 1, nil, after)
+	assert.ErrorIs(t, err, domain.ErrInternal)
+
+	mockRepo.On("InsertAuditEntry", mock.MatchedBy(func(ctx context.Context) bool {
+		return domain.TenantIDFromContext(ctx) == "brand-a"
+	}), mock.MatchedBy(func(entry *domain.AuditEntry) bool {
+		return entry == failed && !entry.ID.IsZero() && entry.Actor == "alice"
+	})).Return(&domain.AuditEntry{}, nil).Once()
+
+	retried, err := auditService.RetryPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, retried)
+
+	// The queue is empty once the entry is stored
+	retried, err = auditService.RetryPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, retried)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+/*
+ * Role authorizer grants the permissions mapped to the principal roles,
+ * plus the permissions granted to the principal directly (e.g. api key scopes)
+ */
+type RoleAuthorizer struct {
+	rolePermissions map[string]map[domain.Permission]struct{}
+}
+
+func NewRoleAuthorizer(rolePermissions map[string][]string) port.Authorizer {
+	authorizer := &RoleAuthorizer{
+		rolePermissions: make(map[string]map[domain.Permission]struct{}, len(rolePermissions)),
+	}
+	for role, permissions := range rolePermissions {
+		granted := make(map[domain.Permission]struct{}, len(permissions))
+		for _, permission := range permissions {
+			granted[domain.Permission(permission)] = struct{}{}
+		}
+		authorizer.rolePermissions[role] = granted
+	}
+
+	return authorizer
+}
+
+func (a *RoleAuthorizer) Authorize(ctx context.Context, permission domain.Permission) error {
+	principal, ok := domain.PrincipalFromContext(ctx)
+	if !ok {
+		return domain.ErrUnauthenticated
+	}
+
+	for _, granted := range principal.Permissions {
+		if p := domain.Permission(granted); p == permission || p == domain.PermissionAll {
+			return nil
+		}
+	}
+
+	for _, role := range principal.Roles {
+		granted := a.rolePermissions[role]
+		if _, ok := granted[permission]; ok {
+			return nil
+		}
+		if _, ok := granted[domain.PermissionAll]; ok {
+			return nil
+		}
+	}
+
+	return domain.ErrForbidden
+}
+
+// Allow all authorizer is used when authentication is disabled
+type AllowAllAuthorizer struct{}
+
+func NewAllowAllAuthorizer() port.Authorizer {
+	return AllowAllAuthorizer{}
+}
+
+func (AllowAllAuthorizer) Authorize(context.Context, domain.Permission) error {
+	return nil
+}
@@ -0,0 +1,65 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/service"
+	"github.com/stretchr/testify/assert"
+)
+
+var rolePermissions = map[string][]string{
+	"admin":  {"*"},
+	"editor": {"products:create", "products:update"},
+}
+
+/*
+ * Test Role Authorizer
+ * Role permission, wildcard role, direct permission, missing permission, anonymous
+ */
+func TestAuthorize_RolePermission(t *testing.T) {
+	authorizer := service.NewRoleAuthorizer(rolePermissions)
+	ctx := domain.WithPrincipal(context.Background(), &domain.Principal{Subject: "alice", Roles: []string{"editor"}})
+
+	assert.NoError(t, authorizer.Authorize(ctx, domain.PermissionProductsUpdate))
+	assert.ErrorIs(t, authorizer.Authorize(ctx, domain.PermissionProductsDelete), domain.ErrForbidden)
+}
+
+func TestAuthorize_WildcardRole(t *testing.T) {
+	authorizer := service.NewRoleAuthorizer(rolePermissions)
+	ctx := domain.WithPrincipal(context.Background(), &domain.Principal{Subject: "root", Roles: []string{"admin"}})
+
+	assert.NoError(t, authorizer.Authorize(ctx, domain.PermissionProductsDelete))
+}
+
+func TestAuthorize_DirectPermission(t *testing.T) {
+	authorizer := service.NewRoleAuthorizer(rolePermissions)
+	ctx := domain.WithPrincipal(context.Background(), &domain.Principal{
+		Subject:     "apikey:1",
+		Type:        domain.PrincipalAPIKey,
+		Permissions: []string{"profiling:read"},
+	})
+
+	assert.NoError(t, authorizer.Authorize(ctx, domain.PermissionProfilingRead))
+	assert.ErrorIs(t, authorizer.Authorize(ctx, domain.PermissionProductsCreate), domain.ErrForbidden)
+}
+
+func TestAuthorize_Anonymous(t *testing.T) {
+	authorizer := service.NewRoleAuthorizer(rolePermissions)
+
+	err := authorizer.Authorize(context.Background(), domain.PermissionProductsCreate)
+
+	assert.ErrorIs(t, err, domain.ErrUnauthenticated)
+}
+
+func TestDeleteProduct_Forbidden(t *testing.T) {
+	mockRepo := new(MockProductRepository)
+	productService := service.NewProductService(mockRepo, service.WithAuthorizer(service.NewRoleAuthorizer(rolePermissions)))
+	ctx := domain.WithPrincipal(context.Background(), &domain.Principal{Subject: "alice", Roles: []string{"editor"}})
+
+	err := productService.DeleteProduct(ctx, 1)
+
+	assert.ErrorIs(t, err, domain.ErrForbidden)
+	mockRepo.AssertNotCalled(t, "DeleteProduct", ctx, int64(1))
+}
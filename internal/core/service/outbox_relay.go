@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+/*
+ * Outbox relay publishes the events stored in the outbox,
+ * an event is marked as published only after the publisher accepted it (at-least-once).
+ * When an event fails, the next events of the same product are held back
+ * until it succeeds, so events of one product are always published in order.
+ * An event failing maxAttempts times is dead lettered, which releases the events behind it.
+ * A relay handles every event unless it is given an event selector
+ */
+type OutboxRelay struct {
+	outboxRepository port.OutboxRepository
+	publisher        port.EventPublisher
+	interval         time.Duration
+	batchSize        uint64
+	maxAttempts      int
+	selector         domain.EventSelector
+}
+
+// Optional setting of the outbox relay
+type OutboxRelayOption func(*OutboxRelay)
+
+// Only relay the events of the selected types, another relay handles the rest
+func WithRelayedEvents(selector domain.EventSelector) OutboxRelayOption {
+	return func(r *OutboxRelay) {
+		r.selector = selector
+	}
+}
+
+func NewOutboxRelay(outboxRepository port.OutboxRepository, publisher port.EventPublisher, interval time.Duration, batchSize uint64, maxAttempts int, opts ...OutboxRelayOption) *OutboxRelay {
+	if maxAttempts <= 0 {
+		maxAttempts = 10
+	}
+
+	r := &OutboxRelay{
+		outboxRepository: outboxRepository,
+		publisher:        publisher,
+		interval:         interval,
+		batchSize:        batchSize,
+		maxAttempts:      maxAttempts,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Poll the outbox until the context is cancelled
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.RelayPendingEvents(ctx); err != nil {
+			log.Println("error when relaying outbox events", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Publish one batch of pending events, returns the number of published events
+func (r *OutboxRelay) RelayPendingEvents(ctx context.Context) (int, error) {
+	events, err := r.outboxRepository.GetPendingEvents(ctx, r.selector, r.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	blockedProducts := make(map[int64]struct{})
+	for i := range events {
+		event := &events[i]
+		if _, blocked := blockedProducts[event.ProductID]; blocked {
+			continue
+		}
+
+		if err := r.publisher.Publish(ctx, event); err != nil {
+			log.Printf("error when publishing event %s (%s): %v", event.ID, event.Type, err)
+			blockedProducts[event.ProductID] = struct{}{}
+			if event.Attempts+1 >= r.maxAttempts {
+				log.Printf("event %s failed %d times, moving it to the dead letters", event.ID, event.Attempts+1)
+				if err := r.outboxRepository.MarkEventDeadLettered(ctx, event.Sequence, err.Error()); err != nil {
+					log.Println("error when marking event as dead lettered", err)
+				}
+				continue
+			}
+			if err := r.outboxRepository.MarkEventFailed(ctx, event.Sequence, err.Error()); err != nil {
+				log.Println("error when marking event as failed", err)
+			}
+			continue
+		}
+
+		if err := r.outboxRepository.MarkEventPublished(ctx, event.Sequence); err != nil {
+			// The event will be published again, keep the rest of this product behind it
+			log.Println("error when marking event as published", err)
+			blockedProducts[event.ProductID] = struct{}{}
+			continue
+		}
+		published++
+	}
+
+	return published, nil
+}
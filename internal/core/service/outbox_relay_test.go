@@ -0,0 +1,150 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockOutboxRepository struct {
+	mock.Mock
+}
+
+func (m *MockOutboxRepository) GetPendingEvents(ctx context.Context, selector domain.EventSelector, limit uint64) ([]domain.Event, error) {
+	args := m.Called(ctx, selector, limit)
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Event), nil
+}
+
+func (m *MockOutboxRepository) MarkEventPublished(ctx context.Context, sequence int64) error {
+	args := m.Called(ctx, sequence)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) MarkEventFailed(ctx context.Context, sequence int64, reason string) error {
+	args := m.Called(ctx, sequence, reason)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) MarkEventDeadLettered(ctx context.Context, sequence int64, reason string) error {
+	args := m.Called(ctx, sequence, reason)
+	return args.Error(0)
+}
+
+type MockEventPublisher struct {
+	mock.Mock
+}
+
+func (m *MockEventPublisher) Publish(ctx context.Context, event *domain.Event) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func eventWithSequence(sequence int64) interface{} {
+	return mock.MatchedBy(func(event *domain.Event) bool {
+		return event.Sequence == sequence
+	})
+}
+
+/*
+ * Test Outbox Relay
+ * Publish all, failed event holds back the rest of its product,
+ * poison event is dead lettered after max attempts without holding back other products,
+ * relay with an event selector only reads the selected events
+ */
+func TestRelayPendingEvents_PublishAll(t *testing.T) {
+	mockOutbox := new(MockOutboxRepository)
+	mockPublisher := new(MockEventPublisher)
+	relay := service.NewOutboxRelay(mockOutbox, mockPublisher, time.Second, 10, 3)
+
+	events := []domain.Event{
+		{Sequence: 1, Type: domain.EventProductCreated, ProductID: 1},
+		{Sequence: 2, Type: domain.EventStockAdjusted, ProductID: 1},
+	}
+	mockOutbox.On("GetPendingEvents", context.Background(), domain.EventSelector{}, uint64(10)).Return(events, nil)
+	mockPublisher.On("Publish", context.Background(), mock.Anything).Return(nil)
+	mockOutbox.On("MarkEventPublished", context.Background(), int64(1)).Return(nil)
+	mockOutbox.On("MarkEventPublished", context.Background(), int64(2)).Return(nil)
+
+	published, err := relay.RelayPendingEvents(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, published)
+	mockOutbox.AssertExpectations(t)
+	mockPublisher.AssertExpectations(t)
+}
+
+func TestRelayPendingEvents_KeepsOrderPerProduct(t *testing.T) {
+	mockOutbox := new(MockOutboxRepository)
+	mockPublisher := new(MockEventPublisher)
+	relay := service.NewOutboxRelay(mockOutbox, mockPublisher, time.Second, 10, 3)
+
+	events := []domain.Event{
+		{Sequence: 1, Type: domain.EventProductUpdated, ProductID: 1},
+		{Sequence: 2, Type: domain.EventProductUpdated, ProductID: 2},
+		{Sequence: 3, Type: domain.EventProductDeleted, ProductID: 1},
+	}
+	mockOutbox.On("GetPendingEvents", context.Background(), domain.EventSelector{}, uint64(10)).Return(events, nil)
+	mockPublisher.On("Publish", context.Background(), eventWithSequence(1)).Return(errors.New("broker unavailable"))
+	mockPublisher.On("Publish", context.Background(), eventWithSequence(2)).Return(nil)
+	mockOutbox.On("MarkEventFailed", context.Background(), int64(1), "broker unavailable").Return(nil)
+	mockOutbox.On("MarkEventPublished", context.Background(), int64(2)).Return(nil)
+
+	published, err := relay.RelayPendingEvents(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, published)
+	mockPublisher.AssertNotCalled(t, "Publish", context.Background(), eventWithSequence(3))
+	mockOutbox.AssertExpectations(t)
+}
+
+func TestRelayPendingEvents_PoisonEventDeadLettered(t *testing.T) {
+	mockOutbox := new(MockOutboxRepository)
+	mockPublisher := new(MockEventPublisher)
+	relay := service.NewOutboxRelay(mockOutbox, mockPublisher, time.Second, 10, 3)
+
+	events := []domain.Event{
+		{Sequence: 1, Type: domain.EventProductUpdated, ProductID: 1, Attempts: 2},
+		{Sequence: 2, Type: domain.EventProductUpdated, ProductID: 2},
+		{Sequence: 3, Type: domain.EventProductDeleted, ProductID: 1},
+	}
+	mockOutbox.On("GetPendingEvents", context.Background(), domain.EventSelector{}, uint64(10)).Return(events, nil)
+	mockPublisher.On("Publish", context.Background(), eventWithSequence(1)).Return(errors.New("invalid payload"))
+	mockPublisher.On("Publish", context.Background(), eventWithSequence(2)).Return(nil)
+	mockOutbox.On("MarkEventDeadLettered", context.Background(), int64(1), "invalid payload").Return(nil)
+	mockOutbox.On("MarkEventPublished", context.Background(), int64(2)).Return(nil)
+
+	published, err := relay.RelayPendingEvents(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, published)
+	mockOutbox.AssertNotCalled(t, "MarkEventFailed", mock.Anything, mock.Anything, mock.Anything)
+	mockPublisher.AssertNotCalled(t, "Publish", context.Background(), eventWithSequence(3))
+	mockOutbox.AssertExpectations(t)
+}
+
+func TestRelayPendingEvents_SelectedEvents(t *testing.T) {
+	mockOutbox := new(MockOutboxRepository)
+	mockPublisher := new(MockEventPublisher)
+	selector := domain.EventSelector{Types: []domain.EventType{domain.EventAuditRecorded}}
+	relay := service.NewOutboxRelay(mockOutbox, mockPublisher, time.Second, 10, 3, service.WithRelayedEvents(selector))
+
+	events := []domain.Event{{Sequence: 4, Type: domain.EventAuditRecorded, ProductID: 1}}
+	mockOutbox.On("GetPendingEvents", context.Background(), selector, uint64(10)).Return(events, nil)
+	mockPublisher.On("Publish", context.Background(), eventWithSequence(4)).Return(nil)
+	mockOutbox.On("MarkEventPublished", context.Background(), int64(4)).Return(nil)
+
+	published, err := relay.RelayPendingEvents(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, published)
+	mockOutbox.AssertExpectations(t)
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"log"
+	"sync"
 
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
@@ -10,21 +12,90 @@ import (
 // Implement port.ProductRepository, so be able to access it functionality
 type ProductService struct {
 	productRepository port.ProductRepository
+	notifier          port.Notifier
+	auditService      port.AuditService
+	outboxAudit       bool
+	authorizer        port.Authorizer
+	rules             domain.ProductRules
+
+	// Products that already have a pending low stock alert,
+	// an alert is only sent again once the stock has recovered
+	mu              sync.Mutex
+	lowStockAlerted map[int64]struct{}
+}
+
+// Optional dependency of the product service
+type ProductServiceOption func(*ProductService)
+
+// Send low stock alerts through the given notifier
+func WithNotifier(notifier port.Notifier) ProductServiceOption {
+	return func(ps *ProductService) {
+		ps.notifier = notifier
+	}
+}
+
+// Record an audit entry for every product change
+func WithAuditService(auditService port.AuditService) ProductServiceOption {
+	return func(ps *ProductService) {
+		ps.auditService = auditService
+	}
+}
+
+/*
+ * Have the product repository write the audit entry of every product change
+ * to its outbox in the transaction of the change, instead of recording it afterwards.
+ * The audit service stores the entries as the outbox relay publishes them
+ */
+func WithOutboxAudit() ProductServiceOption {
+	return func(ps *ProductService) {
+		ps.outboxAudit = true
+	}
+}
+
+// Check the caller permission before every product change
+func WithAuthorizer(authorizer port.Authorizer) ProductServiceOption {
+	return func(ps *ProductService) {
+		ps.authorizer = authorizer
+	}
+}
+
+// Enforce the given business rules instead of domain.DefaultProductRules
+func WithProductRules(rules domain.ProductRules) ProductServiceOption {
+	return func(ps *ProductService) {
+		ps.rules = rules
+	}
 }
 
 // Create new product service instance
-func NewProductService(productRepository port.ProductRepository) port.ProductService {
-	return &ProductService{
-		productRepository,
+func NewProductService(productRepository port.ProductRepository, opts ...ProductServiceOption) port.ProductService {
+	ps := &ProductService{
+		productRepository: productRepository,
+		rules:             domain.DefaultProductRules(),
+		lowStockAlerted:   make(map[int64]struct{}),
+	}
+	for _, opt := range opts {
+		opt(ps)
 	}
+
+	return ps
 }
 
 func (ps *ProductService) CreateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error) {
-	createdProduct, err := ps.productRepository.CreateProduct(ctx, product)
+	if err := ps.authorize(ctx, domain.PermissionProductsCreate); err != nil {
+		return nil, err
+	}
+	if err := ps.rules.Validate(product); err != nil {
+		return nil, err
+	}
+
+	writeCtx, pending := ps.pendingAudit(ctx, domain.AuditActionCreate)
+	createdProduct, err := ps.productRepository.CreateProduct(writeCtx, product)
 	if err != nil {
 		return nil, err
 	}
 
+	ps.recordChange(ctx, pending, createdProduct.ID, createdProduct)
+	ps.checkStockLevel(ctx, createdProduct)
 	return createdProduct, nil
 }
 
@@ -54,20 +125,149 @@ func (ps *ProductService) GetProducts(
 	return products, totalCount, nil
 }
 
+func (ps *ProductService) GetLowStockProducts(ctx context.Context) ([]domain.Product, error) {
+	products, err := ps.productRepository.GetLowStockProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
 func (ps *ProductService) UpdateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error) {
-	updatedProduct, err := ps.productRepository.UpdateProduct(ctx, product)
+	if err := ps.authorize(ctx, domain.PermissionProductsUpdate); err != nil {
+		return nil, err
+	}
+	if err := ps.rules.Validate(product); err != nil {
+		return nil, err
+	}
+
+	writeCtx, pending := ps.pendingAudit(ctx, domain.AuditActionUpdate)
+	updatedProduct, err := ps.productRepository.UpdateProduct(writeCtx, product)
 	if err != nil {
 		return nil, err
 	}
 
+	ps.recordChange(ctx, pending, updatedProduct.ID, updatedProduct)
+	ps.checkStockLevel(ctx, updatedProduct)
 	return updatedProduct, nil
 }
 
-func (ps *ProductService) DeleteProduct(ctx context.Context, id int64) error {
-	err := ps.productRepository.DeleteProduct(ctx, id)
+func (ps *ProductService) AdjustStock(ctx context.Context, id int64, delta int) (*domain.Product, error) {
+	if err := ps.authorize(ctx, domain.PermissionProductsUpdate); err != nil {
+		return nil, err
+	}
+
+	if err := ps.validateStockIncrease(ctx, id, delta); err != nil {
+		return nil, err
+	}
+
+	writeCtx, pending := ps.pendingAudit(ctx, domain.AuditActionAdjustStock)
+	adjustedProduct, err := ps.productRepository.AdjustStock(writeCtx, id, delta)
 	if err != nil {
+		return nil, err
+	}
+
+	ps.recordChange(ctx, pending, id, adjustedProduct)
+	ps.checkStockLevel(ctx, adjustedProduct)
+	return adjustedProduct, nil
+}
+
+func (ps *ProductService) DeleteProduct(ctx context.Context, id int64) error {
+	if err := ps.authorize(ctx, domain.PermissionProductsDelete); err != nil {
+		return err
+	}
+
+	writeCtx, pending := ps.pendingAudit(ctx, domain.AuditActionDelete)
+	if err := ps.productRepository.DeleteProduct(writeCtx, id); err != nil {
 		return err
 	}
 
+	ps.recordChange(ctx, pending, id, nil)
+
+	ps.mu.Lock()
+	delete(ps.lowStockAlerted, id)
+	ps.mu.Unlock()
+
 	return nil
 }
+
+func (ps *ProductService) authorize(ctx context.Context, permission domain.Permission) error {
+	if ps.authorizer == nil {
+		return nil
+	}
+	return ps.authorizer.Authorize(ctx, permission)
+}
+
+/*
+ * Check the maximum stock before adding to it, a stock going below zero
+ * is refused atomically by the repository as insufficient stock instead
+ */
+func (ps *ProductService) validateStockIncrease(ctx context.Context, id int64, delta int) error {
+	if ps.rules.MaxStock <= 0 || delta <= 0 {
+		return nil
+	}
+
+	current, err := ps.productRepository.GetProductById(ctx, id)
+	if err != nil {
+		return err
+	}
+	return ps.rules.ValidateStock(current.Stock + delta)
+}
+
+/*
+ * Context of the repository write, it carries the audited change when the audit trail is enabled.
+ * The repository fills in the state it replaced, pending is nil when nothing is audited
+ */
+func (ps *ProductService) pendingAudit(ctx context.Context, action domain.AuditAction) (context.Context, *domain.PendingAudit) {
+	if ps.auditService == nil && !ps.outboxAudit {
+		return ctx, nil
+	}
+	return domain.WithPendingAudit(ctx, action)
+}
+
+/*
+ * Record the change in the audit trail when the repository does not write it to its outbox.
+ * The change is already stored, so a failure is only logged, the audit service keeps the entry and retries it
+ */
+func (ps *ProductService) recordChange(ctx context.Context, pending *domain.PendingAudit, productID int64, after *domain.Product) {
+	if pending == nil || ps.outboxAudit {
+		return
+	}
+
+	if err := ps.auditService.RecordProductChange(ctx, pending.Action, productID, pending.Before, after); err != nil {
+		log.Println("error when recording audit entry, it is retried in the background", err)
+	}
+}
+
+/*
+ * Send a low stock alert when the product stock is below its threshold,
+ * the alert is deduplicated until the stock recovers above the threshold
+ */
+func (ps *ProductService) checkStockLevel(ctx context.Context, product *domain.Product) {
+	if ps.notifier == nil {
+		return
+	}
+
+	ps.mu.Lock()
+	if !product.IsLowStock() {
+		delete(ps.lowStockAlerted, product.ID)
+		ps.mu.Unlock()
+		return
+	}
+	if _, alerted := ps.lowStockAlerted[product.ID]; alerted {
+		ps.mu.Unlock()
+		return
+	}
+	ps.lowStockAlerted[product.ID] = struct{}{}
+	ps.mu.Unlock()
+
+	if err := ps.notifier.NotifyLowStock(ctx, domain.NewLowStockAlert(product)); err != nil {
+		log.Println("error when sending low stock alert", err)
+
+		// Allow the alert to be retried on the next stock change
+		ps.mu.Lock()
+		delete(ps.lowStockAlerted, product.ID)
+		ps.mu.Unlock()
+	}
+}
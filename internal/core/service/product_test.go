@@ -2,6 +2,7 @@ package service_test
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
@@ -46,11 +47,36 @@ func (m *MockProductRepository) UpdateProduct(ctx context.Context, product *doma
 	return args.Get(0).(*domain.Product), nil
 }
 
+func (m *MockProductRepository) GetLowStockProducts(ctx context.Context) ([]domain.Product, error) {
+	args := m.Called(ctx)
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Product), nil
+}
+
+func (m *MockProductRepository) AdjustStock(ctx context.Context, id int64, delta int) (*domain.Product, error) {
+	args := m.Called(ctx, id, delta)
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Product), nil
+}
+
 func (m *MockProductRepository) DeleteProduct(ctx context.Context, id int64) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) NotifyLowStock(ctx context.Context, alert *domain.LowStockAlert) error {
+	args := m.Called(ctx, alert)
+	return args.Error(0)
+}
+
 /*
  * Test Create Product
  * Success, Invalid Data (price)
@@ -77,13 +103,72 @@ func TestCreateProduct_InvalidData(t *testing.T) {
 
 	product := &domain.Product{ID: 1, Name: "Samsung A2", Stock: 100, Price: -1000}
 
-	mockRepo.On("CreateProduct", context.Background(), product).Return(nil, domain.ErrInternal)
-
 	createdProduct, err := productService.CreateProduct(context.Background(), product)
 
-	assert.Error(t, err)
+	var verr *domain.ValidationError
+	assert.ErrorAs(t, err, &verr)
+	assert.Equal(t, []domain.FieldError{{Field: "price", Rule: "min", Message: "price must be greater than 0"}}, verr.Errors)
 	assert.Nil(t, createdProduct)
-	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "CreateProduct", mock.Anything, mock.Anything)
+}
+
+/*
+ * Test Product Business Rules
+ * Name charset and length, configured max price, update, max stock on adjustment
+ */
+func TestCreateProduct_InvalidName(t *testing.T) {
+	productService := service.NewProductService(new(MockProductRepository))
+
+	_, err := productService.CreateProduct(context.Background(), &domain.Product{Name: "Samsung <A2>", Stock: 1, Price: 1000})
+	var verr *domain.ValidationError
+	assert.ErrorAs(t, err, &verr)
+	assert.Equal(t, "charset", verr.Errors[0].Rule)
+
+	_, err = productService.CreateProduct(context.Background(), &domain.Product{Name: strings.Repeat("a", 256), Stock: 1, Price: 1000})
+	assert.ErrorAs(t, err, &verr)
+	assert.Equal(t, "max_length", verr.Errors[0].Rule)
+}
+
+func TestCreateProduct_MaxPrice(t *testing.T) {
+	mockRepo := new(MockProductRepository)
+	rules := domain.DefaultProductRules()
+	rules.MaxPrice = 10000
+	productService := service.NewProductService(mockRepo, service.WithProductRules(rules))
+
+	_, err := productService.CreateProduct(context.Background(), &domain.Product{Name: "Samsung A2", Stock: 1, Price: 10001})
+
+	var verr *domain.ValidationError
+	assert.ErrorAs(t, err, &verr)
+	assert.Equal(t, []domain.FieldError{{Field: "price", Rule: "max", Message: "price must be at most 10000"}}, verr.Errors)
+}
+
+func TestUpdateProduct_InvalidData(t *testing.T) {
+	mockRepo := new(MockProductRepository)
+	productService := service.NewProductService(mockRepo)
+
+	_, err := productService.UpdateProduct(context.Background(), &domain.Product{ID: 1, Name: "Samsung A2", Stock: -1, Price: 1000, ReorderThreshold: -5})
+
+	var verr *domain.ValidationError
+	assert.ErrorAs(t, err, &verr)
+	assert.Len(t, verr.Errors, 2)
+	mockRepo.AssertNotCalled(t, "UpdateProduct", mock.Anything, mock.Anything)
+}
+
+func TestAdjustStock_AboveMaxStock(t *testing.T) {
+	mockRepo := new(MockProductRepository)
+	rules := domain.DefaultProductRules()
+	rules.MaxStock = 100
+	productService := service.NewProductService(mockRepo, service.WithProductRules(rules))
+
+	mockRepo.On("GetProductById", context.Background(), int64(1)).
+		Return(&domain.Product{ID: 1, Name: "Samsung A1", Stock: 95, Price: 1000}, nil)
+
+	_, err := productService.AdjustStock(context.Background(), 1, 10)
+
+	var verr *domain.ValidationError
+	assert.ErrorAs(t, err, &verr)
+	assert.Equal(t, "stock", verr.Errors[0].Field)
+	mockRepo.AssertNotCalled(t, "AdjustStock", mock.Anything, mock.Anything, mock.Anything)
 }
 
 /*
@@ -268,3 +353,157 @@ func TestDeleteProduct_NotFound(t *testing.T) {
 	assert.Equal(t, domain.ErrProductNotFound, err)
 	mockRepo.AssertExpectations(t)
 }
+
+/*
+ * Test Low Stock Alert
+ * Alert once below threshold, alert again after recovery
+ */
+func TestAdjustStock_LowStockAlertDeduplicated(t *testing.T) {
+	mockRepo := new(MockProductRepository)
+	mockNotifier := new(MockNotifier)
+	productService := service.NewProductService(mockRepo, service.WithNotifier(mockNotifier))
+
+	productID := int64(1)
+	mockRepo.On("AdjustStock", context.Background(), productID, -6).
+		Return(&domain.Product{ID: productID, Name: "Samsung A1", Stock: 4, Price: 1000, ReorderThreshold: 5}, nil).Once()
+	mockRepo.On("AdjustStock", context.Background(), productID, -1).
+		Return(&domain.Product{ID: productID, Name: "Samsung A1", Stock: 3, Price: 1000, ReorderThreshold: 5}, nil).Once()
+	mockNotifier.On("NotifyLowStock", context.Background(), mock.MatchedBy(func(alert *domain.LowStockAlert) bool {
+		return alert.ProductID == productID && alert.Stock == 4 && alert.Threshold == 5
+	})).Return(nil).Once()
+
+	_, err := productService.AdjustStock(context.Background(), productID, -6)
+	assert.NoError(t, err)
+
+	_, err = productService.AdjustStock(context.Background(), productID, -1)
+	assert.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+	mockNotifier.AssertExpectations(t)
+}
+
+func TestUpdateProduct_LowStockAlertAfterRecovery(t *testing.T) {
+	mockRepo := new(MockProductRepository)
+	mockNotifier := new(MockNotifier)
+	productService := service.NewProductService(mockRepo, service.WithNotifier(mockNotifier))
+
+	low := &domain.Product{ID: 1, Name: "Samsung A1", Stock: 2, Price: 1000, ReorderThreshold: 5}
+	recovered := &domain.Product{ID: 1, Name: "Samsung A1", Stock: 10, Price: 1000, ReorderThreshold: 5}
+
+	mockRepo.On("UpdateProduct", context.Background(), low).Return(low, nil)
+	mockRepo.On("UpdateProduct", context.Background(), recovered).Return(recovered, nil)
+	mockNotifier.On("NotifyLowStock", context.Background(), mock.Anything).Return(nil).Twice()
+
+	for _, product := range []*domain.Product{low, low, recovered, low} {
+		_, err := productService.UpdateProduct(context.Background(), product)
+		assert.NoError(t, err)
+	}
+
+	mockNotifier.AssertNumberOfCalls(t, "NotifyLowStock", 2)
+}
+
+func TestGetLowStockProducts_Success(t *testing.T) {
+	mockRepo := new(MockProductRepository)
+	productService := service.NewProductService(mockRepo)
+
+	expectedProducts := []domain.Product{
+		{ID: 1, Name: "Samsung A1", Stock: 2, Price: 1000, ReorderThreshold: 5},
+	}
+
+	mockRepo.On("GetLowStockProducts", context.Background()).Return(expectedProducts, nil)
+
+	products, err := productService.GetLowStockProducts(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedProducts, products)
+	mockRepo.AssertExpectations(t)
+}
+
+/*
+ * Test Audit Trail
+ * Update and delete record the state the repository write replaced,
+ * an audit failure keeps the stored change successful,
+ * outbox audit is handed to the repository instead of recorded afterwards
+ */
+
+// Mock the repository reporting the replaced product to the pending audit of the context
+func reportBefore(before *domain.Product) func(mock.Arguments) {
+	return func(args mock.Arguments) {
+		if pending, ok := domain.PendingAuditFromContext(args.Get(0).(context.Context)); ok {
+			pending.Before = before
+		}
+	}
+}
+
+func TestUpdateProduct_RecordsAuditEntry(t *testing.T) {
+	mockRepo := new(MockProductRepository)
+	mockAudit := new(MockAuditService)
+	productService := service.NewProductService(mockRepo, service.WithAuditService(mockAudit))
+
+	before := &domain.Product{ID: 1, Name: "Samsung A1", Stock: 100, Price: 1000}
+	after := &domain.Product{ID: 1, Name: "Samsung A1", Stock: 100, Price: 1500}
+
+	mockRepo.On("UpdateProduct", mock.Anything, after).Run(reportBefore(before)).Return(after, nil)
+	mockAudit.On("RecordProductChange", context.Background(), domain.AuditActionUpdate, int64(1), before, after).Return(nil)
+
+	_, err := productService.UpdateProduct(context.Background(), after)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "GetProductById", mock.Anything, mock.Anything)
+	mockAudit.AssertExpectations(t)
+}
+
+func TestDeleteProduct_RecordsAuditEntry(t *testing.T) {
+	mockRepo := new(MockProductRepository)
+	mockAudit := new(MockAuditService)
+	productService := service.NewProductService(mockRepo, service.WithAuditService(mockAudit))
+
+	before := &domain.Product{ID: 1, Name: "Samsung A1", Stock: 100, Price: 1000}
+
+	mockRepo.On("DeleteProduct", mock.Anything, int64(1)).Run(reportBefore(before)).Return(nil)
+	mockAudit.On("RecordProductChange", context.Background(), domain.AuditActionDelete, int64(1), before, (*domain.Product)(nil)).Return(nil)
+
+	err := productService.DeleteProduct(context.Background(), 1)
+
+	assert.NoError(t, err)
+	mockAudit.AssertExpectations(t)
+}
+
+func TestUpdateProduct_AuditFailure(t *testing.T) {
+	mockRepo := new(MockProductRepository)
+	mockAudit := new(MockAuditService)
+	productService := service.NewProductService(mockRepo, service.WithAuditService(mockAudit))
+
+	before := &domain.Product{ID: 1, Name: "Samsung A1", Stock: 100, Price: 1000}
+	after := &domain.Product{ID: 1, Name: "Samsung A1", Stock: 100, Price: 1500}
+
+	mockRepo.On("UpdateProduct", mock.Anything, after).Run(reportBefore(before)).Return(after, nil)
+	mockAudit.On("RecordProductChange", context.Background(), domain.AuditActionUpdate, int64(1), before, after).Return(domain.ErrInternal)
+
+	result, err := productService.UpdateProduct(context.Background(), after)
+
+	// The update is stored, so it succeeds and a retry of the request doesn't repeat it
+	assert.NoError(t, err)
+	assert.Equal(t, after, result)
+	mockRepo.AssertExpectations(t)
+	mockAudit.AssertExpectations(t)
+}
+
+func TestUpdateProduct_OutboxAudit(t *testing.T) {
+	mockRepo := new(MockProductRepository)
+	productService := service.NewProductService(mockRepo, service.WithOutboxAudit())
+
+	after := &domain.Product{ID: 1, Name: "Samsung A1", Stock: 100, Price: 1500}
+
+	mockRepo.On("UpdateProduct", mock.MatchedBy(func(ctx context.Context) bool {
+		pending, ok := domain.PendingAuditFromContext(ctx)
+		return ok && pending.Action == domain.AuditActionUpdate && pending.Before == nil
+	}), after).Return(after, nil)
+
+	_, err := productService.UpdateProduct(context.Background(), after)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "GetProductById", mock.Anything, mock.Anything)
+}
@@ -2,21 +2,216 @@ package service
 
 import (
 	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
 	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
 )
 
+// What to do with a profiling record when the queue is full
+type ProfilingDropPolicy string
+
+const (
+	// Drop the new record, the queued ones are kept
+	ProfilingDropNewest ProfilingDropPolicy = "drop_newest"
+	// Drop the oldest queued record to make room for the new one
+	ProfilingDropOldest ProfilingDropPolicy = "drop_oldest"
+)
+
+type ProfilingWriterConfig struct {
+	Workers       int
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+	// Timeout of a single batch insert
+	FlushTimeout time.Duration
+	DropPolicy   ProfilingDropPolicy
+}
+
+/*
+ * Profiling service writes the profiling data in the background,
+ * records are queued in a bounded queue and workers insert them in batches,
+ * a batch is flushed when it is full or when the flush interval elapses.
+ * When the queue is full records are dropped following the drop policy,
+ * so a slow database never slows down the requests
+ */
 type ProfilingService struct {
 	profilingRepository port.ProfilingRepository
+	config              ProfilingWriterConfig
+	queue               chan domain.Profiling
+
+	// Guards the queue against sends after Close
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+
+	dropped atomic.Uint64
+	flushed atomic.Uint64
+	failed  atomic.Uint64
 }
 
-func NewProfilingService(profilingRepository port.ProfilingRepository) port.ProfilingService {
+func NewProfilingService(profilingRepository port.ProfilingRepository, config ProfilingWriterConfig) *ProfilingService {
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 1
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.FlushTimeout <= 0 {
+		config.FlushTimeout = 5 * time.Second
+	}
+
 	return &ProfilingService{
 		profilingRepository: profilingRepository,
+		config:              config,
+		queue:               make(chan domain.Profiling, config.QueueSize),
+	}
+}
+
+var _ port.ProfilingService = (*ProfilingService)(nil)
+
+// Start the workers, they run until Close
+func (s *ProfilingService) Start() {
+	for i := 0; i < s.config.Workers; i++ {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.work()
+		}()
+	}
+}
+
+/*
+ * Close stops accepting records and waits until the workers flushed the queue,
+ * records still queued when ctx is done are lost
+ */
+func (s *ProfilingService) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *ProfilingService) RecordProfiling(data *domain.Profiling) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		s.dropped.Add(1)
+		return
+	}
+
+	select {
+	case s.queue <- *data:
+		return
+	default:
+	}
+
+	if s.config.DropPolicy == ProfilingDropOldest {
+		select {
+		case <-s.queue:
+			s.dropped.Add(1)
+		default:
+		}
+		select {
+		case s.queue <- *data:
+			return
+		default:
+		}
+	}
+
+	s.dropped.Add(1)
+}
+
+func (s *ProfilingService) Stats() domain.ProfilingWriterStats {
+	return domain.ProfilingWriterStats{
+		Queued:  len(s.queue),
+		Dropped: s.dropped.Load(),
+		Flushed: s.flushed.Load(),
+		Failed:  s.failed.Load(),
+	}
+}
+
+func (s *ProfilingService) GetProfilings(ctx context.Context, filter domain.ProfilingFilter) ([]domain.Profiling, int64, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Limit < 1 {
+		filter.Limit = 10
 	}
+
+	return s.profilingRepository.GetProfilings(ctx, filter)
 }
 
-func (s *ProfilingService) InsertProfilingData(ctx context.Context, data *domain.Profiling) (*domain.Profiling, error) {
-	return s.profilingRepository.InsertProfilingData(ctx, data)
+func (s *ProfilingService) GetRouteStats(ctx context.Context, filter domain.ProfilingFilter) ([]domain.RouteStats, error) {
+	if err := filter.BoundStatsWindow(time.Now()); err != nil {
+		return nil, err
+	}
+
+	return s.profilingRepository.GetRouteStats(ctx, filter)
+}
+
+// Collect batches until the queue is closed and drained
+func (s *ProfilingService) work() {
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]domain.Profiling, 0, s.config.BatchSize)
+	for {
+		select {
+		case data, ok := <-s.queue:
+			if !ok {
+				s.flush(batch)
+				return
+			}
+			batch = append(batch, data)
+			if len(batch) >= s.config.BatchSize {
+				s.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			s.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (s *ProfilingService) flush(batch []domain.Profiling) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.FlushTimeout)
+	defer cancel()
+
+	if err := s.profilingRepository.InsertProfilingBatch(ctx, batch); err != nil {
+		log.Printf("error when flushing %d profiling records: %v", len(batch), err)
+		s.failed.Add(uint64(len(batch)))
+		return
+	}
+	s.flushed.Add(uint64(len(batch)))
 }
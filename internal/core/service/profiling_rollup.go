@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+/*
+ * Profiling rollup service keeps the hourly and daily rollups up to date.
+ * Every run rolls up again the hours since the previous run, including the current one,
+ * then the days of those hours. The first run looks back over the lookback period.
+ * Records written for hours already rolled up (late, or replayed from the spill)
+ * are reported with MarkWritten, the next run starts again at their hour.
+ * An hour rolled up again after its raw records expired would lose them,
+ * so the lookback must be shorter than the retention and older hours are never rolled up again
+ */
+type ProfilingRollupService struct {
+	rollupRepository port.ProfilingRollupRepository
+	interval         time.Duration
+	lookback         time.Duration
+	// Retention of the raw records, zero when they are kept forever
+	retention time.Duration
+
+	// Guards the hours below, MarkWritten is called by the profiling writers
+	mu sync.Mutex
+	// Start of the hour of the previous run, zero before the first run
+	lastHour time.Time
+	// Earliest hour written after it was rolled up, zero when there is none
+	lateHour time.Time
+	// Hour the current run is rolling up, zero between runs
+	rollingHour time.Time
+}
+
+func NewProfilingRollupService(rollupRepository port.ProfilingRollupRepository, interval time.Duration, lookback time.Duration, retention time.Duration) (*ProfilingRollupService, error) {
+	if retention > 0 && lookback >= retention {
+		return nil, fmt.Errorf("profiling rollup lookback %s must be shorter than the retention %s", lookback, retention)
+	}
+
+	return &ProfilingRollupService{
+		rollupRepository: rollupRepository,
+		interval:         interval,
+		lookback:         lookback,
+		retention:        retention,
+	}, nil
+}
+
+var _ port.ProfilingRollupService = (*ProfilingRollupService)(nil)
+
+// Roll up every interval until the context is cancelled
+func (s *ProfilingRollupService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Rollup(ctx, time.Now()); err != nil {
+			log.Println("error when rolling up profiling", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// MarkWritten reports records written from earliest, their hours are rolled up again by the next run
+func (s *ProfilingRollupService) MarkWritten(earliest time.Time) {
+	hour := earliest.UTC().Truncate(time.Hour)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The next run rolls up the hours from the previous one anyway, unless the current run
+	// already rolled the hour up or is reading its records, it then ends past the hour
+	running := !s.rollingHour.IsZero() && !hour.After(s.rollingHour)
+	if !running && !s.lastHour.IsZero() && !hour.Before(s.lastHour) {
+		return
+	}
+	if s.lateHour.IsZero() || hour.Before(s.lateHour) {
+		s.lateHour = hour
+	}
+}
+
+// Rollup rolls up the hours from the previous run (or the earliest late record) until now, and their days
+func (s *ProfilingRollupService) Rollup(ctx context.Context, now time.Time) error {
+	now = now.UTC()
+	currentHour := now.Truncate(time.Hour)
+
+	s.mu.Lock()
+	from := s.lastHour
+	if from.IsZero() {
+		from = now.Add(-s.lookback).Truncate(time.Hour)
+	}
+	if !s.lateHour.IsZero() && s.lateHour.Before(from) {
+		from = s.lateHour
+	}
+	// Only the hours whose records are all still kept
+	if s.retention > 0 {
+		if oldest := now.Add(-s.retention).Truncate(time.Hour).Add(time.Hour); from.Before(oldest) {
+			from = oldest
+		}
+	}
+	// A failure on the first hour resumes from it
+	s.lastHour, s.lateHour = from, time.Time{}
+	s.mu.Unlock()
+	defer s.setRollingHour(time.Time{})
+
+	// Days of the hours rolled up, they are rolled up even when a later hour failed
+	var days []time.Time
+	var hourErr error
+	for hour := from; !hour.After(currentHour); hour = hour.Add(time.Hour) {
+		s.setRollingHour(hour)
+		if hourErr = s.rollupRepository.RollupHour(ctx, hour); hourErr != nil {
+			break
+		}
+		// Record progress hour by hour, so a failure resumes where it stopped
+		s.setLastHour(hour)
+
+		day := truncateDay(hour)
+		if len(days) == 0 || !days[len(days)-1].Equal(day) {
+			days = append(days, day)
+		}
+	}
+
+	for _, day := range days {
+		if err := s.rollupRepository.RollupDay(ctx, day); err != nil {
+			return err
+		}
+	}
+	return hourErr
+}
+
+func (s *ProfilingRollupService) GetTrend(ctx context.Context, filter domain.RollupFilter) ([]domain.ProfilingRollup, error) {
+	if filter.Granularity != domain.RollupDaily {
+		filter.Granularity = domain.RollupHourly
+	}
+
+	return s.rollupRepository.GetRollups(ctx, filter)
+}
+
+func (s *ProfilingRollupService) setLastHour(hour time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastHour = hour
+}
+
+func (s *ProfilingRollupService) setRollingHour(hour time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rollingHour = hour
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,184 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockProfilingRollupRepository struct {
+	mock.Mock
+}
+
+func (m *MockProfilingRollupRepository) RollupHour(ctx context.Context, hour time.Time) error {
+	args := m.Called(ctx, hour)
+	return args.Error(0)
+}
+
+func (m *MockProfilingRollupRepository) RollupDay(ctx context.Context, day time.Time) error {
+	args := m.Called(ctx, day)
+	return args.Error(0)
+}
+
+func (m *MockProfilingRollupRepository) GetRollups(ctx context.Context, filter domain.RollupFilter) ([]domain.ProfilingRollup, error) {
+	args := m.Called(ctx, filter)
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.ProfilingRollup), nil
+}
+
+func newRollupService(t *testing.T, mockRepo *MockProfilingRollupRepository, lookback time.Duration, retention time.Duration) *service.ProfilingRollupService {
+	rollupService, err := service.NewProfilingRollupService(mockRepo, time.Minute, lookback, retention)
+	require.NoError(t, err)
+	return rollupService
+}
+
+func utcTime(day int, hour int, minute int) time.Time {
+	return time.Date(2024, 3, day, hour, minute, 0, 0, time.UTC)
+}
+
+/*
+ * Test Profiling Rollup
+ * First run looks back across days, next run starts at the previous hour,
+ * failed hour is retried and its days still rolled up, late records roll up their hours again,
+ * also when written during a run for an hour it already rolled up,
+ * hours past the retention are never rolled up again, lookback must be shorter than the retention,
+ * default trend granularity
+ */
+func TestRollup_FirstRunLookback(t *testing.T) {
+	mockRepo := new(MockProfilingRollupRepository)
+	rollupService := newRollupService(t, mockRepo, 2*time.Hour, 0)
+
+	mockRepo.On("RollupHour", context.Background(), utcTime(1, 23, 0)).Return(nil)
+	mockRepo.On("RollupHour", context.Background(), utcTime(2, 0, 0)).Return(nil)
+	mockRepo.On("RollupHour", context.Background(), utcTime(2, 1, 0)).Return(nil)
+	mockRepo.On("RollupDay", context.Background(), utcTime(1, 0, 0)).Return(nil)
+	mockRepo.On("RollupDay", context.Background(), utcTime(2, 0, 0)).Return(nil)
+
+	err := rollupService.Rollup(context.Background(), utcTime(2, 1, 30))
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNumberOfCalls(t, "RollupHour", 3)
+}
+
+func TestRollup_NextRunStartsAtPreviousHour(t *testing.T) {
+	mockRepo := new(MockProfilingRollupRepository)
+	rollupService := newRollupService(t, mockRepo, 0, 0)
+	mockRepo.On("RollupHour", context.Background(), mock.Anything).Return(nil)
+	mockRepo.On("RollupDay", context.Background(), utcTime(2, 0, 0)).Return(nil)
+
+	assert.NoError(t, rollupService.Rollup(context.Background(), utcTime(2, 10, 55)))
+	assert.NoError(t, rollupService.Rollup(context.Background(), utcTime(2, 11, 5)))
+
+	// The hour of the first run is rolled up again, records may have arrived after it
+	mockRepo.AssertNumberOfCalls(t, "RollupHour", 3)
+	mockRepo.AssertCalled(t, "RollupHour", context.Background(), utcTime(2, 10, 0))
+	mockRepo.AssertCalled(t, "RollupHour", context.Background(), utcTime(2, 11, 0))
+}
+
+func TestRollup_FailedHourIsRetried(t *testing.T) {
+	mockRepo := new(MockProfilingRollupRepository)
+	rollupService := newRollupService(t, mockRepo, time.Hour, 0)
+	mongoDown := errors.New("server selection timeout")
+
+	mockRepo.On("RollupHour", context.Background(), utcTime(1, 23, 0)).Return(nil)
+	mockRepo.On("RollupHour", context.Background(), utcTime(2, 0, 0)).Return(mongoDown).Once()
+	mockRepo.On("RollupDay", context.Background(), utcTime(1, 0, 0)).Return(nil)
+
+	err := rollupService.Rollup(context.Background(), utcTime(2, 0, 10))
+
+	assert.ErrorIs(t, err, mongoDown)
+	mockRepo.AssertCalled(t, "RollupDay", context.Background(), utcTime(1, 0, 0))
+
+	mockRepo.On("RollupHour", context.Background(), utcTime(2, 0, 0)).Return(nil)
+	mockRepo.On("RollupDay", context.Background(), utcTime(2, 0, 0)).Return(nil)
+
+	assert.NoError(t, rollupService.Rollup(context.Background(), utcTime(2, 0, 20)))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRollup_LateRecords(t *testing.T) {
+	mockRepo := new(MockProfilingRollupRepository)
+	rollupService := newRollupService(t, mockRepo, 0, 0)
+	mockRepo.On("RollupHour", context.Background(), mock.Anything).Return(nil)
+	mockRepo.On("RollupDay", context.Background(), mock.Anything).Return(nil)
+
+	assert.NoError(t, rollupService.Rollup(context.Background(), utcTime(2, 11, 5)))
+
+	// Records of 8:xx replayed from the spill, and a later one that the next run covers anyway
+	rollupService.MarkWritten(utcTime(2, 8, 40))
+	rollupService.MarkWritten(utcTime(2, 11, 30))
+	assert.NoError(t, rollupService.Rollup(context.Background(), utcTime(2, 11, 10)))
+
+	mockRepo.AssertCalled(t, "RollupHour", context.Background(), utcTime(2, 8, 0))
+	mockRepo.AssertCalled(t, "RollupHour", context.Background(), utcTime(2, 9, 0))
+	mockRepo.AssertCalled(t, "RollupHour", context.Background(), utcTime(2, 10, 0))
+	// 11:00 on both runs, then 8:00 to 11:00 again
+	mockRepo.AssertNumberOfCalls(t, "RollupHour", 5)
+
+	// The late hours were caught up, the next run only rolls up the current hour
+	assert.NoError(t, rollupService.Rollup(context.Background(), utcTime(2, 11, 15)))
+	mockRepo.AssertNumberOfCalls(t, "RollupHour", 6)
+}
+
+func TestRollup_LateRecordsDuringRun(t *testing.T) {
+	mockRepo := new(MockProfilingRollupRepository)
+	rollupService := newRollupService(t, mockRepo, 3*time.Hour, 0)
+
+	// While the first run rolls up 10:00, records of 9:xx are replayed from the spill
+	mockRepo.On("RollupHour", context.Background(), utcTime(2, 10, 0)).Run(func(mock.Arguments) {
+		rollupService.MarkWritten(utcTime(2, 9, 30))
+	}).Return(nil).Once()
+	mockRepo.On("RollupHour", context.Background(), mock.Anything).Return(nil)
+	mockRepo.On("RollupDay", context.Background(), mock.Anything).Return(nil)
+
+	assert.NoError(t, rollupService.Rollup(context.Background(), utcTime(2, 11, 5)))
+	// 8:00 to 11:00
+	mockRepo.AssertNumberOfCalls(t, "RollupHour", 4)
+
+	// The next run starts again at 9:00 instead of 11:00
+	assert.NoError(t, rollupService.Rollup(context.Background(), utcTime(2, 11, 10)))
+	mockRepo.AssertNumberOfCalls(t, "RollupHour", 7)
+}
+
+func TestRollup_LateRecordsPastRetention(t *testing.T) {
+	mockRepo := new(MockProfilingRollupRepository)
+	rollupService := newRollupService(t, mockRepo, time.Hour, 3*time.Hour)
+	mockRepo.On("RollupHour", context.Background(), mock.Anything).Return(nil)
+	mockRepo.On("RollupDay", context.Background(), mock.Anything).Return(nil)
+
+	// With a 3h retention records before 8:10 expired at 11:10, 9:00 is the oldest hour fully kept
+	rollupService.MarkWritten(utcTime(2, 5, 0))
+	assert.NoError(t, rollupService.Rollup(context.Background(), utcTime(2, 11, 10)))
+
+	mockRepo.AssertNotCalled(t, "RollupHour", context.Background(), utcTime(2, 5, 0))
+	mockRepo.AssertNotCalled(t, "RollupHour", context.Background(), utcTime(2, 8, 0))
+	mockRepo.AssertCalled(t, "RollupHour", context.Background(), utcTime(2, 9, 0))
+}
+
+func TestNewProfilingRollupService_LookbackBeyondRetention(t *testing.T) {
+	_, err := service.NewProfilingRollupService(new(MockProfilingRollupRepository), time.Minute, 24*time.Hour, 24*time.Hour)
+
+	assert.Error(t, err)
+}
+
+func TestGetTrend_DefaultGranularity(t *testing.T) {
+	mockRepo := new(MockProfilingRollupRepository)
+	rollupService := newRollupService(t, mockRepo, 0, 0)
+	expectedFilter := domain.RollupFilter{Granularity: domain.RollupHourly, Route: "/products"}
+	mockRepo.On("GetRollups", context.Background(), expectedFilter).Return([]domain.ProfilingRollup{}, nil)
+
+	_, err := rollupService.GetTrend(context.Background(), domain.RollupFilter{Route: "/products"})
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,235 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// Records the inserted batches and the last filter, inserts wait on block when it is set
+type FakeProfilingRepository struct {
+	mu      sync.Mutex
+	batches [][]domain.Profiling
+	filter  domain.ProfilingFilter
+	err     error
+	block   chan struct{}
+}
+
+func (r *FakeProfilingRepository) InsertProfilingBatch(ctx context.Context, data []domain.Profiling) error {
+	if r.block != nil {
+		<-r.block
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.batches = append(r.batches, append([]domain.Profiling(nil), data...))
+	return nil
+}
+
+func (r *FakeProfilingRepository) GetProfilings(ctx context.Context, filter domain.ProfilingFilter) ([]domain.Profiling, int64, error) {
+	r.filter = filter
+	return []domain.Profiling{}, 0, r.err
+}
+
+func (r *FakeProfilingRepository) GetRouteStats(ctx context.Context, filter domain.ProfilingFilter) ([]domain.RouteStats, error) {
+	r.filter = filter
+	return []domain.RouteStats{}, r.err
+}
+
+func (r *FakeProfilingRepository) Batches() [][]domain.Profiling {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.batches
+}
+
+func profilingOf(path string) *domain.Profiling {
+	return &domain.Profiling{Method: "GET", Path: path}
+}
+
+/*
+ * Test Profiling Service
+ * Flush by batch size, flush by interval, drop newest, drop oldest,
+ * drain on close, record after close, failed batch
+ */
+func TestRecordProfiling_FlushByBatchSize(t *testing.T) {
+	repo := &FakeProfilingRepository{}
+	profilingService := service.NewProfilingService(repo, service.ProfilingWriterConfig{
+		Workers: 1, QueueSize: 10, BatchSize: 2, FlushInterval: time.Hour,
+	})
+	profilingService.Start()
+	defer profilingService.Close(context.Background())
+
+	profilingService.RecordProfiling(profilingOf("/a"))
+	profilingService.RecordProfiling(profilingOf("/b"))
+
+	assert.Eventually(t, func() bool { return len(repo.Batches()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Len(t, repo.Batches()[0], 2)
+	assert.Equal(t, uint64(2), profilingService.Stats().Flushed)
+}
+
+func TestRecordProfiling_FlushByInterval(t *testing.T) {
+	repo := &FakeProfilingRepository{}
+	profilingService := service.NewProfilingService(repo, service.ProfilingWriterConfig{
+		Workers: 1, QueueSize: 10, BatchSize: 100, FlushInterval: 20 * time.Millisecond,
+	})
+	profilingService.Start()
+	defer profilingService.Close(context.Background())
+
+	profilingService.RecordProfiling(profilingOf("/a"))
+
+	assert.Eventually(t, func() bool { return len(repo.Batches()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "/a", repo.Batches()[0][0].Path)
+}
+
+func TestRecordProfiling_DropNewest(t *testing.T) {
+	repo := &FakeProfilingRepository{}
+	// Workers are not started, so the queue stays full
+	profilingService := service.NewProfilingService(repo, service.ProfilingWriterConfig{
+		QueueSize: 2, BatchSize: 10, DropPolicy: service.ProfilingDropNewest,
+	})
+
+	profilingService.RecordProfiling(profilingOf("/a"))
+	profilingService.RecordProfiling(profilingOf("/b"))
+	profilingService.RecordProfiling(profilingOf("/c"))
+
+	assert.Equal(t, 2, profilingService.Stats().Queued)
+	assert.Equal(t, uint64(1), profilingService.Stats().Dropped)
+
+	profilingService.Start()
+	assert.NoError(t, profilingService.Close(context.Background()))
+	assert.Equal(t, []domain.Profiling{*profilingOf("/a"), *profilingOf("/b")}, repo.Batches()[0])
+}
+
+func TestRecordProfiling_DropOldest(t *testing.T) {
+	repo := &FakeProfilingRepository{}
+	profilingService := service.NewProfilingService(repo, service.ProfilingWriterConfig{
+		QueueSize: 2, BatchSize: 10, DropPolicy: service.ProfilingDropOldest,
+	})
+
+	profilingService.RecordProfiling(profilingOf("/a"))
+	profilingService.RecordProfiling(profilingOf("/b"))
+	profilingService.RecordProfiling(profilingOf("/c"))
+
+	assert.Equal(t, uint64(1), profilingService.Stats().Dropped)
+
+	profilingService.Start()
+	assert.NoError(t, profilingService.Close(context.Background()))
+	assert.Equal(t, []domain.Profiling{*profilingOf("/b"), *profilingOf("/c")}, repo.Batches()[0])
+}
+
+func TestClose_DrainsQueue(t *testing.T) {
+	repo := &FakeProfilingRepository{}
+	profilingService := service.NewProfilingService(repo, service.ProfilingWriterConfig{
+		Workers: 2, QueueSize: 100, BatchSize: 7, FlushInterval: time.Hour,
+	})
+	profilingService.Start()
+
+	for i := 0; i < 50; i++ {
+		profilingService.RecordProfiling(profilingOf("/a"))
+	}
+
+	assert.NoError(t, profilingService.Close(context.Background()))
+	total := 0
+	for _, batch := range repo.Batches() {
+		total += len(batch)
+	}
+	assert.Equal(t, 50, total)
+	assert.Equal(t, uint64(50), profilingService.Stats().Flushed)
+}
+
+func TestClose_Timeout(t *testing.T) {
+	repo := &FakeProfilingRepository{block: make(chan struct{})}
+	defer close(repo.block)
+	profilingService := service.NewProfilingService(repo, service.ProfilingWriterConfig{
+		Workers: 1, QueueSize: 10, BatchSize: 1,
+	})
+	profilingService.Start()
+	profilingService.RecordProfiling(profilingOf("/a"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, profilingService.Close(ctx), context.DeadlineExceeded)
+}
+
+func TestRecordProfiling_AfterClose(t *testing.T) {
+	repo := &FakeProfilingRepository{}
+	profilingService := service.NewProfilingService(repo, service.ProfilingWriterConfig{QueueSize: 10})
+	profilingService.Start()
+	assert.NoError(t, profilingService.Close(context.Background()))
+
+	assert.NotPanics(t, func() { profilingService.RecordProfiling(profilingOf("/a")) })
+	assert.Equal(t, uint64(1), profilingService.Stats().Dropped)
+}
+
+func TestRecordProfiling_FailedBatch(t *testing.T) {
+	repo := &FakeProfilingRepository{err: errors.New("mongo unavailable")}
+	profilingService := service.NewProfilingService(repo, service.ProfilingWriterConfig{QueueSize: 10, BatchSize: 10})
+	profilingService.Start()
+
+	profilingService.RecordProfiling(profilingOf("/a"))
+	profilingService.RecordProfiling(profilingOf("/b"))
+
+	assert.NoError(t, profilingService.Close(context.Background()))
+	assert.Equal(t, uint64(2), profilingService.Stats().Failed)
+	assert.Equal(t, uint64(0), profilingService.Stats().Flushed)
+}
+
+/*
+ * Test Profiling Queries
+ * Default pagination, filter is passed to the stats, stats default to the last hour,
+ * stats time range can't exceed a day
+ */
+func TestGetProfilings_DefaultPagination(t *testing.T) {
+	repo := &FakeProfilingRepository{}
+	profilingService := service.NewProfilingService(repo, service.ProfilingWriterConfig{})
+
+	_, _, err := profilingService.GetProfilings(context.Background(), domain.ProfilingFilter{Route: "/products/:id"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.ProfilingFilter{Route: "/products/:id", Page: 1, Limit: 10}, repo.filter)
+}
+
+func TestGetRouteStats_Filter(t *testing.T) {
+	repo := &FakeProfilingRepository{}
+	profilingService := service.NewProfilingService(repo, service.ProfilingWriterConfig{})
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(6 * time.Hour)
+
+	_, err := profilingService.GetRouteStats(context.Background(), domain.ProfilingFilter{Method: "GET", Status: 500, From: from, To: to})
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.ProfilingFilter{Method: "GET", Status: 500, From: from, To: to}, repo.filter)
+}
+
+func TestGetRouteStats_DefaultWindow(t *testing.T) {
+	repo := &FakeProfilingRepository{}
+	profilingService := service.NewProfilingService(repo, service.ProfilingWriterConfig{})
+
+	_, err := profilingService.GetRouteStats(context.Background(), domain.ProfilingFilter{})
+
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), repo.filter.To, time.Second)
+	assert.Equal(t, time.Hour, repo.filter.To.Sub(repo.filter.From))
+}
+
+func TestGetRouteStats_WindowTooLong(t *testing.T) {
+	repo := &FakeProfilingRepository{}
+	profilingService := service.NewProfilingService(repo, service.ProfilingWriterConfig{})
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := profilingService.GetRouteStats(context.Background(), domain.ProfilingFilter{From: from})
+
+	var verr *domain.ValidationError
+	assert.ErrorAs(t, err, &verr)
+	assert.Equal(t, domain.ProfilingFilter{}, repo.filter)
+}
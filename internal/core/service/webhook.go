@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/port"
+)
+
+// Retry policy of webhook deliveries
+type WebhookRetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Backoff before the given attempt number, doubled after every failed attempt
+func (p WebhookRetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+		if backoff >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return backoff
+}
+
+/*
+ * Webhook service manages subscriptions and delivers product events to them,
+ * it implements port.EventPublisher so the outbox relay can hand events over.
+ * Publishing only stores a pending delivery, the actual HTTP calls are made by
+ * DeliverDueWebhooks so a slow partner never blocks the relay.
+ */
+type WebhookService struct {
+	webhookRepository port.WebhookRepository
+	sender            port.WebhookSender
+	retryPolicy       WebhookRetryPolicy
+	batchSize         int64
+}
+
+func NewWebhookService(webhookRepository port.WebhookRepository, sender port.WebhookSender, retryPolicy WebhookRetryPolicy, batchSize int64) *WebhookService {
+	return &WebhookService{
+		webhookRepository: webhookRepository,
+		sender:            sender,
+		retryPolicy:       retryPolicy,
+		batchSize:         batchSize,
+	}
+}
+
+func (s *WebhookService) CreateSubscription(ctx context.Context, subscription *domain.WebhookSubscription) (*domain.WebhookSubscription, error) {
+	if err := s.sender.CheckURL(subscription.URL); err != nil {
+		return nil, err
+	}
+
+	subscription.TenantID = domain.TenantIDFromContext(ctx)
+	subscription.CreatedAt = time.Now()
+	return s.webhookRepository.CreateSubscription(ctx, subscription)
+}
+
+func (s *WebhookService) GetSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	return s.webhookRepository.GetSubscriptions(ctx)
+}
+
+func (s *WebhookService) GetSubscriptionById(ctx context.Context, id string) (*domain.WebhookSubscription, error) {
+	return s.webhookRepository.GetSubscriptionById(ctx, id)
+}
+
+func (s *WebhookService) DeleteSubscription(ctx context.Context, id string) error {
+	return s.webhookRepository.DeleteSubscription(ctx, id)
+}
+
+func (s *WebhookService) GetDeliveries(ctx context.Context, subscriptionID string, page int64, limit int64) ([]domain.WebhookDelivery, int64, error) {
+	if _, err := s.webhookRepository.GetSubscriptionById(ctx, subscriptionID); err != nil {
+		return nil, 0, err
+	}
+	return s.webhookRepository.GetDeliveries(ctx, subscriptionID, page, limit)
+}
+
+// Queue a delivery of the event for every subscription of its tenant that wants it
+func (s *WebhookService) Publish(ctx context.Context, event *domain.Event) error {
+	ctx = domain.WithTenantID(ctx, event.TenantID)
+	subscriptions, err := s.webhookRepository.GetSubscriptionsByEventType(ctx, event.Type)
+	if err != nil {
+		return err
+	}
+	if len(subscriptions) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, subscription := range subscriptions {
+		delivery := &domain.WebhookDelivery{
+			TenantID:       subscription.TenantID,
+			SubscriptionID: subscription.ID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Payload:        string(payload),
+			Status:         domain.WebhookDeliveryPending,
+			Attempts:       []domain.WebhookAttempt{},
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+
+		// The repository ignores a delivery that already exists for this event,
+		// so an event relayed twice is still delivered once per subscription
+		if err := s.webhookRepository.CreateDelivery(ctx, delivery); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Deliver due webhooks until the context is cancelled
+func (s *WebhookService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.DeliverDueWebhooks(ctx); err != nil {
+			log.Println("error when delivering webhooks", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Make one attempt for every delivery that is due, returns the number of succeeded deliveries
+func (s *WebhookService) DeliverDueWebhooks(ctx context.Context) (int, error) {
+	deliveries, err := s.webhookRepository.GetDueDeliveries(ctx, time.Now(), s.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	succeeded := 0
+	for i := range deliveries {
+		if s.attemptDelivery(ctx, &deliveries[i]) {
+			succeeded++
+		}
+	}
+
+	return succeeded, nil
+}
+
+func (s *WebhookService) attemptDelivery(ctx context.Context, delivery *domain.WebhookDelivery) bool {
+	attempt := domain.WebhookAttempt{
+		Number:      len(delivery.Attempts) + 1,
+		AttemptedAt: time.Now(),
+	}
+
+	ctx = domain.WithTenantID(ctx, delivery.TenantID)
+	subscription, err := s.webhookRepository.GetSubscriptionById(ctx, delivery.SubscriptionID.Hex())
+	if err == nil {
+		start := time.Now()
+		attempt.StatusCode, err = s.sender.Send(ctx, subscription.URL, subscription.Secret, delivery)
+		attempt.Duration = time.Since(start).Milliseconds()
+	}
+
+	delivery.UpdatedAt = time.Now()
+	switch {
+	case err == nil:
+		delivery.Status = domain.WebhookDeliverySucceeded
+	case err == domain.ErrWebhookNotFound || attempt.Number >= s.retryPolicy.MaxAttempts:
+		attempt.Error = err.Error()
+		delivery.Status = domain.WebhookDeliveryDeadLetter
+	default:
+		attempt.Error = err.Error()
+		delivery.NextAttemptAt = delivery.UpdatedAt.Add(s.retryPolicy.Backoff(attempt.Number))
+	}
+	delivery.Attempts = append(delivery.Attempts, attempt)
+
+	if err := s.webhookRepository.UpdateDelivery(ctx, delivery); err != nil {
+		log.Println("error when updating webhook delivery", err)
+	}
+
+	return delivery.Status == domain.WebhookDeliverySucceeded
+}
@@ -0,0 +1,234 @@
+package service_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/adapter/webhook"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/domain"
+	"github.com/mfauzirh/go-fiber-mongo-hexarch/internal/core/service"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// In memory webhook repository, deliveries are keyed by subscription and event like the mongo unique index
+type fakeWebhookRepository struct {
+	mu            sync.Mutex
+	subscriptions []domain.WebhookSubscription
+	deliveries    []*domain.WebhookDelivery
+}
+
+func (r *fakeWebhookRepository) CreateSubscription(ctx context.Context, subscription *domain.WebhookSubscription) (*domain.WebhookSubscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	subscription.ID = primitive.NewObjectID()
+	r.subscriptions = append(r.subscriptions, *subscription)
+	return subscription, nil
+}
+
+func (r *fakeWebhookRepository) GetSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	return r.subscriptions, nil
+}
+
+func (r *fakeWebhookRepository) GetSubscriptionById(ctx context.Context, id string) (*domain.WebhookSubscription, error) {
+	for _, subscription := range r.subscriptions {
+		if subscription.ID.Hex() == id {
+			return &subscription, nil
+		}
+	}
+	return nil, domain.ErrWebhookNotFound
+}
+
+func (r *fakeWebhookRepository) GetSubscriptionsByEventType(ctx context.Context, eventType domain.EventType) ([]domain.WebhookSubscription, error) {
+	var subscriptions []domain.WebhookSubscription
+	for _, subscription := range r.subscriptions {
+		if subscription.Subscribes(eventType) {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return subscriptions, nil
+}
+
+func (r *fakeWebhookRepository) DeleteSubscription(ctx context.Context, id string) error {
+	return nil
+}
+
+func (r *fakeWebhookRepository) CreateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.deliveries {
+		if existing.SubscriptionID == delivery.SubscriptionID && existing.EventID == delivery.EventID {
+			return nil
+		}
+	}
+	delivery.ID = primitive.NewObjectID()
+	copied := *delivery
+	r.deliveries = append(r.deliveries, &copied)
+	return nil
+}
+
+func (r *fakeWebhookRepository) GetDueDeliveries(ctx context.Context, now time.Time, limit int64) ([]domain.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deliveries []domain.WebhookDelivery
+	for _, delivery := range r.deliveries {
+		if delivery.Status == domain.WebhookDeliveryPending && !delivery.NextAttemptAt.After(now) {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (r *fakeWebhookRepository) UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, existing := range r.deliveries {
+		if existing.ID == delivery.ID {
+			copied := *delivery
+			r.deliveries[i] = &copied
+		}
+	}
+	return nil
+}
+
+func (r *fakeWebhookRepository) GetDeliveries(ctx context.Context, subscriptionID string, page int64, limit int64) ([]domain.WebhookDelivery, int64, error) {
+	var deliveries []domain.WebhookDelivery
+	for _, delivery := range r.deliveries {
+		if delivery.SubscriptionID.Hex() == subscriptionID {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	return deliveries, int64(len(deliveries)), nil
+}
+
+// Make every pending delivery due now, so the test does not wait for the backoff
+func (r *fakeWebhookRepository) expireBackoff() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, delivery := range r.deliveries {
+		delivery.NextAttemptAt = time.Time{}
+	}
+}
+
+func newTestWebhookService(repo *fakeWebhookRepository, maxAttempts int) *service.WebhookService {
+	return service.NewWebhookService(repo, webhook.NewHTTPSender(time.Second, true), service.WebhookRetryPolicy{
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+	}, 10)
+}
+
+/*
+ * Test Webhook Service
+ * Delivered with signature, retried then dead lettered, duplicate event, backoff,
+ * subscription to a private address is rejected
+ */
+func TestWebhookService_DeliversSignedEvent(t *testing.T) {
+	secret := "super-secret-value"
+	received := make(chan *http.Request, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	repo := &fakeWebhookRepository{}
+	webhookService := newTestWebhookService(repo, 3)
+	subscription, _ := webhookService.CreateSubscription(context.Background(), &domain.WebhookSubscription{
+		URL:        receiver.URL,
+		Secret:     secret,
+		EventTypes: []domain.EventType{domain.EventProductCreated},
+	})
+
+	event, _ := domain.NewEvent(domain.EventProductCreated, 1, domain.Product{ID: 1, Name: "Samsung A1"})
+	assert.NoError(t, webhookService.Publish(context.Background(), event))
+
+	// Not subscribed, no delivery is queued
+	deleted, _ := domain.NewEvent(domain.EventProductDeleted, 1, domain.Product{ID: 1})
+	assert.NoError(t, webhookService.Publish(context.Background(), deleted))
+
+	succeeded, err := webhookService.DeliverDueWebhooks(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, succeeded)
+
+	request := <-received
+	assert.NotEmpty(t, request.Header.Get(webhook.SignatureHeader))
+
+	deliveries, total, err := webhookService.GetDeliveries(context.Background(), subscription.ID.Hex(), 1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, domain.WebhookDeliverySucceeded, deliveries[0].Status)
+	assert.Equal(t, http.StatusOK, deliveries[0].Attempts[0].StatusCode)
+}
+
+func TestWebhookService_RetriesThenDeadLetters(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	repo := &fakeWebhookRepository{}
+	webhookService := newTestWebhookService(repo, 2)
+	subscription, _ := webhookService.CreateSubscription(context.Background(), &domain.WebhookSubscription{
+		URL:        receiver.URL,
+		Secret:     "super-secret-value",
+		EventTypes: []domain.EventType{domain.EventStockAdjusted},
+	})
+
+	event, _ := domain.NewEvent(domain.EventStockAdjusted, 1, domain.StockAdjustment{ProductID: 1, Delta: -1})
+	assert.NoError(t, webhookService.Publish(context.Background(), event))
+	// Relayed twice (at-least-once), still only one delivery
+	assert.NoError(t, webhookService.Publish(context.Background(), event))
+
+	_, err := webhookService.DeliverDueWebhooks(context.Background())
+	assert.NoError(t, err)
+
+	deliveries, _, _ := webhookService.GetDeliveries(context.Background(), subscription.ID.Hex(), 1, 10)
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, domain.WebhookDeliveryPending, deliveries[0].Status)
+	assert.True(t, deliveries[0].NextAttemptAt.After(time.Now()))
+
+	// Still backing off, nothing is attempted
+	_, err = webhookService.DeliverDueWebhooks(context.Background())
+	assert.NoError(t, err)
+	deliveries, _, _ = webhookService.GetDeliveries(context.Background(), subscription.ID.Hex(), 1, 10)
+	assert.Len(t, deliveries[0].Attempts, 1)
+
+	repo.expireBackoff()
+	_, err = webhookService.DeliverDueWebhooks(context.Background())
+	assert.NoError(t, err)
+
+	deliveries, _, _ = webhookService.GetDeliveries(context.Background(), subscription.ID.Hex(), 1, 10)
+	assert.Equal(t, domain.WebhookDeliveryDeadLetter, deliveries[0].Status)
+	assert.Len(t, deliveries[0].Attempts, 2)
+	assert.Equal(t, http.StatusInternalServerError, deliveries[0].Attempts[1].StatusCode)
+}
+
+func TestWebhookRetryPolicy_Backoff(t *testing.T) {
+	policy := service.WebhookRetryPolicy{MaxAttempts: 10, InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	assert.Equal(t, time.Second, policy.Backoff(1))
+	assert.Equal(t, 2*time.Second, policy.Backoff(2))
+	assert.Equal(t, 4*time.Second, policy.Backoff(3))
+	assert.Equal(t, 5*time.Second, policy.Backoff(4))
+}
+
+func TestWebhookService_RejectsPrivateURL(t *testing.T) {
+	repo := &fakeWebhookRepository{}
+	webhookService := service.NewWebhookService(repo, webhook.NewHTTPSender(time.Second, false), service.WebhookRetryPolicy{MaxAttempts: 1}, 10)
+
+	_, err := webhookService.CreateSubscription(context.Background(), &domain.WebhookSubscription{
+		URL:        "http://169.254.169.254/latest/meta-data",
+		Secret:     "super-secret-value",
+		EventTypes: []domain.EventType{domain.EventProductCreated},
+	})
+
+	var verr *domain.ValidationError
+	assert.ErrorAs(t, err, &verr)
+	subscriptions, _ := repo.GetSubscriptions(context.Background())
+	assert.Empty(t, subscriptions)
+}
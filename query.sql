@@ -1,6 +1,27 @@
 CREATE TABLE golangdb.products (
     id INT AUTO_INCREMENT PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
     name VARCHAR(255) NOT NULL,
     stock INT NOT NULL CHECK (stock >= 0),
-    price INT NOT NULL CHECK (price > 0)
+    price INT NOT NULL CHECK (price > 0),
+    reorder_threshold INT NOT NULL DEFAULT 0 CHECK (reorder_threshold >= 0),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    INDEX idx_products_tenant (tenant_id, id),
+    UNIQUE KEY uq_products_tenant_name (tenant_id, name)
+);
+
+CREATE TABLE golangdb.outbox_events (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    event_id CHAR(36) NOT NULL UNIQUE,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    event_type VARCHAR(64) NOT NULL,
+    product_id INT NOT NULL,
+    payload JSON NOT NULL,
+    occurred_at DATETIME(6) NOT NULL,
+    published_at DATETIME(6) NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    dead_lettered_at DATETIME(6) NULL,
+    INDEX idx_outbox_events_pending (published_at, dead_lettered_at, id),
+    INDEX idx_outbox_events_product (product_id, id)
 );